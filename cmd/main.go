@@ -2,18 +2,26 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/platform9/app-controller/pkg/api"
+	"github.com/platform9/app-controller/pkg/controller"
 	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
 	"github.com/platform9/app-controller/pkg/log"
+	"github.com/platform9/app-controller/pkg/metrics"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+	"github.com/platform9/app-controller/pkg/server"
 	"github.com/platform9/app-controller/pkg/util"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
@@ -21,31 +29,101 @@ import (
 func readEnv() {
 }
 
+// Connect to the database, exiting if it is not reachable within the
+// connect timeout.
+func initDB() {
+	if err := db.Init(context.Background()); err != nil {
+		zap.S().Errorf(err.Error())
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
 func run(*cobra.Command, []string) {
 	zap.S().Info("Starting app-controller...")
 	zap.S().Infof("Version of app-controller being used is: %s", util.Version)
-	router := api.New()
-	srv := &http.Server{
-		Handler: router,
-		Addr:    ":6112",
+	initDB()
+	// Apply the changes of the constraints, plans and log level without a restart.
+	options.Watch(cfgFile, func(config *options.Config) {
+		if err := log.SetLevel(config.Log.Level); err != nil {
+			zap.S().Errorf("Failed to reload log level. Error: %v", err)
+		}
+	})
+
+	clients, err := knative.NewClients(util.Kubeconfig)
+	if err != nil {
+		zap.S().Fatalf("Failed to create the Kubernetes clients. Error: %v", err)
+	}
+	store := db.Get()
+	apiServer := api.NewServer(store, clients)
+	router := api.New(apiServer)
+	serverConfig := server.ConfigFromOptions()
+	srv, err := server.New(router, serverConfig)
+	if err != nil {
+		zap.S().Fatalf("Failed to configure the API server. Error: %v", err)
+	}
+
+	// Background controllers, run by the leader replica only.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loops := []controller.Loop{}
+	if options.GetReaperEnabled() {
+		loops = append(loops, controller.NewReaper(clients, store).Run)
+	}
+	if options.GetGCEnabled() {
+		loops = append(loops, controller.NewGarbageCollector(clients, store).Run)
+	}
+	if options.GetReconcileEnabled() {
+		loops = append(loops, controller.NewReconciler(clients, store).Run)
+	}
+	if len(loops) > 0 {
+		go func() {
+			if err := controller.RunLeaderElected(ctx, clients.Kube, loops...); err != nil {
+				zap.S().Errorf("Failed to run background controllers. Error: %v", err)
+			}
+		}()
 	}
 
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
+		zap.S().Infof("Serving the API on %v, TLS: %v", serverConfig.Address, serverConfig.TLS())
+		if err := server.ListenAndServe(srv); err != nil && err != http.ErrServerClosed {
 			zap.S().Fatalf(err.Error())
 		}
 	}()
 
-	stop := make(chan os.Signal)
-	signal.Notify(stop, os.Interrupt)
+	// Metrics, on a listener separate from the API.
+	var metricsSrv *http.Server
+	if options.GetMetricsEnabled() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{
+			Handler: mux,
+			Addr:    options.GetMetricsAddress(),
+		}
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				zap.S().Fatalf(err.Error())
+			}
+		}()
+		go controller.NewUsageCollector(clients, store).Run(ctx)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	select {
 	case <-stop:
 		zap.S().Info("server stopping...")
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-		defer cancel()
-		if err := srv.Shutdown(ctx); err != nil {
+		cancel()
+		if err := server.Shutdown(srv, serverConfig, apiServer.SetShuttingDown); err != nil {
 			zap.S().Fatalf(err.Error())
 		}
+		if metricsSrv != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownGrace)
+			defer cancel()
+			if err := metricsSrv.Shutdown(ctx); err != nil {
+				zap.S().Errorf(err.Error())
+			}
+		}
 	}
 }
 
@@ -57,57 +135,306 @@ func main() {
 
 // Config file to read secrets like kubeconfig path, Database and auth0 credentials.
 const (
-	cfgFile = "/etc/pf9/app-controller/config.yaml"
+	defaultCfgFile = "/etc/pf9/app-controller/config.yaml"
 )
 
+// Config file set through --config.
+var cfgFile string
+
+// Skip initCfg for the commands not reading the config through it.
+func noInitCfg(*cobra.Command, []string) {}
+
 func buildCmds() *cobra.Command {
-	cobra.OnInitialize(initCfg)
 	rootCmd := &cobra.Command{
-		Use:   "app-controller",
-		Short: "app-controller is a service to interact knative kubernetes clusters",
-		Long:  "app-controller is a service to interact knative kubernetes clusters",
-		Run:   run,
+		Use:              "app-controller",
+		Short:            "app-controller is a service to interact knative kubernetes clusters",
+		Long:             "app-controller is a service to interact knative kubernetes clusters",
+		PersistentPreRun: initCfg,
+		Run:              run,
 	}
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", defaultCfgFile,
+		"Config file, its values can be overridden by "+options.EnvPrefix+"_* environment variables")
 
 	migrateCmd := &cobra.Command{
 		Use:   "migrate",
 		Short: "migrate initializes and upgrades database",
-		Long:  "migrate initializes and upgrades database",
+		Long:  "migrate initializes and upgrades database, same as migrate up",
+		Run: func(cmd *cobra.Command, args []string) {
+			initDB()
+			if err := db.Get().Migrate(context.Background()); err != nil {
+				zap.S().Errorf(err.Error())
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	var migrateTo string
+	migrateUpCmd := &cobra.Command{
+		Use:   "up",
+		Short: "up runs the pending migrations",
+		Long:  "up runs the pending migrations, up to and including the one of --to if set",
+		Run: func(cmd *cobra.Command, args []string) {
+			initDB()
+			if err := db.Get().MigrateUp(context.Background(), migrateTo); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	migrateUpCmd.Flags().StringVar(&migrateTo, "to", "", "Last migration to run, e.g. 005 or 005_domains.sql")
+
+	migrateDownCmd := &cobra.Command{
+		Use:   "down",
+		Short: "down rolls back the last migration",
+		Long:  "down rolls back the last migration, or every migration after the one of --to if set",
+		Run: func(cmd *cobra.Command, args []string) {
+			initDB()
+			if err := db.Get().MigrateDown(context.Background(), migrateTo); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	migrateDownCmd.Flags().StringVar(&migrateTo, "to", "", "Last migration to keep, e.g. 005 or 005_domains.sql")
+
+	migrateStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "status prints the state of the migrations",
+		Long:  "status prints the migrations with their state: applied, pending, modified since applied, or missing from this version",
+		Run: func(cmd *cobra.Command, args []string) {
+			initDB()
+			statuses, err := db.Get().MigrationStatus(context.Background())
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSTATE\tAPPLIED AT")
+			for _, status := range statuses {
+				appliedAt := ""
+				if !status.AppliedAt.IsZero() {
+					appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%v\t%v\t%v\n", status.Name, status.State, appliedAt)
+			}
+			w.Flush()
+		},
+	}
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+
+	var quotaEmail, quotaName string
+	var quotaMaxApp int
+	quotaCmd := &cobra.Command{
+		Use:   "quota",
+		Short: "quota sets the maximum apps deploy count of a user",
+		Long:  "quota sets the maximum apps deploy count of a user, 0 resets it to the configured constraint",
+		Run: func(cmd *cobra.Command, args []string) {
+			if quotaEmail == "" && quotaName == "" {
+				fmt.Println("One of --email or --name is required")
+				os.Exit(1)
+			}
+
+			initDB()
+			ctx := context.Background()
+			var user objects.User
+			dbHandle := db.Get()
+			var err error
+			if quotaEmail != "" {
+				err = dbHandle.GetUserByEmail(ctx, quotaEmail, &user)
+			} else {
+				err = dbHandle.GetUserByName(ctx, quotaName, &user)
+			}
+			if err != nil {
+				zap.S().Errorf(err.Error())
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			if user.ID == 0 {
+				fmt.Println("User not found")
+				os.Exit(1)
+			}
+
+			user.MaxApps = quotaMaxApp
+			if err := dbHandle.SetUserMaxApps(ctx, &user); err != nil {
+				zap.S().Errorf(err.Error())
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	quotaCmd.Flags().StringVar(&quotaEmail, "email", "", "Email of the user")
+	quotaCmd.Flags().StringVar(&quotaName, "name", "", "Name of the user, for github users")
+	quotaCmd.Flags().IntVar(&quotaMaxApp, "max-app", 0, "Maximum apps the user can deploy")
+
+	var deleteEmail, deleteName string
+	var deleteID int
+	deleteUserCmd := &cobra.Command{
+		Use:   "delete-user",
+		Short: "delete-user removes a user with all the apps and the namespace",
+		Long:  "delete-user removes the apps, secrets and namespace of a user, and then the user from database",
 		Run: func(cmd *cobra.Command, args []string) {
+			if deleteID == 0 && deleteEmail == "" && deleteName == "" {
+				fmt.Println("One of --id, --email or --name is required")
+				os.Exit(1)
+			}
+
+			initDB()
+			ctx := context.Background()
+			var user objects.User
 			dbHandle := db.Get()
-			if err := dbHandle.Migrate(); err != nil {
+			var err error
+			switch {
+			case deleteID != 0:
+				err = dbHandle.GetUserByID(ctx, deleteID, &user)
+			case deleteEmail != "":
+				err = dbHandle.GetUserByEmail(ctx, deleteEmail, &user)
+			default:
+				err = dbHandle.GetUserByName(ctx, deleteName, &user)
+			}
+			if err != nil {
+				zap.S().Errorf(err.Error())
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			if user.ID == 0 {
+				fmt.Println("User not found")
+				os.Exit(1)
+			}
+
+			clients, err := knative.NewClients(util.Kubeconfig)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			if err = controller.DeleteUser(ctx, clients, dbHandle, &user, "admin"); err != nil {
 				zap.S().Errorf(err.Error())
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	deleteUserCmd.Flags().IntVar(&deleteID, "id", 0, "ID of the user")
+	deleteUserCmd.Flags().StringVar(&deleteEmail, "email", "", "Email of the user")
+	deleteUserCmd.Flags().StringVar(&deleteName, "name", "", "Name of the user, for github users")
+
+	var reconcileFix bool
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "reconcile reports drift between the database users and the cluster namespaces",
+		Long:  "reconcile reports drift between the database users and the cluster namespaces as JSON, --fix recreates missing namespaces and quarantines orphan ones",
+		Run: func(cmd *cobra.Command, args []string) {
+			initDB()
+			clients, err := knative.NewClients(util.Kubeconfig)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			reconciler := controller.NewReconciler(clients, db.Get())
+			reconciler.Fix = reconcileFix
+			report, err := reconciler.Reconcile(context.Background())
+			if err != nil {
+				zap.S().Errorf(err.Error())
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				panic(err)
+			}
+			fmt.Println(string(data))
+		},
+	}
+	reconcileCmd.Flags().BoolVar(&reconcileFix, "fix", false, "Recreate missing namespaces and quarantine orphan namespaces")
+
+	backfillCmd := &cobra.Command{
+		Use:   "backfill-namespaces",
+		Short: "backfill-namespaces labels the existing namespaces of users",
+		Long:  "backfill-namespaces sets the managed-by, owner and Pod Security labels on the namespaces of the database users, created before they were labelled",
+		Run: func(cmd *cobra.Command, args []string) {
+			initDB()
+			clients, err := knative.NewClients(util.Kubeconfig)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			report, err := controller.Backfill(context.Background(), clients, db.Get())
+			if err != nil {
+				zap.S().Errorf(err.Error())
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
 				panic(err)
 			}
+			fmt.Println(string(data))
+		},
+	}
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "config inspects the configuration of app-controller",
+		Long:  "config inspects the configuration of app-controller",
+	}
+	configCheckCmd := &cobra.Command{
+		Use:              "check",
+		Short:            "check validates the config file and environment variables",
+		Long:             "check validates the config file and the " + options.EnvPrefix + "_* environment variables, and reports every invalid value",
+		PersistentPreRun: noInitCfg,
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := options.Load(cfgFile); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			fmt.Printf("Config %v is valid\n", cfgFile)
 		},
 	}
+	configCmd.AddCommand(configCheckCmd)
 
 	versionCmd := &cobra.Command{
-		Use:   "version",
-		Short: "Current version of app-controller being used",
-		Long:  "Current version of app-controller being used",
+		Use:              "version",
+		Short:            "Current version of app-controller being used",
+		Long:             "Current version of app-controller being used",
+		PersistentPreRun: noInitCfg,
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Println(util.Version)
 		},
 	}
 
 	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(quotaCmd)
+	rootCmd.AddCommand(deleteUserCmd)
+	rootCmd.AddCommand(reconcileCmd)
+	rootCmd.AddCommand(backfillCmd)
+	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	return rootCmd
 }
 
-func initCfg() {
-	viper.SetConfigFile(cfgFile)
-	if err := viper.ReadInConfig(); err != nil {
-		zap.S().Errorf(err.Error())
-		panic(err)
+// Load and validate the config, exiting with all its errors if invalid.
+func initCfg(*cobra.Command, []string) {
+	config, err := options.Load(cfgFile)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	options.Set(config)
+	util.Kubeconfig = options.GetKubeconfigFile()
+
+	// Log as configured, now that the config is read.
+	if err := log.Logger(); err != nil {
+		fmt.Printf("Failed to initiate logger, Error is: %s\n", err)
 	}
-	util.Kubeconfig = viper.GetString("kubeconfig.file")
 }
 
 func init() {
-	err := log.Logger()
+	// Log to stdout until the config is read.
+	err := log.Setup(log.Config{Output: log.OutputStdout, Redact: true})
 	if err != nil {
 		fmt.Printf("Failed to initiate logger, Error is: %s", err)
 	}
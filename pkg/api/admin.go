@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	applog "github.com/platform9/app-controller/pkg/log"
+	"github.com/platform9/app-controller/pkg/metrics"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+)
+
+// Plan change request structure.
+type PlanRequest struct {
+	Plan string `json:"plan"`
+}
+
+// Validate the token of the request and check that the caller is an admin,
+// responding with the error status otherwise.
+func (s *Server) validateAdmin(w http.ResponseWriter, r *http.Request) (*UserInfo, bool) {
+	log := requestLogger(r)
+	userInfo, ok := s.validateClaims(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !options.IsAdmin(userInfo.Email) {
+		log.Errorf("User %v is not an admin.", userInfo.NickName)
+		metrics.AuthFailure("not_admin")
+		w.WriteHeader(http.StatusForbidden)
+		return nil, false
+	}
+	return userInfo, true
+}
+
+// Write the value as JSON response.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	writeJSONStatus(w, http.StatusOK, value)
+}
+
+// Respond with the value marshalled as JSON and the status code.
+func writeJSONStatus(w http.ResponseWriter, status int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		zap.S().Errorf("Error while json marshalling the response. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	if _, err = w.Write(data); err != nil {
+		zap.S().Errorf("Error while responding over http. Error: %v", err)
+	}
+}
+
+// To list the subscription plans.
+func (s *Server) getPlans(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Get Plans *****")
+
+	if _, ok := s.validateClaims(w, r); !ok {
+		return
+	}
+
+	plans := []objects.Plan{}
+	for _, name := range options.GetPlanNames() {
+		plans = append(plans, options.GetPlan(name))
+	}
+	writeJSON(w, plans)
+}
+
+// To list all the users, admin only.
+func (s *Server) getUsers(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Get Users *****")
+
+	if _, ok := s.validateAdmin(w, r); !ok {
+		return
+	}
+
+	users := []objects.User{}
+	if err := s.Users.GetUsers(r.Context(), &users); err != nil {
+		log.Errorf("Failed to get users from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, users)
+}
+
+// To change the subscription plan of a user, admin only.
+func (s *Server) setUserPlan(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Set User Plan *****")
+
+	admin, ok := s.validateAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	event := auditAction(r, "set-plan", mux.Vars(r)["id"])
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Errorf("Error while reading data in request body. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	planReq := PlanRequest{}
+	if err = json.Unmarshal(body, &planReq); err != nil {
+		log.Errorf("Error while unmarhsalling request body data. Error: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !options.IsPlan(planReq.Plan) {
+		http.Error(w, "Unknown plan "+planReq.Plan, http.StatusBadRequest)
+		return
+	}
+
+	que := s.Users
+	var user objects.User
+	if err = que.GetUserByID(r.Context(), userID, &user); err != nil {
+		log.Errorf("Failed to get user from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	event.Space = user.Space
+	event.Detail = "plan " + planReq.Plan
+	user.Plan = options.GetPlan(planReq.Plan).Name
+	if err = que.SetUserPlan(r.Context(), &user); err != nil {
+		log.Errorf("Failed to update user plan in DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Apply the app count limit of the new plan right away.
+	if user.Space != "" {
+		if err = s.Clients.EnsureAppQuota(r.Context(), user.Space, GetUserPlan(&user).MaxApps); err != nil {
+			log.Errorf("Failed to update the app quota of user %v. Error: %v", user.ID, err)
+		}
+		if err = s.labelNamespace(r.Context(), &user); err != nil {
+			log.Errorf("Failed to label namespace of user %v. Error: %v", user.ID, err)
+		}
+	}
+
+	log.Infof("Plan of user %v set to %v by %v", user.ID, user.Plan, admin.Email)
+	writeJSON(w, user)
+}
+
+// To get or change the log level at runtime, e.g. {"level": "debug"}, admin only.
+func (s *Server) logLevel(w http.ResponseWriter, r *http.Request) {
+	requestLogger(r).Info("***** Log Level *****")
+
+	if _, ok := s.validateAdmin(w, r); !ok {
+		return
+	}
+	if r.Method == http.MethodPut {
+		auditAction(r, "set-log-level", "")
+	}
+	applog.Level.ServeHTTP(w, r)
+}
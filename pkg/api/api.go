@@ -1,14 +1,9 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"math/rand"
 	"net/http"
-	"regexp"
 	"strings"
-	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"go.uber.org/zap"
@@ -17,8 +12,11 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/platform9/app-controller/pkg/controller"
 	"github.com/platform9/app-controller/pkg/db"
 	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/metrics"
+	"github.com/platform9/app-controller/pkg/naming"
 	"github.com/platform9/app-controller/pkg/objects"
 	"github.com/platform9/app-controller/pkg/options"
 	"github.com/platform9/app-controller/pkg/util"
@@ -27,10 +25,9 @@ import (
 	"context"
 
 	"github.com/mitchellh/mapstructure"
-	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 // User information structure.
@@ -43,77 +40,93 @@ type UserInfo struct {
 	Exp      float64 `json:"exp"`
 }
 
-// New returns new API router for app-controller
-func New() *mux.Router {
+// New returns new API router for app-controller, serving the requests with s.
+func New(s *Server) *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc("/v1/apps", getApp).Methods("GET")
-	r.HandleFunc("/v1/apps/{name}", getAppByName).Methods("GET")
-	r.HandleFunc("/v1/apps", createApp).Methods("POST")
-	r.HandleFunc("/v1/apps/login", loginApp).Methods("POST")
-	r.HandleFunc("/v1/apps/{name}", deleteApp).Methods("DELETE")
+	r.HandleFunc("/v1/apps", s.getApp).Methods("GET")
+	r.HandleFunc("/v1/apps/{name}", s.getAppByName).Methods("GET")
+	r.HandleFunc("/v1/apps", s.createApp).Methods("POST")
+	r.HandleFunc("/v1/apps/login", s.loginApp).Methods("POST")
+	r.HandleFunc("/v1/apps/validate", s.validateApp).Methods("POST")
+	r.HandleFunc("/v1/apps/{name}", s.deleteApp).Methods("DELETE")
+	r.HandleFunc("/v1/apps/{name}/visibility", s.setAppVisibility).Methods("PUT")
+	r.HandleFunc("/v1/apps/{name}/deployments", s.getDeployments).Methods("GET")
+	r.HandleFunc("/v1/apps/{name}/deployments/{id}/redeploy", s.redeployApp).Methods("POST")
+	r.HandleFunc("/v1/apps/{name}/domains", s.getDomains).Methods("GET")
+	r.HandleFunc("/v1/apps/{name}/domains", s.addDomain).Methods("POST")
+	r.HandleFunc("/v1/apps/{name}/domains/{domain}/verify", s.verifyDomain).Methods("POST")
+	r.HandleFunc("/v1/apps/{name}/domains/{domain}", s.deleteDomain).Methods("DELETE")
+	r.HandleFunc("/v1/apply", s.applyApps).Methods("POST")
+	r.HandleFunc("/v1/export", s.exportApps).Methods("GET")
+	r.HandleFunc("/v1/quota", s.getQuota).Methods("GET")
+	r.HandleFunc("/v1/me", s.deleteMe).Methods("DELETE")
+	r.HandleFunc("/v1/plans", s.getPlans).Methods("GET")
+	r.HandleFunc("/v1/users", s.getUsers).Methods("GET")
+	r.HandleFunc("/v1/users/{id}/plan", s.setUserPlan).Methods("PUT")
+	r.HandleFunc("/v1/audit", s.getAuditEvents).Methods("GET")
+	r.HandleFunc("/v1/log/level", s.logLevel).Methods("GET", "PUT")
+	r.HandleFunc("/healthz", s.healthz).Methods("GET")
+	r.HandleFunc("/readyz", s.readyz).Methods("GET")
+	r.HandleFunc("/v1/openapi.json", s.getOpenAPI).Methods("GET")
+	r.Use(requestIDMiddleware, s.accessLogMiddleware, metricsMiddleware)
 
 	return r
 }
 
 // Fetch all the apps running for a particular user.
-func getApp(w http.ResponseWriter, r *http.Request) {
-	zap.S().Info("***** Get Apps *****")
-	// Validate the token, and get claims.
-	claims, err := ValidateToken(r)
-	if err != nil {
-		if findStrInSlice(err.Error(), util.ErrorsToken) {
-			zap.S().Errorf("Token validation Error: %v", err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		zap.S().Errorf("Error is: %v", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Fetch user information from claims
-	userInfo, err := GetUserClaims(claims)
-	if err != nil {
-		zap.S().Errorf("Failed to get user information. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+func (s *Server) getApp(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Get Apps *****")
 
-	//Get Namespace from DB
-	nameSpace, err := GetNamespace(*userInfo)
-	if err != nil {
-		zap.S().Errorf("Failed to get Namespace. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
 		return
 	}
+	nameSpace := userDB.Space
 
-	appList, err := knative.GetApps(util.Kubeconfig, nameSpace)
+	appList, err := s.Clients.GetApps(r.Context(), nameSpace)
 	if err != nil {
-		zap.S().Errorf("Error while listing app. Error: %v", err)
+		log.Errorf("Error while listing app. Error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	zap.S().Infof("App List, successful. Space: %v", nameSpace)
+	log.Infof("App List, successful. Space: %v", nameSpace)
 
 	data := []byte(appList)
 	w.WriteHeader(http.StatusOK)
 	if _, err = w.Write(data); err != nil {
-		zap.S().Errorf("Error while responding over http. Error: %v", err)
+		log.Errorf("Error while responding over http. Error: %v", err)
 	}
 }
 
 // App structure.
 type App struct {
-	Name  string `json:"name"`
-	Image string `json:"image"`
-	Port  string `json:"port"`
-	Envs  []struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	} `json:"envs"`
-	UserName string `json:"username"`
-	Password string `json:"password"`
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	Port     string `json:"port,omitempty"`
+	Envs     []Env  `json:"envs,omitempty"`
+	UserName string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Optional duration after which the app is deleted, e.g. "24h".
+	TTL string `json:"ttl,omitempty"`
+	// Either "public" (default) or "cluster-local".
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// Environment variable of an app.
+type Env struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Environment variables of the app container.
+func (app *App) envVars() []corev1.EnvVar {
+	envVars := []corev1.EnvVar{}
+	for _, env := range app.Envs {
+		envVars = append(envVars, corev1.EnvVar{Name: env.Key, Value: env.Value})
+	}
+	return envVars
 }
 
 /*
@@ -125,186 +138,169 @@ type App struct {
 	5. CreateApp using above fetched namespace.
 */
 
-func createApp(w http.ResponseWriter, r *http.Request) {
-	zap.S().Info("***** Create App *****")
-	// Validate the token, and get claims.
-	claims, err := ValidateToken(r)
-	if err != nil {
-		if findStrInSlice(err.Error(), util.ErrorsToken) {
-			zap.S().Errorf("Token validation Error: %v", err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		zap.S().Errorf("Error is: %v", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
+func (s *Server) createApp(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Create App *****")
 
-	// Fetch user information from claims
-	userInfo, err := GetUserClaims(claims)
-	if err != nil {
-		zap.S().Errorf("Failed to get user information. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
 		return
 	}
 
-	//Get Namespace from DB
-	nameSpace, err := GetNamespace(*userInfo)
-	if err != nil {
-		zap.S().Errorf("Failed to get Namespace. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	body, ok := readBody(w, r, maxAppBodySize)
+	if !ok {
 		return
 	}
-
 	app := App{}
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		zap.S().Errorf("Error while reading data in request body. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	err = json.Unmarshal(body, &app)
-	if err != nil {
-		zap.S().Errorf("Error while unmarhsalling request body data. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	schemaErrs, ok := decodeSchema(w, r, body, "App", &app)
+	if !ok {
 		return
 	}
+	auditAction(r, "create-app", app.Name)
 
-	zap.S().Debugf("app: %v\n", app)
+	// Keep the registry credentials and env values out of the logs.
+	log.Debugf("app: %v, image: %v, port: %v, envs: %v", app.Name, app.Image, app.Port, len(app.Envs))
 
-	envVars := []corev1.EnvVar{}
-	envVar := corev1.EnvVar{}
-	for _, env := range app.Envs {
-		envVar.Name = env.Key
-		envVar.Value = env.Value
-		envVars = append(envVars, envVar)
+	if errs := mergeFieldErrors(app.validate(""), schemaErrs); len(errs) > 0 {
+		log.Errorf("Invalid app %v. Errors: %v", app.Name, errs)
+		writeValidationError(w, errs)
+		return
 	}
 
-	// Use app name as a secret name.
-	err = knative.CreateApp(util.Kubeconfig, app.Name, nameSpace, app.Image, envVars, app.Port,
-		app.Name, app.UserName, app.Password)
-	if err != nil {
-		if err.Error() == util.MaxAppDeployError {
-			zap.S().Errorf("Maximum App deployed limit reached!! Namespace: %v", nameSpace)
-			w.WriteHeader(util.MaxAppDeployStatusCode)
-			return
-		} else if strings.Contains(err.Error(), util.Errors[0]) {
-			zap.S().Errorf("Error while creating app. Error: %v", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		zap.S().Errorf("Error while creating app. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-
+	if _, err := s.deploy(r, userDB, app, app.ttl(), false); err != nil {
+		writeDeployError(w, r, err)
 		return
 	}
 
-	zap.S().Infof("App Name: %v, Image: %v, created successfully in Space: %v", app.Name, app.Image, nameSpace)
+	log.Infof("App Name: %v, Image: %v, created successfully in Space: %v", app.Name, app.Image, userDB.Space)
 	w.WriteHeader(http.StatusOK)
 }
 
 // To get an app by name.
-func getAppByName(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getAppByName(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
 
-	zap.S().Info("***** Get App by name *****")
+	log.Info("***** Get App by name *****")
 
-	// Validate the token, and get claims.
-	claims, err := ValidateToken(r)
-	if err != nil {
-		if findStrInSlice(err.Error(), util.ErrorsToken) {
-			zap.S().Errorf("Token validation Error: %v", err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		zap.S().Errorf("Error is: %v", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	// Fetch user information from claims
-	userInfo, err := GetUserClaims(claims)
-	if err != nil {
-		zap.S().Errorf("Failed to get user information. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	//Get Namespace from DB
-	nameSpace, err := GetNamespace(*userInfo)
-	if err != nil {
-		zap.S().Errorf("Failed to get Namespace. Error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
 		return
 	}
+	nameSpace := userDB.Space
 
 	vars := mux.Vars(r)
 	appName := vars["name"]
 
-	appList, err := knative.GetAppByName(util.Kubeconfig, nameSpace, appName)
+	appList, err := s.Clients.GetAppByName(r.Context(), nameSpace, appName)
+	if apierrors.IsNotFound(err) {
+		log.Errorf("App %v not found. Error: %v", appName, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		zap.S().Errorf("Error while listing app. Error: %v", err)
+		log.Errorf("Error while listing app. Error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	zap.S().Infof("Get app by name successful. Name: %v, Space: %v", appName, nameSpace)
+	log.Infof("Get app by name successful. Name: %v, Space: %v", appName, nameSpace)
 
 	data := []byte(appList)
 	w.WriteHeader(http.StatusOK)
 	if _, err = w.Write(data); err != nil {
-		zap.S().Errorf("Error while responding over http. Error: %v", err)
+		log.Errorf("Error while responding over http. Error: %v", err)
 	}
 }
 
 // To delete an app.
-func deleteApp(w http.ResponseWriter, r *http.Request) {
-	zap.S().Info("***** Delete App *****")
+func (s *Server) deleteApp(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Delete App *****")
 
-	// Validate the token, and get claims.
-	claims, err := ValidateToken(r)
-	if err != nil {
-		if findStrInSlice(err.Error(), util.ErrorsToken) {
-			zap.S().Errorf("Token validation Error: %v", err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		zap.S().Errorf("Error is: %v", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
 		return
 	}
+	nameSpace := userDB.Space
 
-	// Fetch user information from claims
-	userInfo, err := GetUserClaims(claims)
-	if err != nil {
-		zap.S().Errorf("Failed to get user information. Error: %v", err)
+	vars := mux.Vars(r)
+	deleteAppName := vars["name"]
+	auditAction(r, "delete-app", deleteAppName)
+
+	log.Debugf("vars : %v", vars)
+
+	log.Infof("Name: %s, space: %s", deleteAppName, nameSpace)
+
+	// Delete the app with its custom domains.
+	errDel := controller.DeleteApp(r.Context(), s.Clients, s.Domains, userDB, deleteAppName)
+	if apierrors.IsNotFound(errDel) {
+		log.Errorf("App %v not found. Error: %v", deleteAppName, errDel)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if errDel != nil {
+		log.Errorf("Error while deleting app. Error: %v", errDel)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	//Get Namespace from DB
-	nameSpace, err := GetNamespace(*userInfo)
+	log.Infof("Delete app successful. Name: %v, Space: %v", deleteAppName, nameSpace)
+	w.WriteHeader(http.StatusOK)
+}
+
+// To get the quota usage and limits of a user.
+func (s *Server) getQuota(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Get Quota *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+
+	quota, err := s.Clients.GetQuota(r.Context(), userDB.Space, GetUserPlan(userDB))
 	if err != nil {
-		zap.S().Errorf("Failed to get Namespace. Error: %v", err)
+		log.Errorf("Error while getting quota. Error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	vars := mux.Vars(r)
-	deleteAppName := vars["name"]
+	log.Infof("Get quota successful. Space: %v", userDB.Space)
+	writeJSON(w, quota)
+}
 
-	zap.S().Debugf("vars : %v", vars)
+// To delete the account of the user with all the apps.
+func (s *Server) deleteMe(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Delete Account *****")
 
-	zap.S().Infof("Name: %s, space: %s", deleteAppName, nameSpace)
+	userInfo, ok := s.validateClaims(w, r)
+	if !ok {
+		return
+	}
 
-	errDel := knative.DeleteApp(util.Kubeconfig, nameSpace, deleteAppName)
-	if errDel != nil {
-		zap.S().Errorf("Error while deleting app. Error: %v", errDel)
+	// Answer 404 rather than the 500 of validateUser, as the account may be
+	// deleted already.
+	userDB, err := GetUser(r.Context(), s.Users, *userInfo)
+	if err != nil {
+		log.Errorf("Failed to get user. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setRequestUser(r, userDB.Owner(), userDB.Space)
+	if userDB.ID == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = controller.DeleteUser(r.Context(), s.Clients, s.store(), userDB, userInfo.Email)
+	if err != nil {
+		log.Errorf("Error while deleting account. Error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	zap.S().Infof("Delete app successful. Name: %v, Space: %v", deleteAppName, nameSpace)
+
+	log.Infof("Delete account successful. Space: %v", userDB.Space)
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -316,206 +312,279 @@ func deleteApp(w http.ResponseWriter, r *http.Request) {
 	4. If exists then check expiry and do necessary action if exipred.
 	5. Else, create a userNamespace and update the DB.
 */
-func loginApp(w http.ResponseWriter, r *http.Request) {
-	zap.S().Info("***** Login *****")
+func (s *Server) loginApp(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Login *****")
 
-	// Validate the token, and get claims.
-	claims, err := ValidateToken(r)
-	if err != nil {
-		if findStrInSlice(err.Error(), util.ErrorsToken) {
-			zap.S().Errorf("Token validation Error: %v", err)
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		zap.S().Errorf("Error is: %v", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	userInfo, ok := s.validateClaims(w, r)
+	if !ok {
 		return
 	}
 
-	// Fetch user information from claims
-	userInfo, err := GetUserClaims(claims)
+	// Check if user exists in DB.
+	que := s.Users
+	userDB, err := GetUser(r.Context(), s.Users, *userInfo)
 	if err != nil {
-		zap.S().Errorf("Failed to get user information. Error: %v", err)
+		log.Errorf("Get user info from DB. Error: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	//Database User object.
-	var userDB objects.User
-	que := db.Get()
-	// Check if user exists in DB.
-	var UserExists bool = false
-	if strings.Contains(userInfo.Sub, "github") {
-		errDB := que.GetUserByName(userInfo.NickName, &userDB)
-		if errDB != nil {
-			zap.S().Errorf("DB Error: %v", errDB)
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-		if userDB.Name == userInfo.NickName {
-			UserExists = true
-		}
-	} else {
-		errDB := que.GetUserByEmail(userInfo.Email, &userDB)
-		if errDB != nil {
-			zap.S().Errorf("Get user info from DB. Error: %v", errDB)
+	clientset := s.Clients.Kube
+
+	if userDB.ID != 0 {
+		setRequestUser(r, userDB.Owner(), userDB.Space)
+		if userDB.Space == "" {
+			log.Errorf("Failed to get Namespace of user %v", userDB.ID)
 			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
-		if userDB.Email == userInfo.Email {
-			UserExists = true
-		}
-	}
 
-	// If user doesn't exist in the database, then create a namespace for user.
-	var NameSpace, createdNS string
-	if !UserExists {
-		zap.S().Info("User doesn't exist's in DB, starting creation of namespace.")
-		if strings.Contains(userInfo.Sub, "github") {
-			NameSpace = userInfo.NickName + CreateRandomCode(6)
-		} else {
-			NameSpace = strings.Split(userInfo.Email, "@")[0] + CreateRandomCode(6)
+		// Record the identity provider of users added before it was tracked.
+		if userDB.Issuer == "" && GetIssuer(*userInfo) != "" {
+			userDB.Issuer = GetIssuer(*userInfo)
+			if errDB := que.SetUserIssuer(r.Context(), userDB); errDB != nil {
+				log.Errorf("Failed to update issuer of user. Error: %v", errDB)
+			}
 		}
 
-		// Check if namespace is valid. bcz only regex valid "^*[a-z0-9]([-a-z0-9]*[a-z0-9])?$"
-		if util.RegexValidate(NameSpace) {
-			zap.S().Debug("Its a Valid Namespace")
+		// Recreate the namespace if it was deleted from the cluster.
+		created, err := knative.EnsureNamespace(r.Context(), clientset, userDB.Space, userDB.Owner(),
+			knative.NamespaceLabels(userDB, options.GetPodSecurityLabels()))
+		if err != nil {
+			log.Errorf("Failed to ensure namespace %v. Error: %v", userDB.Space, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !created {
+			// Keep the owner and Pod Security labels up to date.
+			if _, err = knative.LabelNamespace(r.Context(), clientset, userDB.Space, userDB.Owner(),
+				knative.NamespaceLabels(userDB, options.GetPodSecurityLabels())); err != nil {
+				log.Errorf("Failed to label namespace %v. Error: %v", userDB.Space, err)
+			}
 		} else {
-			/* If namespace is invalid:
-			1. Remove all special characters other than [a-zA-Z0-9] from namespace.
-			2. Convert if any uppercase characters exists in string to lowercase.
-			*/
-			zap.S().Debug("Namespace given is not valid, so formating as per valid regex.")
-			NameSpace, err = RemoveSpecialChars(NameSpace)
-			if err != nil {
-				zap.S().Errorf("Notable to remove special characters from given string. Error: %v", err)
+			log.Infof("Recreated missing namespace %v", userDB.Space)
+			if err = s.isolateNamespace(r, userDB.Space); err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
+				return
 			}
-			NameSpace = NameSpace + CreateRandomCode(6)
 		}
 
-		// Create new namespace.
-		createdNS, err = CreateNamespace(NameSpace)
-		if err != nil {
-			zap.S().Errorf("Failed to create a namespace with name %v. Error: ", createdNS, err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		if errDB := que.TouchUser(r.Context(), userDB); errDB != nil {
+			log.Errorf("Failed to update last seen of user. Error: %v", errDB)
 		}
+		log.Infof("Login successful. Existing-User: %v, Email: %v, Space: %v", userInfo.NickName, userInfo.Email, userDB.Space)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-		zap.S().Infof("Successfully created namespace %v", createdNS)
+	// User doesn't exist in the database, so set up a namespace for user.
+	event := auditAction(r, "create-user", "")
+	var user objects.User
+	user.Name = userInfo.NickName
+	user.Email = userInfo.Email
+	user.Plan = options.GetDefaultPlanName()
+	user.Issuer = GetIssuer(*userInfo)
 
-		// Add Userinfo to DB.
-		var user objects.User
-		user.Name = userInfo.NickName
-		user.Email = userInfo.Email
-		user.Space = createdNS
+	// Resume with the namespace of a previous login that failed before adding the user to DB.
+	createdNS, err := knative.FindOwnedNamespace(r.Context(), clientset, user.Owner())
+	if err != nil {
+		log.Errorf("Failed to look up namespace of user. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-		errDB := que.AddUser(&user)
-		if errDB != nil {
-			zap.S().Errorf("Adding user information to DB. Error: %v", errDB)
+	if createdNS != "" {
+		log.Infof("Resuming setup with existing namespace %v", createdNS)
+		if err = knative.ReleaseNamespace(r.Context(), clientset, createdNS); err != nil {
+			log.Errorf("Failed to release namespace %v. Error: %v", createdNS, err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		zap.S().Infof("Added user information to DB. Name: %v, Email: %v, Space: %v", userInfo.NickName, userInfo.Email, createdNS)
 	} else {
-		//Get Namespace from DB
-		nameSpace, err := GetNamespace(*userInfo)
+		log.Info("User doesn't exist's in DB, starting creation of namespace.")
+		base := strings.Split(userInfo.Email, "@")[0]
+		if strings.Contains(userInfo.Sub, "github") {
+			base = userInfo.NickName
+		}
+
+		// Create new namespace.
+		createdNS, err = CreateNamespace(r.Context(), clientset, base, user.Owner(),
+			knative.NamespaceLabels(&user, options.GetPodSecurityLabels()))
 		if err != nil {
-			zap.S().Errorf("Failed to get Namespace. Error: %v", err)
+			log.Errorf("Failed to create a namespace for %v. Error: %v", base, err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		zap.S().Infof("Login successful. Existing-User: %v, Email: %v, Space: %v", userInfo.NickName, userInfo.Email, nameSpace)
+
+		log.Infof("Successfully created namespace %v", createdNS)
+	}
+
+	setRequestUser(r, user.Owner(), createdNS)
+
+	// Deny ingress from the other tenants before the user can deploy apps.
+	if err = s.isolateNamespace(r, createdNS); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Add Userinfo to DB.
+	user.Space = createdNS
+	errDB := que.AddUser(r.Context(), &user)
+	if errDB != nil {
+		log.Errorf("Adding user information to DB. Error: %v", errDB)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Infof("Added user information to DB. Name: %v, Email: %v, Space: %v", userInfo.NickName, userInfo.Email, createdNS)
+
+	// Label the namespace with the ID of the user, now that it is known.
+	if userDB, err = GetUser(r.Context(), s.Users, *userInfo); err == nil && userDB.ID != 0 {
+		event.Target = fmt.Sprintf("%d", userDB.ID)
+		err = s.labelNamespace(r.Context(), userDB)
+	}
+	if err != nil {
+		log.Errorf("Failed to label namespace %v. Error: %v", createdNS, err)
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-// To create a new namespace as part of login.
-func CreateNamespace(nameSpace string) (string, error) {
-	config, err := clientcmd.BuildConfigFromFlags("", util.Kubeconfig)
-	if err != nil {
-		zap.S().Errorf("Error building config: %v", err)
-		return "", err
+// To create a new namespace as part of login, named after the base, e.g.
+// the nickname or email prefix of the user.
+func CreateNamespace(ctx context.Context, clientset kubernetes.Interface, base string, owner string,
+	labels map[string]string) (string, error) {
+	namer := naming.New(options.GetNamespacePrefix(), options.GetNamespaceMaxAttempts())
+	return namer.Create(base, owner, func(name string) error {
+		_, err := clientset.CoreV1().Namespaces().Create(ctx, knative.NewNamespaceObj(name, owner, labels), metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			zap.S().Debugf("Namespace %v already exists, trying another name", name)
+		} else if err != nil {
+			zap.S().Errorf("Failed to create a new namespace %v. Error: %v", name, err)
+		}
+		return err
+	})
+}
+
+// Apply the tenant NetworkPolicy to the namespace of a user. Without the RBAC
+// to manage NetworkPolicies, the login goes on and reconcile reports the
+// namespace missing the policy.
+func (s *Server) isolateNamespace(r *http.Request, space string) error {
+	log := requestLogger(r)
+	err := controller.NetworkIsolationFromOptions().Isolate(r.Context(), s.Clients.Kube, space)
+	if apierrors.IsForbidden(err) {
+		log.Warnf("Not allowed to isolate namespace %v, left to reconcile. Error: %v", space, err)
+		return nil
 	}
-	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		zap.S().Errorf("Error creating kubernetes config: %v", err)
-		return "", err
+		log.Errorf("Failed to isolate namespace %v. Error: %v", space, err)
 	}
+	return err
+}
 
-	// Check if the creating namespace already exist.
-	_, errGetNs := clientset.CoreV1().Namespaces().Get(context.TODO(), nameSpace, metav1.GetOptions{})
-	if errGetNs == nil {
-		nameSpace = nameSpace + CreateRandomCode(4)
-		zap.S().Debugf("Namespace already exists. Generating new namespace: %v", nameSpace)
-	}
+// Label the namespace of a user with its owner and Pod Security labels.
+func (s *Server) labelNamespace(ctx context.Context, user *objects.User) error {
+	_, err := knative.LabelNamespace(ctx, s.Clients.Kube, user.Space, user.Owner(),
+		knative.NamespaceLabels(user, options.GetPodSecurityLabels()))
+	return err
+}
 
-	// Namespace metaobject.
-	nsName := &v1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: nameSpace,
-		},
+// GetIssuer returns the identity provider of the user, from the subject of
+// the token, e.g. github for "github|1234".
+func GetIssuer(userInfo UserInfo) string {
+	if i := strings.Index(userInfo.Sub, "|"); i > 0 {
+		return userInfo.Sub[:i]
 	}
+	return ""
+}
 
-	//Create a namespace.
-	_, errCreate := clientset.CoreV1().Namespaces().Create(context.Background(), nsName, metav1.CreateOptions{})
-	if errCreate != nil {
-		zap.S().Errorf("Failed to create a new namespace %v. Error: %v", nameSpace, errCreate)
-		return "", errCreate
+// Owner returns the identity of the user recorded in logs and audit events,
+// the email or the nickname if there is none.
+func (u UserInfo) Owner() string {
+	if u.Email != "" {
+		return u.Email
 	}
-	return nameSpace, nil
+	return u.NickName
 }
 
-// Get the namespace for user, from DB.
-func GetNamespace(userInfo UserInfo) (string, error) {
+// Get the user information from the store.
+func GetUser(ctx context.Context, que db.UserStore, userInfo UserInfo) (*objects.User, error) {
 	//Database User object.
 	var userDB objects.User
-	que := db.Get()
 	if strings.Contains(userInfo.Sub, "github") {
-		errDB := que.GetUserByName(userInfo.NickName, &userDB)
+		errDB := que.GetUserByName(ctx, userInfo.NickName, &userDB)
 		if errDB != nil {
 			zap.S().Errorf("DB Error: %v", errDB)
-			return "", fmt.Errorf("Failed to get Namespace. Error: %v", errDB)
-		}
-
-		if userDB.Space != "" {
-			zap.S().Debugf("Namespace found is: %v", userDB.Space)
-			return userDB.Space, nil
+			return nil, fmt.Errorf("Failed to get user. Error: %v", errDB)
 		}
 	} else {
-		errDB := que.GetUserByEmail(userInfo.Email, &userDB)
+		errDB := que.GetUserByEmail(ctx, userInfo.Email, &userDB)
 		if errDB != nil {
 			zap.S().Errorf("Get user info from DB. Error: %v", errDB)
-			return "", fmt.Errorf("Failed to get Namespace. Error: %v", errDB)
+			return nil, fmt.Errorf("Failed to get user. Error: %v", errDB)
 		}
+	}
+	return &userDB, nil
+}
 
-		if userDB.Space != "" {
-			zap.S().Debugf("Namespace found is: %v", userDB.Space)
-			return userDB.Space, nil
+// Validate the token of the request and get the user information from its
+// claims, responding with the error status otherwise.
+func (s *Server) validateClaims(w http.ResponseWriter, r *http.Request) (*UserInfo, bool) {
+	log := requestLogger(r)
+	// Validate the token, and get claims.
+	claims, err := s.ValidateToken(r)
+	if err != nil {
+		if findStrInSlice(err.Error(), util.ErrorsToken) {
+			log.Errorf("Token validation Error: %v", err)
+			w.WriteHeader(http.StatusForbidden)
+			return nil, false
 		}
+		log.Errorf("Error is: %v", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
 	}
-	return "", fmt.Errorf("Failed to get Namespace")
+
+	// Fetch user information from claims
+	userInfo, err := GetUserClaims(claims)
+	if err != nil {
+		log.Errorf("Failed to get user information. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	setRequestUser(r, userInfo.Owner(), "")
+	return userInfo, true
 }
 
-// Create a random code of given length.
-func CreateRandomCode(lenCode int) string {
-	var letter = []rune(util.AllCharSet)
+// Validate the token of the request and get the user from DB, recording
+// that they were seen, responding with the error status otherwise.
+func (s *Server) validateUser(w http.ResponseWriter, r *http.Request) (*UserInfo, *objects.User, bool) {
+	log := requestLogger(r)
+	userInfo, ok := s.validateClaims(w, r)
+	if !ok {
+		return nil, nil, false
+	}
 
-	rand.Seed(time.Now().UnixNano())
-	code := make([]rune, lenCode)
-	for i := range code {
-		code[i] = letter[rand.Intn(len(letter))]
+	//Get user from DB
+	userDB, err := GetUser(r.Context(), s.Users, *userInfo)
+	if err != nil || userDB.Space == "" {
+		log.Errorf("Failed to get Namespace. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	setRequestUser(r, userDB.Owner(), userDB.Space)
+	// Keep active users from being collected as inactive.
+	if errDB := s.Users.TouchUser(r.Context(), userDB); errDB != nil {
+		log.Errorf("Failed to update last seen of user. Error: %v", errDB)
 	}
-	return string(code)
+	return userInfo, userDB, true
 }
 
-// Remove all special characters and convert to lowercase alphanumeric string.
-func RemoveSpecialChars(specialChar string) (string, error) {
-	regex, err := regexp.Compile(util.NoSpecialChar)
-	if err != nil {
-		zap.S().Errorf("Error while removing special characters: %v", err)
-		return "", fmt.Errorf("%v\n", err)
+// Get the plan of a user, with the per-user maximum apps override applied.
+func GetUserPlan(user *objects.User) objects.Plan {
+	plan := options.GetPlan(user.Plan)
+	if user.MaxApps > 0 {
+		plan.MaxApps = user.MaxApps
 	}
-	formattedString := regex.ReplaceAllString(specialChar, "")
-	return strings.ToLower(formattedString), nil
+	return plan
 }
 
 // Get the UserInfo from claims.
@@ -524,43 +593,49 @@ func GetUserClaims(claims jwt.Claims) (*UserInfo, error) {
 	var user UserInfo
 	errStru := mapstructure.Decode(claims, &user)
 	if errStru != nil {
-		zap.S().Error("Failed to convert map to struct. Error: %v", errStru)
+		zap.S().Errorf("Failed to convert map to struct. Error: %v", errStru)
 		return &UserInfo{}, fmt.Errorf("%v", errStru)
 	}
 
-	zap.S().Infof("The User info is %+v", user)
+	zap.S().Debugf("User info of subject %v", user.Sub)
 	return &user, nil
 }
 
 // Fetch the token and validate it.
 func ValidateToken(r *http.Request) (jwt.Claims, error) {
+	log := requestLogger(r)
 
 	// Fetch the token.
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
+		metrics.AuthFailure("missing_token")
 		return jwt.MapClaims{}, fmt.Errorf(util.ErrorsToken[2])
 	}
 
 	bearerToken := strings.Split(authHeader, "Bearer ")
 	if len(bearerToken) != 2 {
+		metrics.AuthFailure("malformed_header")
 		return jwt.MapClaims{}, fmt.Errorf(util.ErrorsToken[2])
 	}
 
 	// Create the JWKS from the resource at the given URL.
 	jwks, err := keyfunc.Get(options.GetJWKSURL(), keyfunc.Options{})
 	if err != nil {
-		zap.S().Errorf("Failed to create JWKS from URL.\nError: %s", err.Error())
+		log.Errorf("Failed to create JWKS from URL.\nError: %s", err.Error())
+		metrics.AuthFailure("jwks_unavailable")
 		return jwt.MapClaims{}, fmt.Errorf("Failed to create JWKS from URL. Error: %s", err.Error())
 	}
 
 	// Parse the token.
 	token, err := jwt.Parse(bearerToken[1], jwks.Keyfunc)
 	if err != nil {
-		zap.S().Errorf("Error is %v\n", err)
+		log.Errorf("Error is %v\n", err)
 		if err.Error() == util.ErrorsToken[0] {
+			metrics.AuthFailure("expired")
 			return jwt.MapClaims{}, fmt.Errorf(util.ErrorsToken[0])
 		}
-		zap.S().Errorf("Falied to parse token. Error: %s", err.Error())
+		log.Errorf("Falied to parse token. Error: %s", err.Error())
+		metrics.AuthFailure("invalid_token")
 		return jwt.MapClaims{}, fmt.Errorf("Falied to parse token. Error: %s", err.Error())
 	}
 
@@ -569,6 +644,7 @@ func ValidateToken(r *http.Request) (jwt.Claims, error) {
 
 	// Audiance validation i.e if audiance == auth0 clientID
 	if !token.Valid || claims["aud"] != options.GetAuth0ClientId() {
+		metrics.AuthFailure("invalid_audience")
 		return jwt.MapClaims{}, fmt.Errorf(util.ErrorsToken[1])
 	}
 
@@ -584,3 +660,57 @@ func findStrInSlice(msg string, list []string) bool {
 	}
 	return false
 }
+
+// Visibility request structure.
+type VisibilityRequest struct {
+	Visibility string `json:"visibility"`
+}
+
+// To make an existing app public or cluster-local.
+func (s *Server) setAppVisibility(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Set App Visibility *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+	appName := mux.Vars(r)["name"]
+	event := auditAction(r, "set-visibility", appName)
+
+	body, ok := readBody(w, r, maxAppBodySize)
+	if !ok {
+		return
+	}
+	visibilityReq := VisibilityRequest{}
+	if !decodeJSON(w, r, body, &visibilityReq) {
+		return
+	}
+	if visibilityReq.Visibility == "" || !knative.IsVisibility(visibilityReq.Visibility) {
+		log.Errorf("Invalid visibility %q", visibilityReq.Visibility)
+		http.Error(w, "Invalid visibility "+visibilityReq.Visibility, http.StatusBadRequest)
+		return
+	}
+
+	event.Detail = "visibility " + visibilityReq.Visibility
+
+	_, err := s.Clients.GetAppByName(r.Context(), userDB.Space, appName)
+	if apierrors.IsNotFound(err) {
+		log.Errorf("App %v not found. Error: %v", appName, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("Error while getting app %v. Error: %v", appName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err = s.Clients.SetVisibility(r.Context(), userDB.Space, appName, visibilityReq.Visibility); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("App %v visibility set to %v. Space: %v", appName, visibilityReq.Visibility, userDB.Space)
+	w.WriteHeader(http.StatusOK)
+}
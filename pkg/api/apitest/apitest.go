@@ -0,0 +1,79 @@
+// Package apitest provides the fixtures shared by the tests of the API and
+// of its clients: the options, the token of the test users, and the users in
+// memory with fake clusters.
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+	"github.com/platform9/app-controller/pkg/util"
+)
+
+// Email of the admin of the tests, logged in with the token "admin".
+const AdminEmail = "admin@example.com"
+
+// Plans of the tests, "free" being the default.
+func Plans() map[string]objects.Plan {
+	return map[string]objects.Plan{
+		"free": {MaxApps: 2, MaxScale: 1, MaxDomains: 1},
+		"pro":  {MaxApps: 10, MaxScale: 5, MaxDomains: 10, Retention: time.Hour},
+	}
+}
+
+// SetOptions sets the plans and admin of the tests, restoring the default
+// options once the test is done.
+func SetOptions(t testing.TB) {
+	config := options.Default()
+	config.DefaultPlan = "free"
+	config.Plans = Plans()
+	config.Admin.Emails = []string{AdminEmail}
+	options.Set(config)
+	t.Cleanup(func() { options.Set(options.Default()) })
+}
+
+// Token validates the token of the test user named by the request, e.g.
+// "Bearer jdoe" for jdoe@example.com, and fails without a token.
+func Token(r *http.Request) (jwt.Claims, error) {
+	name := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if name == "" {
+		return jwt.MapClaims{}, fmt.Errorf(util.ErrorsToken[2])
+	}
+	return jwt.MapClaims{
+		"sub":      "auth0|" + name,
+		"email":    name + "@example.com",
+		"nickname": name,
+	}, nil
+}
+
+// Fakes are the dependencies of the API server in the tests.
+type Fakes struct {
+	Store   *db.MemoryStore
+	Kube    *k8sfake.Clientset
+	Serving *servingfake.Clientset
+}
+
+// NewFakes returns an empty store and clusters.
+func NewFakes() *Fakes {
+	return &Fakes{
+		Store:   db.NewMemoryStore(),
+		Kube:    k8sfake.NewSimpleClientset(),
+		Serving: servingfake.NewSimpleClientset(),
+	}
+}
+
+// Clients of the fake clusters.
+func (f *Fakes) Clients() *knative.Clients {
+	return &knative.Clients{Kube: f.Kube, Serving: f.Serving}
+}
@@ -0,0 +1,350 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/platform9/app-controller/pkg/controller"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+)
+
+// Actions of an apply on the apps.
+const (
+	ApplyCreate    = "create"
+	ApplyUpdate    = "update"
+	ApplyDelete    = "delete"
+	ApplyUnchanged = "unchanged"
+)
+
+// Manifest of the apps of a namespace, in YAML or JSON.
+type Manifest struct {
+	Apps []App `json:"apps"`
+}
+
+// Change of a field of an app, e.g. "image" or "envs.MODE".
+type FieldChange struct {
+	Field   string `json:"field"`
+	Current string `json:"current,omitempty"`
+	Desired string `json:"desired,omitempty"`
+}
+
+// AppDiff is the action of an apply on an app, with the changed fields.
+type AppDiff struct {
+	Name    string        `json:"name"`
+	Action  string        `json:"action"`
+	Changes []FieldChange `json:"changes,omitempty"`
+	// ID of the deployment of a created or updated app.
+	Deployment int    `json:"deployment,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Apply response structure.
+type ApplyResponse struct {
+	DryRun bool      `json:"dryRun"`
+	Apps   []AppDiff `json:"apps"`
+}
+
+// Spec of the app deployed as the knative service. The registry credentials
+// are not part of it.
+func appFromService(service servingv1.Service) App {
+	app := App{
+		Name:       service.Name,
+		Visibility: knative.Visibility(&service),
+		TTL:        service.Annotations[util.TTLAnnotation],
+	}
+	if len(service.Spec.Template.Spec.Containers) == 0 {
+		return app
+	}
+	container := service.Spec.Template.Spec.Containers[0]
+	app.Image = container.Image
+	if len(container.Ports) > 0 {
+		app.Port = strconv.Itoa(int(container.Ports[0].ContainerPort))
+	}
+	for _, env := range container.Env {
+		app.Envs = append(app.Envs, Env{Key: env.Name, Value: env.Value})
+	}
+	return app
+}
+
+// Ttl of the app in a canonical form, e.g. "24h0m0s" for "24h", to compare
+// durations written differently.
+func appTTL(app App) string {
+	if app.TTL == "" {
+		return ""
+	}
+	return app.ttl().String()
+}
+
+// Visibility of the app, empty being public.
+func appVisibility(app App) string {
+	if app.Visibility == "" {
+		return knative.VisibilityPublic
+	}
+	return app.Visibility
+}
+
+// Changes of the fields of the current app to the desired one, the
+// environment variables compared by key.
+func diffApp(current App, desired App) []FieldChange {
+	changes := []FieldChange{}
+	add := func(field string, currentValue string, desiredValue string) {
+		if currentValue != desiredValue {
+			changes = append(changes, FieldChange{Field: field, Current: currentValue, Desired: desiredValue})
+		}
+	}
+	add("image", current.Image, desired.Image)
+	add("port", current.Port, desired.Port)
+	add("visibility", appVisibility(current), appVisibility(desired))
+	add("ttl", appTTL(current), appTTL(desired))
+
+	currentEnvs := map[string]string{}
+	for _, env := range current.Envs {
+		currentEnvs[env.Key] = env.Value
+	}
+	for _, env := range desired.Envs {
+		value, ok := currentEnvs[env.Key]
+		if !ok || value != env.Value {
+			changes = append(changes, FieldChange{Field: "envs." + env.Key, Current: value, Desired: env.Value})
+		}
+		delete(currentEnvs, env.Key)
+	}
+	removed := []string{}
+	for key := range currentEnvs {
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+	for _, key := range removed {
+		changes = append(changes, FieldChange{Field: "envs." + key, Current: currentEnvs[key]})
+	}
+	return changes
+}
+
+// Validate the apps of the manifest, each named once.
+func (manifest *Manifest) validate() []FieldError {
+	errs := []FieldError{}
+	names := map[string]int{}
+	for i := range manifest.Apps {
+		path := fmt.Sprintf("apps[%d].", i)
+		app := &manifest.Apps[i]
+		errs = append(errs, app.validate(path)...)
+		if first, ok := names[app.Name]; ok && app.Name != "" {
+			errs = append(errs, FieldError{Field: path + "name", Message: fmt.Sprintf("duplicates apps[%d].name %v", first, app.Name)})
+			continue
+		}
+		names[app.Name] = i
+	}
+	return errs
+}
+
+// Actions to apply the manifest over the current apps, in the order of the
+// manifest and then the apps to delete by name if prune is set.
+func planApply(current []App, manifest Manifest, prune bool) []AppDiff {
+	currentApps := map[string]App{}
+	for _, app := range current {
+		currentApps[app.Name] = app
+	}
+
+	diffs := []AppDiff{}
+	for _, desired := range manifest.Apps {
+		app, ok := currentApps[desired.Name]
+		delete(currentApps, desired.Name)
+		if !ok {
+			diffs = append(diffs, AppDiff{Name: desired.Name, Action: ApplyCreate, Changes: diffApp(App{}, desired)})
+			continue
+		}
+		diff := AppDiff{Name: desired.Name, Action: ApplyUnchanged, Changes: diffApp(app, desired)}
+		if len(diff.Changes) > 0 {
+			diff.Action = ApplyUpdate
+		}
+		diffs = append(diffs, diff)
+	}
+
+	if prune {
+		removed := []string{}
+		for name := range currentApps {
+			removed = append(removed, name)
+		}
+		sort.Strings(removed)
+		for _, name := range removed {
+			diffs = append(diffs, AppDiff{Name: name, Action: ApplyDelete, Changes: diffApp(currentApps[name], App{})})
+		}
+	}
+	return diffs
+}
+
+// Get a boolean query parameter of the request, false if not set.
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// Current apps of the namespace of the user.
+func (s *Server) currentApps(r *http.Request, userDB *objects.User) ([]App, error) {
+	services, err := s.Clients.ListApps(r.Context(), userDB.Space)
+	if err != nil {
+		return nil, err
+	}
+	apps := []App{}
+	for _, service := range services {
+		apps = append(apps, appFromService(service))
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return apps, nil
+}
+
+/*
+-- Apply
+1. Parse and check the manifest of the apps.
+2. Compare it with the apps of the namespace of the user.
+3. With dryRun, respond with the actions and changes of each app.
+4. Otherwise delete the apps missing from the manifest if prune is set.
+5. Update the changed apps, then create the new ones.
+6. Respond with the outcome of each app, 500 if any failed.
+*/
+func (s *Server) applyApps(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Apply *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+
+	prune, err := queryBool(r, "prune")
+	if err != nil {
+		http.Error(w, "Invalid prune "+r.URL.Query().Get("prune"), http.StatusBadRequest)
+		return
+	}
+	dryRun, err := queryBool(r, "dryRun")
+	if err != nil {
+		http.Error(w, "Invalid dryRun "+r.URL.Query().Get("dryRun"), http.StatusBadRequest)
+		return
+	}
+
+	body, ok := readBody(w, r, maxManifestBodySize)
+	if !ok {
+		return
+	}
+	data, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		log.Errorf("Invalid manifest. Error: %v", err)
+		http.Error(w, "Invalid manifest", http.StatusBadRequest)
+		return
+	}
+	manifest := Manifest{}
+	schemaErrs, ok := decodeSchema(w, r, data, "Manifest", &manifest)
+	if !ok {
+		return
+	}
+	if errs := mergeFieldErrors(manifest.validate(), schemaErrs); len(errs) > 0 {
+		log.Errorf("Invalid manifest. Errors: %v", errs)
+		writeValidationError(w, errs)
+		return
+	}
+
+	current, err := s.currentApps(r, userDB)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	diffs := planApply(current, manifest, prune)
+	if dryRun {
+		writeJSON(w, ApplyResponse{DryRun: true, Apps: diffs})
+		return
+	}
+
+	desired := map[string]App{}
+	for _, app := range manifest.Apps {
+		desired[app.Name] = app
+	}
+
+	// Delete first to free the quota for the new apps.
+	counts := map[string]int{}
+	failed := false
+	for _, action := range []string{ApplyDelete, ApplyUpdate, ApplyCreate} {
+		for i := range diffs {
+			diff := &diffs[i]
+			if diff.Action != action {
+				continue
+			}
+			if action == ApplyDelete {
+				err = controller.DeleteApp(r.Context(), s.Clients, s.Domains, userDB, diff.Name)
+			} else {
+				var deployment *objects.Deployment
+				app := desired[diff.Name]
+				deployment, err = s.deploy(r, userDB, app, app.ttl(), action == ApplyUpdate)
+				diff.Deployment = deployment.ID
+			}
+			if err != nil {
+				log.Errorf("Failed to %v app %v. Error: %v", action, diff.Name, err)
+				diff.Error = err.Error()
+				failed = true
+				continue
+			}
+			counts[action]++
+		}
+	}
+
+	event := auditAction(r, "apply", "")
+	event.Detail = fmt.Sprintf("%d created, %d updated, %d deleted", counts[ApplyCreate], counts[ApplyUpdate], counts[ApplyDelete])
+
+	status := http.StatusOK
+	if failed {
+		status = http.StatusInternalServerError
+	}
+	log.Infof("Apply done, %v. Space: %v", event.Detail, userDB.Space)
+	writeJSONStatus(w, status, ApplyResponse{Apps: diffs})
+}
+
+// To export the apps of the namespace of the user as a manifest, in JSON
+// or with format=yaml in YAML.
+func (s *Server) exportApps(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Export *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "json" && format != "yaml" {
+		http.Error(w, "Invalid format "+format, http.StatusBadRequest)
+		return
+	}
+
+	apps, err := s.currentApps(r, userDB)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	manifest := Manifest{Apps: apps}
+
+	log.Infof("Export successful. Space: %v", userDB.Space)
+	if format != "yaml" {
+		writeJSON(w, manifest)
+		return
+	}
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		log.Errorf("Error while marshalling the manifest. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(data); err != nil {
+		log.Errorf("Error while responding over http. Error: %v", err)
+	}
+}
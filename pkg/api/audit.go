@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+const (
+	// Audit events returned when no limit is requested.
+	defaultAuditLimit = 100
+	// Maximum audit events returned at once.
+	maxAuditLimit = 1000
+)
+
+// Get the audit filter from the query parameters of the request.
+func auditFilter(r *http.Request) (objects.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := objects.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Space:  query.Get("space"),
+		Limit:  defaultAuditLimit,
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, err
+		}
+		filter.Since = t
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return filter, strconv.ErrSyntax
+		}
+		if n > maxAuditLimit {
+			n = maxAuditLimit
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// To list the audit events of the namespace of the user, or with all=true
+// the audit events of every user, admin only.
+func (s *Server) getAuditEvents(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Get Audit Events *****")
+
+	filter, err := auditFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid since or limit", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("all") == "true" {
+		if _, ok := s.validateAdmin(w, r); !ok {
+			return
+		}
+	} else {
+		_, userDB, ok := s.validateUser(w, r)
+		if !ok {
+			return
+		}
+		// Users only see the events of their namespace, including the
+		// actions of the reaper and of the admins, which they can filter.
+		filter.Space = userDB.Space
+	}
+
+	events := []objects.AuditEvent{}
+	if err = s.Audit.GetAuditEvents(r.Context(), filter, &events); err != nil {
+		log.Errorf("Failed to get audit events from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, events)
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+)
+
+// Deployment response structure, with the spec deployed.
+type DeploymentResponse struct {
+	objects.Deployment
+	Spec *App `json:"spec"`
+}
+
+func newDeploymentResponse(deployment objects.Deployment) DeploymentResponse {
+	resp := DeploymentResponse{Deployment: deployment}
+	if deployment.Spec != "" {
+		resp.Spec = &App{}
+		if err := json.Unmarshal([]byte(deployment.Spec), resp.Spec); err != nil {
+			resp.Spec = nil
+		} else {
+			resp.Spec.Envs = redactEnvs(resp.Spec.Envs)
+		}
+	}
+	return resp
+}
+
+// Redeploy request structure, the registry credentials of a private image
+// and the values of the environment variables, as they are not recorded
+// with the deployments.
+type RedeployRequest struct {
+	UserName string `json:"username"`
+	Password string `json:"password"`
+	Envs     []Env  `json:"envs,omitempty"`
+}
+
+// Environment variables without their values, which may hold secrets.
+func redactEnvs(envs []Env) []Env {
+	redacted := []Env{}
+	for _, env := range envs {
+		redacted = append(redacted, Env{Key: env.Key})
+	}
+	return redacted
+}
+
+// Spec of the app recorded with its deployment, without the registry password
+// and the values of the environment variables.
+func deploymentSpec(app App) string {
+	app.Password = ""
+	app.Envs = redactEnvs(app.Envs)
+	data, err := json.Marshal(app)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Restore the values of the environment variables of a redeployed spec from
+// the request, or else from the running app, returning the variables left
+// without a value.
+func (s *Server) restoreEnvs(r *http.Request, space string, app *App, requested []Env) ([]FieldError, error) {
+	current := map[string]string{}
+	service, err := s.Clients.ServingClient(space).GetService(r.Context(), app.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		for _, env := range appFromService(*service).Envs {
+			current[env.Key] = env.Value
+		}
+	}
+	values := map[string]string{}
+	for _, env := range requested {
+		values[env.Key] = env.Value
+	}
+
+	errs := []FieldError{}
+	for i := range app.Envs {
+		env := &app.Envs[i]
+		if value, ok := values[env.Key]; ok {
+			env.Value = value
+		} else if value, ok := current[env.Key]; ok && env.Value == "" {
+			env.Value = value
+		} else if env.Value == "" {
+			errs = append(errs, FieldError{Field: fmt.Sprintf("envs[%d].value", i),
+				Message: fmt.Sprintf("is not recorded for %v, send it with the redeploy", env.Key)})
+		}
+	}
+	return errs, nil
+}
+
+// Name of the revision created by a deployment, after the app and the
+// deployment ID. Empty to let knative name it if too long.
+func revisionName(app string, deploymentID int) string {
+	if deploymentID == 0 {
+		return ""
+	}
+	name := fmt.Sprintf("%s-%05d", app, deploymentID)
+	if len(validation.IsDNS1123Label(name)) > 0 {
+		return ""
+	}
+	return name
+}
+
+// Deploy the app in the namespace of the user, updating it if it exists and
+// update is set, and record the deployment. Failing to record it doesn't
+// fail the deployment.
+func (s *Server) deploy(r *http.Request, userDB *objects.User, app App, ttl time.Duration, update bool) (*objects.Deployment, error) {
+	log := requestLogger(r)
+	ctx := r.Context()
+
+	deployment := &objects.Deployment{
+		UserID: userDB.ID,
+		Space:  userDB.Space,
+		App:    app.Name,
+		Actor:  userDB.Owner(),
+		Spec:   deploymentSpec(app),
+	}
+	if err := s.Deployments.AddDeployment(ctx, deployment); err != nil {
+		log.Errorf("Failed to record deployment of app %v. Error: %v", app.Name, err)
+	}
+	revision := revisionName(app.Name, deployment.ID)
+
+	exists := false
+	var err error
+	if update {
+		exists, err = s.Clients.AppExists(ctx, userDB.Space, app.Name)
+	}
+	// Use app name as a secret name.
+	if err == nil && exists {
+		err = s.Clients.UpdateApp(ctx, app.Name, userDB.Space, app.Image, app.envVars(), app.Port,
+			app.Name, app.UserName, app.Password, GetUserPlan(userDB), ttl, app.Visibility, revision)
+	} else if err == nil {
+		err = s.Clients.CreateApp(ctx, app.Name, userDB.Space, app.Image, app.envVars(), app.Port,
+			app.Name, app.UserName, app.Password, GetUserPlan(userDB), ttl, app.Visibility, revision)
+	}
+
+	deployment.Outcome = objects.DeploymentSucceeded
+	deployment.Revision = revision
+	if err != nil {
+		deployment.Outcome = objects.DeploymentFailed
+		deployment.Revision = ""
+		deployment.Error = err.Error()
+	}
+	if deployment.ID != 0 {
+		if errDB := s.Deployments.FinishDeployment(ctx, deployment); errDB != nil {
+			log.Errorf("Failed to record outcome of deployment %v. Error: %v", deployment.ID, errDB)
+		}
+	}
+	return deployment, err
+}
+
+// Respond with the status of a failed deployment.
+func writeDeployError(w http.ResponseWriter, r *http.Request, err error) {
+	log := requestLogger(r)
+	if err.Error() == util.MaxAppDeployError {
+		log.Errorf("Maximum App deployed limit reached!! Error: %v", err)
+		w.WriteHeader(util.MaxAppDeployStatusCode)
+		return
+	} else if strings.Contains(err.Error(), util.Errors[0]) {
+		log.Errorf("Error while creating app. Error: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Errorf("Error while creating app. Error: %v", err)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// To list the deployments of an app, the latest first.
+func (s *Server) getDeployments(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Get Deployments *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+
+	deployments := []objects.Deployment{}
+	if err := s.Deployments.GetDeploymentsByApp(r.Context(), userDB.ID, mux.Vars(r)["name"], &deployments); err != nil {
+		log.Errorf("Failed to get deployments from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := []DeploymentResponse{}
+	for _, deployment := range deployments {
+		resp = append(resp, newDeploymentResponse(deployment))
+	}
+	writeJSON(w, resp)
+}
+
+/*
+-- Redeploy app
+1. Find the past deployment of the app of the user.
+2. Restore the values of its environment variables, 422 if any is missing.
+3. Deploy its spec again, updating the app if it exists or creating it.
+4. Record the new deployment, and respond with it.
+*/
+func (s *Server) redeployApp(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Redeploy App *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+	appName := mux.Vars(r)["name"]
+	event := auditAction(r, "redeploy-app", appName)
+	event.Detail = "deployment " + mux.Vars(r)["id"]
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid deployment id", http.StatusBadRequest)
+		return
+	}
+
+	past := objects.Deployment{}
+	if err = s.Deployments.GetDeployment(r.Context(), id, &past); err != nil {
+		log.Errorf("Failed to get deployment from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if past.ID == 0 || past.UserID != userDB.ID || past.App != appName {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	app := App{}
+	if err = json.Unmarshal([]byte(past.Spec), &app); err != nil {
+		log.Errorf("Invalid spec of deployment %v. Error: %v", past.ID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, ok := readBody(w, r, maxAppBodySize)
+	if !ok {
+		return
+	}
+	req := RedeployRequest{}
+	if len(body) > 0 {
+		if !decodeJSON(w, r, body, &req) {
+			return
+		}
+		app.UserName, app.Password = req.UserName, req.Password
+	}
+	errs, err := s.restoreEnvs(r, userDB.Space, &app, req.Envs)
+	if err != nil {
+		log.Errorf("Error while getting app %v. Error: %v", appName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(errs) > 0 {
+		log.Errorf("Missing values of deployment %v. Errors: %v", past.ID, errs)
+		writeValidationError(w, errs)
+		return
+	}
+
+	// The ttl of the app starts over.
+	var ttl time.Duration
+	if app.TTL != "" {
+		if ttl, err = time.ParseDuration(app.TTL); err != nil {
+			log.Errorf("Invalid ttl %v of deployment %v. Error: %v", app.TTL, past.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	deployment, err := s.deploy(r, userDB, app, ttl, true)
+	if err != nil {
+		writeDeployError(w, r, err)
+		return
+	}
+
+	log.Infof("App %v redeployed from deployment %v. Space: %v", appName, past.ID, userDB.Space)
+	writeJSON(w, newDeploymentResponse(*deployment))
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/platform9/app-controller/pkg/domains"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+)
+
+// Custom domain request structure.
+type DomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// Custom domain response structure, with the TXT record to publish the token in.
+type DomainResponse struct {
+	objects.Domain
+	Record   string `json:"record"`
+	Verified bool   `json:"verified"`
+}
+
+func newDomainResponse(domain objects.Domain) DomainResponse {
+	return DomainResponse{
+		Domain:   domain,
+		Record:   domains.ChallengeRecord(domain.Domain),
+		Verified: domain.Verified(),
+	}
+}
+
+// Get the claim of the custom domain by an app of the user, responding with
+// the error status if there is none.
+func (s *Server) getAppDomain(w http.ResponseWriter, r *http.Request, userDB *objects.User, appName string, name string) (*objects.Domain, bool) {
+	claims := []objects.Domain{}
+	if err := s.Domains.GetDomainsByName(r.Context(), domains.Normalize(name), &claims); err != nil {
+		requestLogger(r).Errorf("Failed to get domain from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	for _, claim := range claims {
+		if claim.UserID == userDB.ID && claim.App == appName {
+			return &claim, true
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	return nil, false
+}
+
+// Get the verified claim of a custom domain, nil if none is verified.
+func verifiedClaim(claims []objects.Domain) *objects.Domain {
+	for i := range claims {
+		if claims[i].Verified() {
+			return &claims[i]
+		}
+	}
+	return nil
+}
+
+// To list the custom domains of an app.
+func (s *Server) getDomains(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Get Domains *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+
+	appDomains := []objects.Domain{}
+	if err := s.Domains.GetDomainsByApp(r.Context(), userDB.ID, mux.Vars(r)["name"], &appDomains); err != nil {
+		log.Errorf("Failed to get domains from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := []DomainResponse{}
+	for _, domain := range appDomains {
+		resp = append(resp, newDomainResponse(domain))
+	}
+	writeJSON(w, resp)
+}
+
+/*
+-- Add domain
+1. Check the domain is valid, and not verified by another app.
+2. Check the plan of the user allows another domain.
+3. Record the claim of the domain with a new token, pending verification. Other users can claim it too.
+4. The user publishes the token in the TXT record and calls verify, the first verified claim owns the domain.
+*/
+func (s *Server) addDomain(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Add Domain *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+	appName := mux.Vars(r)["name"]
+	event := auditAction(r, "add-domain", "")
+
+	body, ok := readBody(w, r, maxAppBodySize)
+	if !ok {
+		return
+	}
+	domainReq := DomainRequest{}
+	if !decodeJSON(w, r, body, &domainReq) {
+		return
+	}
+
+	name := domains.Normalize(domainReq.Domain)
+	event.Target = name
+	if err := domains.Validate(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err := s.Clients.GetAppByName(r.Context(), userDB.Space, appName)
+	if apierrors.IsNotFound(err) {
+		log.Errorf("App %v not found. Error: %v", appName, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("Error while getting app %v. Error: %v", appName, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	claims := []objects.Domain{}
+	if err = s.Domains.GetDomainsByName(r.Context(), name, &claims); err != nil {
+		log.Errorf("Failed to get domain from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if verified := verifiedClaim(claims); verified != nil && (verified.UserID != userDB.ID || verified.App != appName) {
+		http.Error(w, "Domain is already verified by another app", http.StatusConflict)
+		return
+	}
+	for _, claim := range claims {
+		if claim.UserID == userDB.ID && claim.App == appName {
+			writeJSON(w, newDomainResponse(claim))
+			return
+		}
+	}
+
+	userDomains := []objects.Domain{}
+	if err = s.Domains.GetDomainsByUser(r.Context(), userDB.ID, &userDomains); err != nil {
+		log.Errorf("Failed to get domains from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(userDomains) >= GetUserPlan(userDB).MaxDomains {
+		log.Errorf("Maximum domains limit reached!! Namespace: %v", userDB.Space)
+		http.Error(w, util.MaxDomainsError, util.MaxAppDeployStatusCode)
+		return
+	}
+
+	token, err := domains.NewToken()
+	if err != nil {
+		log.Errorf("Failed to create domain token. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	domain := objects.Domain{
+		UserID: userDB.ID,
+		Space:  userDB.Space,
+		App:    appName,
+		Domain: name,
+		Token:  token,
+	}
+	if err = s.Domains.AddDomain(r.Context(), &domain); err != nil {
+		log.Errorf("Failed to add domain to DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("Domain %v added to app %v, pending verification. Space: %v", name, appName, userDB.Space)
+	writeJSON(w, newDomainResponse(domain))
+}
+
+/*
+-- Verify domain
+1. Check no other claim of the domain is verified.
+2. Look up the token of the claim in the TXT record of the domain.
+3. Map the domain to the app, and record the claim as verified.
+4. Remove the other claims of the domain, pending verification.
+*/
+func (s *Server) verifyDomain(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Verify Domain *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	auditAction(r, "verify-domain", domains.Normalize(vars["domain"]))
+
+	domain, ok := s.getAppDomain(w, r, userDB, vars["name"], vars["domain"])
+	if !ok {
+		return
+	}
+	claims := []objects.Domain{}
+	if err := s.Domains.GetDomainsByName(r.Context(), domain.Domain, &claims); err != nil {
+		log.Errorf("Failed to get domain from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if verified := verifiedClaim(claims); verified != nil && verified.ID != domain.ID {
+		http.Error(w, "Domain is already verified by another app", http.StatusConflict)
+		return
+	}
+
+	if !domain.Verified() {
+		verified, err := domains.Verify(r.Context(), s.Resolver, domain.Domain, domain.Token)
+		if err != nil {
+			log.Errorf("Failed to look up TXT record of %v. Error: %v", domain.Domain, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !verified {
+			http.Error(w, "Token not found in TXT record "+domains.ChallengeRecord(domain.Domain), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Map the domain again on retries, in case the mapping failed.
+	err := s.Clients.CreateDomainMapping(r.Context(), userDB.Space, domain.App, domain.Domain)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !domain.Verified() {
+		if err = s.Domains.SetDomainVerified(r.Context(), domain); err != nil {
+			log.Errorf("Failed to update domain in DB. Error: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, claim := range claims {
+		if claim.ID == domain.ID {
+			continue
+		}
+		if err = s.Domains.RemoveDomain(r.Context(), &claim); err != nil {
+			log.Errorf("Failed to remove claim of domain %v by user %v from DB. Error: %v", claim.Domain, claim.UserID, err)
+		}
+	}
+
+	log.Infof("Domain %v verified and mapped to app %v. Space: %v", domain.Domain, domain.App, userDB.Space)
+	writeJSON(w, newDomainResponse(*domain))
+}
+
+// To detach a custom domain from an app.
+func (s *Server) deleteDomain(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Delete Domain *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	auditAction(r, "delete-domain", domains.Normalize(vars["domain"]))
+
+	domain, ok := s.getAppDomain(w, r, userDB, vars["name"], vars["domain"])
+	if !ok {
+		return
+	}
+
+	if err := s.Clients.DeleteDomainMapping(r.Context(), userDB.Space, domain.Domain); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.Domains.RemoveDomain(r.Context(), domain); err != nil {
+		log.Errorf("Failed to remove domain from DB. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("Domain %v detached from app %v. Space: %v", domain.Domain, domain.App, userDB.Space)
+	w.WriteHeader(http.StatusOK)
+}
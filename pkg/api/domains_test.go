@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/platform9/app-controller/pkg/domains"
+)
+
+// Resolver of the TXT records of the tests, by record name.
+type testResolver map[string][]string
+
+func (r testResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return r[name], nil
+}
+
+func TestDomainClaims(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	resolver := testResolver{}
+	ts.Resolver = resolver
+
+	owner := ts.login(t, "jdoe")
+	ts.login(t, "asmith")
+	for _, user := range []string{"jdoe", "asmith"} {
+		assert.Equal(t, ts.do("POST", "/v1/apps", user, `{"name": "web", "image": "nginx"}`).Code, http.StatusOK)
+	}
+	addDomain := func(user string, status int) DomainResponse {
+		t.Helper()
+		w := ts.do("POST", "/v1/apps/web/domains", user, `{"domain": "WWW.example.com"}`)
+		assert.Equal(t, w.Code, status, w.Body.String())
+		resp := DomainResponse{}
+		if status == http.StatusOK {
+			assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return resp
+	}
+
+	// Unverified claims don't keep other users from claiming the domain.
+	squatted := addDomain("asmith", http.StatusOK)
+	claimed := addDomain("jdoe", http.StatusOK)
+	assert.Equal(t, claimed.Domain.Domain, "www.example.com")
+	assert.Equal(t, claimed.Record, "_app-controller-challenge.www.example.com")
+	assert.Assert(t, claimed.Token != squatted.Token)
+	assert.Equal(t, addDomain("jdoe", http.StatusOK).Token, claimed.Token)
+	assert.Equal(t, ts.do("POST", "/v1/apps/api/domains", "jdoe", `{"domain": "api.example.com"}`).Code, http.StatusNotFound)
+
+	// The claim with its token in the TXT record is verified, and the others removed.
+	path := "/v1/apps/web/domains/www.example.com/verify"
+	assert.Equal(t, ts.do("POST", path, "jdoe", "").Code, http.StatusBadRequest)
+	resolver[domains.ChallengeRecord("www.example.com")] = []string{claimed.Token}
+	assert.Equal(t, ts.do("POST", path, "asmith", "").Code, http.StatusBadRequest)
+	w := ts.do("POST", path, "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	_, err := ts.serving.ServingV1beta1().DomainMappings(owner.Space).Get(ctx, "www.example.com", metav1.GetOptions{})
+	assert.NilError(t, err)
+
+	assert.Equal(t, ts.do("POST", path, "asmith", "").Code, http.StatusNotFound)
+	addDomain("asmith", http.StatusConflict)
+	assert.Equal(t, addDomain("jdoe", http.StatusOK).Verified, true)
+
+	// The plan limits the domains of the user.
+	assert.Equal(t, ts.do("POST", "/v1/apps/web/domains", "jdoe", `{"domain": "api.example.com"}`).Code,
+		http.StatusTooManyRequests)
+}
+
+func TestAddDomainErrors(t *testing.T) {
+	ts := newTestServer(t)
+	ts.login(t, "jdoe")
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "nginx"}`).Code, http.StatusOK)
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"domain": "example"}`, http.StatusBadRequest},
+		{`{"domain": "www.example.com"`, http.StatusBadRequest},
+		{`{"domain": "www.example.com", "app": "api"}`, http.StatusUnprocessableEntity},
+		{`{"domain": ["www.example.com"]}`, http.StatusUnprocessableEntity},
+		{`{"domain": "` + strings.Repeat("a", maxAppBodySize) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		w := ts.do("POST", "/v1/apps/web/domains", "jdoe", tc.body)
+		assert.Equal(t, w.Code, tc.status, w.Body.String())
+	}
+
+	// Only a missing app is not found, other errors of knative are not.
+	ts.serving.PrependReactor("get", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("knative is down")
+	})
+	assert.Equal(t, ts.do("POST", "/v1/apps/web/domains", "jdoe", `{"domain": "www.example.com"}`).Code,
+		http.StatusInternalServerError)
+}
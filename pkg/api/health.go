@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/platform9/app-controller/pkg/options"
+)
+
+const (
+	// Status of a check.
+	checkOK     = "ok"
+	checkFailed = "failed"
+	// Time allowed to all the readiness checks.
+	readinessTimeout = 5 * time.Second
+)
+
+// Check of a dependency the service needs to serve requests.
+type Check func(ctx context.Context) error
+
+// SetShuttingDown fails the readiness from now on. Requests are still served
+// until the server stops listening, after the shutdown delay.
+func (s *Server) SetShuttingDown() {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+}
+
+// Result of a check.
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Health response structure.
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Check the user store, if it is a database.
+func (s *Server) checkDatabase(ctx context.Context) error {
+	if p, ok := s.Users.(pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (s *Server) checkKubernetes(ctx context.Context) error {
+	return s.Clients.Kube.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+}
+
+func checkJWKS(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, options.GetJWKSURL(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS returned status %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// Run the checks concurrently.
+func runChecks(ctx context.Context, checks map[string]Check) map[string]CheckResult {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := map[string]CheckResult{}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := CheckResult{Status: checkOK}
+			if err := check(ctx); err != nil {
+				result = CheckResult{Status: checkFailed, Error: err.Error()}
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return results
+}
+
+// To check the process is alive.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, HealthResponse{Status: checkOK})
+}
+
+// To check the service can serve requests: the database, Kubernetes API and
+// JWKS are reachable, and the server is not shutting down.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := HealthResponse{Status: checkOK, Checks: runChecks(ctx, s.readinessChecks)}
+	if atomic.LoadInt32(&s.shuttingDown) == 1 {
+		resp.Checks["shutdown"] = CheckResult{Status: checkFailed, Error: "server is shutting down"}
+	}
+	for name, result := range resp.Checks {
+		if result.Status != checkOK {
+			zap.S().Warnf("Readiness check %v failed: %v", name, result.Error)
+			resp.Status = checkFailed
+		}
+	}
+
+	if resp.Status != checkOK {
+		writeJSONStatus(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestReadyz(t *testing.T) {
+	s := &Server{}
+
+	get := func() (int, HealthResponse) {
+		w := httptest.NewRecorder()
+		s.readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+		var resp HealthResponse
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+	ok := func(ctx context.Context) error { return nil }
+
+	s.readinessChecks = map[string]Check{"database": ok, "kubernetes": ok}
+	code, resp := get()
+	assert.Equal(t, code, http.StatusOK)
+	assert.DeepEqual(t, resp, HealthResponse{Status: "ok", Checks: map[string]CheckResult{
+		"database":   {Status: "ok"},
+		"kubernetes": {Status: "ok"},
+	}})
+
+	s.readinessChecks["database"] = func(ctx context.Context) error { return errors.New("connection refused") }
+	code, resp = get()
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, resp.Status, "failed")
+	assert.DeepEqual(t, resp.Checks["database"], CheckResult{Status: "failed", Error: "connection refused"})
+	assert.DeepEqual(t, resp.Checks["kubernetes"], CheckResult{Status: "ok"})
+
+	// Not ready while shutting down, even with every dependency up.
+	s.readinessChecks["database"] = ok
+	s.SetShuttingDown()
+	code, resp = get()
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.Equal(t, resp.Checks["shutdown"].Status, "failed")
+
+	w := httptest.NewRecorder()
+	s.healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, w.Code, http.StatusOK)
+}
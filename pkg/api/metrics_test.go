@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+
+	"github.com/platform9/app-controller/pkg/metrics"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/apps/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	r.Use(metricsMiddleware)
+
+	counter := metrics.HTTPRequests.WithLabelValues("/v1/apps/{name}", "GET", "404")
+	before := testutil.ToFloat64(counter)
+	for _, name := range []string{"one", "two"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/apps/"+name, nil))
+	}
+
+	// Requests are counted by route template, not by app name.
+	assert.Equal(t, testutil.ToFloat64(counter)-before, float64(2))
+}
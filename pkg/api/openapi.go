@@ -0,0 +1,167 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// OpenAPI document of the API, describing every route.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// Schemas of the request bodies, validated against the OpenAPI document.
+var requestSchemas = loadSchemas(openAPIDocument, "App", "Manifest")
+
+// Prefix of the references to the schemas of the OpenAPI document.
+const schemaRefPrefix = "#/components/schemas/"
+
+// Index of an item in the field of an error, e.g. "[1]" of "envs[1].key".
+var fieldIndex = regexp.MustCompile(`\[\d+\]`)
+
+// Load the named schemas of the components of the OpenAPI document, with
+// their references expanded as the validator doesn't resolve them.
+func loadSchemas(document []byte, names ...string) map[string]*spec.Schema {
+	doc := struct {
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.Unmarshal(document, &doc); err != nil {
+		panic(fmt.Sprintf("Invalid OpenAPI document. Error: %v", err))
+	}
+
+	schemas := map[string]*spec.Schema{}
+	for _, name := range names {
+		expanded, err := expandRefs(doc.Components.Schemas, doc.Components.Schemas[name])
+		if err != nil {
+			panic(fmt.Sprintf("Invalid schema %v of the OpenAPI document. Error: %v", name, err))
+		}
+		data, err := json.Marshal(expanded)
+		if err != nil {
+			panic(err)
+		}
+		schema := &spec.Schema{}
+		if err = json.Unmarshal(data, schema); err != nil {
+			panic(fmt.Sprintf("Invalid schema %v of the OpenAPI document. Error: %v", name, err))
+		}
+		schemas[name] = schema
+	}
+	return schemas
+}
+
+// Replace the references to the schemas in the value with a copy of them.
+func expandRefs(schemas map[string]interface{}, value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		expanded := map[string]interface{}{}
+		if ref, ok := value["$ref"].(string); ok {
+			target, ok := schemas[strings.TrimPrefix(ref, schemaRefPrefix)]
+			if !ok || !strings.HasPrefix(ref, schemaRefPrefix) {
+				return nil, fmt.Errorf("unknown reference %v", ref)
+			}
+			resolved, err := expandRefs(schemas, target)
+			if err != nil {
+				return nil, err
+			}
+			for key, item := range resolved.(map[string]interface{}) {
+				expanded[key] = item
+			}
+		}
+		for key, item := range value {
+			if key == "$ref" {
+				continue
+			}
+			resolved, err := expandRefs(schemas, item)
+			if err != nil {
+				return nil, err
+			}
+			expanded[key] = resolved
+		}
+		return expanded, nil
+	case []interface{}:
+		expanded := make([]interface{}, len(value))
+		for i, item := range value {
+			resolved, err := expandRefs(schemas, item)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = resolved
+		}
+		return expanded, nil
+	}
+	return value, nil
+}
+
+// Validate the decoded JSON value against the named schema of the request
+// bodies, returning the invalid fields.
+func schemaErrors(name string, value interface{}) []FieldError {
+	schema := requestSchemas[name]
+	result := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(value)
+	errs := []FieldError{}
+	for _, err := range result.Errors {
+		field, msg := "", err.Error()
+		// The errors read e.g. "envs.key in body is required".
+		if i := strings.Index(msg, " in body "); i >= 0 {
+			field, msg = strings.TrimPrefix(msg[:i], "."), msg[i+len(" in body "):]
+		}
+		errs = append(errs, FieldError{Field: field, Message: msg})
+	}
+	// The validator reports the properties in no particular order.
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+// Decode the JSON data into value after validating it against the named
+// schema. The schema errors are returned if the data can still be decoded,
+// to merge with the errors of the fields. Responds with the error status
+// otherwise.
+func decodeSchema(w http.ResponseWriter, r *http.Request, data []byte, name string, value interface{}) ([]FieldError, bool) {
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		requestLogger(r).Errorf("Invalid request body. Error: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	errs := schemaErrors(name, decoded)
+	if len(errs) == 0 {
+		return errs, decodeJSON(w, r, data, value)
+	}
+	// Unknown fields are already reported by the schema.
+	if err := json.Unmarshal(data, value); err != nil {
+		requestLogger(r).Errorf("Invalid request body. Errors: %v", errs)
+		writeValidationError(w, errs)
+		return nil, false
+	}
+	return errs, true
+}
+
+// Merge the schema errors into the errors of the fields, skipping the fields
+// already reported, e.g. "envs.key" for "envs[1].key".
+func mergeFieldErrors(errs []FieldError, schemaErrs []FieldError) []FieldError {
+	reported := map[string]bool{}
+	for _, err := range errs {
+		reported[fieldIndex.ReplaceAllString(err.Field, "")] = true
+	}
+	for _, err := range schemaErrs {
+		if !reported[fieldIndex.ReplaceAllString(err.Field, "")] {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// To get the OpenAPI document of the API.
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"gotest.tools/assert"
+	"k8s.io/kube-openapi/pkg/spec3"
+)
+
+// Operations of the OpenAPI document by method.
+func pathOperations(path *spec3.Path) map[string]*spec3.Operation {
+	return map[string]*spec3.Operation{
+		http.MethodGet:    path.Get,
+		http.MethodPut:    path.Put,
+		http.MethodPost:   path.Post,
+		http.MethodDelete: path.Delete,
+		http.MethodPatch:  path.Patch,
+	}
+}
+
+// Status codes responded by the handler of each route, to keep the OpenAPI
+// document in sync with the handlers.
+var routeStatusCodes = map[string][]string{
+	"GET /v1/apps":                                   {"200", "403", "500"},
+	"POST /v1/apps":                                  {"200", "400", "403", "413", "422", "429", "500"},
+	"POST /v1/apps/login":                            {"200", "403", "500"},
+	"POST /v1/apps/validate":                         {"200", "400", "403", "413", "422", "500"},
+	"GET /v1/apps/{name}":                            {"200", "403", "404", "500"},
+	"DELETE /v1/apps/{name}":                         {"200", "403", "404", "500"},
+	"PUT /v1/apps/{name}/visibility":                 {"200", "400", "403", "404", "413", "422", "500"},
+	"GET /v1/apps/{name}/deployments":                {"200", "403", "500"},
+	"POST /v1/apps/{name}/deployments/{id}/redeploy": {"200", "400", "403", "404", "413", "422", "429", "500"},
+	"GET /v1/apps/{name}/domains":                    {"200", "403", "500"},
+	"POST /v1/apps/{name}/domains":                   {"200", "400", "403", "404", "409", "413", "422", "429", "500"},
+	"POST /v1/apps/{name}/domains/{domain}/verify":   {"200", "400", "403", "404", "409", "500"},
+	"DELETE /v1/apps/{name}/domains/{domain}":        {"200", "403", "404", "500"},
+	"POST /v1/apply":                                 {"200", "400", "403", "413", "422", "500"},
+	"GET /v1/export":                                 {"200", "400", "403", "500"},
+	"GET /v1/quota":                                  {"200", "403", "500"},
+	"DELETE /v1/me":                                  {"200", "403", "404", "500"},
+	"GET /v1/plans":                                  {"200", "403", "500"},
+	"GET /v1/users":                                  {"200", "403", "500"},
+	"PUT /v1/users/{id}/plan":                        {"200", "400", "403", "404", "500"},
+	"GET /v1/audit":                                  {"200", "400", "403", "500"},
+	"GET /v1/log/level":                              {"200", "403", "500"},
+	"PUT /v1/log/level":                              {"200", "400", "403", "500"},
+	"GET /v1/openapi.json":                           {"200"},
+	"GET /healthz":                                   {"200"},
+	"GET /readyz":                                    {"200", "503"},
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := &spec3.OpenAPI{}
+	assert.NilError(t, json.Unmarshal(openAPIDocument, doc))
+
+	// Every route of the router is described.
+	routes := map[string]bool{}
+	err := New(&Server{}).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes[method+" "+template] = true
+			path, ok := doc.Paths.Paths[template]
+			assert.Assert(t, ok, "route %v %v is missing from the OpenAPI document", method, template)
+			assert.Assert(t, pathOperations(path)[method] != nil, "route %v %v is missing from the OpenAPI document",
+				method, template)
+		}
+		return nil
+	})
+	assert.NilError(t, err)
+
+	// Every operation is routed and documents the status codes of its handler.
+	for template, path := range doc.Paths.Paths {
+		for method, operation := range pathOperations(path) {
+			if operation == nil {
+				continue
+			}
+			route := method + " " + template
+			assert.Assert(t, routes[route], "operation %v is not routed", route)
+			assert.Assert(t, operation.Responses != nil, "operation %v has no responses", route)
+			codes := []string{}
+			for code := range operation.Responses.StatusCodeResponses {
+				codes = append(codes, strconv.Itoa(code))
+			}
+			sort.Strings(codes)
+			want, ok := routeStatusCodes[route]
+			assert.Assert(t, ok, "status codes of operation %v are not listed", route)
+			assert.Equal(t, strings.Join(codes, " "), strings.Join(want, " "), "status codes of operation %v", route)
+		}
+	}
+	assert.Equal(t, len(routeStatusCodes), len(routes))
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	ts := newTestServer(t)
+	w := ts.do("GET", "/v1/openapi.json", "", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Equal(t, w.Body.String(), string(openAPIDocument))
+}
+
+func TestSchemaErrors(t *testing.T) {
+	decode := func(body string) interface{} {
+		t.Helper()
+		var value interface{}
+		assert.NilError(t, json.NewDecoder(strings.NewReader(body)).Decode(&value))
+		return value
+	}
+
+	valid := `{"name": "web", "image": "nginx:1", "port": "8080", "envs": [{"key": "MODE", "value": "test"}],
+		"username": "jdoe", "password": "secret", "ttl": "24h", "visibility": "cluster-local"}`
+	assert.DeepEqual(t, schemaErrors("App", decode(valid)), []FieldError{})
+
+	invalid := `{"image": 1, "port": "http", "envs": [{"value": "a"}], "visibility": "private", "replicas": 3}`
+	errs := schemaErrors("App", decode(invalid))
+	fields := map[string]bool{}
+	for _, err := range errs {
+		assert.Assert(t, err.Message != "" && !strings.Contains(err.Message, " in body "), err.Message)
+		fields[err.Field] = true
+	}
+	for _, field := range []string{"name", "image", "port", "envs.key", "visibility", "replicas"} {
+		assert.Assert(t, fields[field], "field %v is not reported in %v", field, errs)
+	}
+
+	errs = schemaErrors("Manifest", decode(`{"apps": [{"name": "web", "image": "nginx"}, {"name": "Api"}]}`))
+	assert.DeepEqual(t, errorFields(errs), []string{"apps.image", "apps.name"})
+}
+
+func TestMergeFieldErrors(t *testing.T) {
+	errs := []FieldError{{Field: "apps[1].envs[0].key", Message: "is required"}}
+	schemaErrs := []FieldError{
+		{Field: "apps.envs.key", Message: "is required"},
+		{Field: "apps.replicas", Message: "is a forbidden property"},
+	}
+	assert.DeepEqual(t, errorFields(mergeFieldErrors(errs, schemaErrs)), []string{"apps[1].envs[0].key", "apps.replicas"})
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// RequestIDHeader carries the ID of a request, set by the caller or generated.
+const RequestIDHeader = "X-Request-ID"
+
+// Request IDs accepted from callers, others are replaced.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// State of a request, filled by the handlers for the access log and the
+// audit event.
+type requestInfo struct {
+	ID    string
+	User  string
+	Space string
+	// Audit event of a mutating action, recorded once the request completes.
+	Audit *objects.AuditEvent
+}
+
+type contextKey int
+
+const requestInfoKey contextKey = 0
+
+// Get the state of the request, empty outside of requestIDMiddleware.
+func getRequestInfo(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// RequestID returns the ID of the request.
+func RequestID(r *http.Request) string {
+	return getRequestInfo(r).ID
+}
+
+// Generate a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Logger of the request, adding its ID to every line.
+func requestLogger(r *http.Request) *zap.SugaredLogger {
+	return zap.S().With("request_id", RequestID(r))
+}
+
+// Record the user and the namespace the request acts on.
+func setRequestUser(r *http.Request, user string, space string) {
+	info := getRequestInfo(r)
+	info.User = user
+	info.Space = space
+}
+
+// Audit the action of the request on the target, the actor and the
+// namespace are the user of the request. The outcome is recorded from the
+// response status once the request completes.
+func auditAction(r *http.Request, action string, target string) *objects.AuditEvent {
+	info := getRequestInfo(r)
+	info.Audit = &objects.AuditEvent{Action: action, Target: target}
+	return info.Audit
+}
+
+// Assign an ID to every request, the X-Request-ID of the caller if valid,
+// and return it in the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestInfoKey, &requestInfo{ID: id})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Log one line per request, and record the audit event of mutating actions.
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		info := getRequestInfo(r)
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		zap.L().Info("access",
+			zap.String("request_id", info.ID),
+			zap.String("method", r.Method),
+			zap.String("route", route),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", recorder.status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("user", info.User),
+			zap.String("namespace", info.Space),
+			zap.String("remote_addr", r.RemoteAddr),
+		)
+
+		if info.Audit == nil {
+			return
+		}
+		event := info.Audit
+		event.Actor = info.User
+		if event.Space == "" {
+			event.Space = info.Space
+		}
+		event.RequestID = info.ID
+		event.Outcome = objects.AuditSuccess
+		if recorder.status >= http.StatusBadRequest {
+			event.Outcome = objects.AuditFailure
+			if event.Detail == "" {
+				event.Detail = http.StatusText(recorder.status)
+			}
+		}
+		// The event is recorded even if the client went away, without the
+		// request context.
+		if err := s.Audit.AddAuditEvent(context.Background(), event); err != nil {
+			zap.S().Errorf("Failed to record audit event %v of request %v. Error: %v", event.Action, info.ID, err)
+		}
+	})
+}
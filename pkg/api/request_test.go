@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gotest.tools/assert"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/apps", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RequestID(r)))
+	})
+	r.Use(requestIDMiddleware)
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/apps", nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The ID of the caller is kept.
+	w := serve("client-id.1")
+	assert.Equal(t, w.Header().Get(RequestIDHeader), "client-id.1")
+	assert.Equal(t, w.Body.String(), "client-id.1")
+
+	// Missing and invalid IDs are replaced.
+	for _, id := range []string{"", "bad id", "x\ny", string(make([]byte, 65))} {
+		w = serve(id)
+		generated := w.Header().Get(RequestIDHeader)
+		assert.Equal(t, len(generated), 32, id)
+		assert.Equal(t, w.Body.String(), generated)
+	}
+	assert.Assert(t, serve("").Header().Get(RequestIDHeader) != serve("").Header().Get(RequestIDHeader))
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	store := db.NewMemoryStore()
+	s := &Server{Audit: store}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/apps/{name}", func(w http.ResponseWriter, r *http.Request) {
+		setRequestUser(r, "jdoe@example.com", "jdoe-abc123")
+		auditAction(r, "delete-app", mux.Vars(r)["name"])
+		if mux.Vars(r)["name"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods("DELETE")
+	r.HandleFunc("/v1/apps", func(w http.ResponseWriter, r *http.Request) {
+		setRequestUser(r, "jdoe@example.com", "jdoe-abc123")
+	}).Methods("GET")
+	r.Use(requestIDMiddleware, s.accessLogMiddleware)
+
+	for id, req := range map[string]*http.Request{
+		"delete-web":     httptest.NewRequest("DELETE", "/v1/apps/web", nil),
+		"delete-missing": httptest.NewRequest("DELETE", "/v1/apps/missing", nil),
+		"list":           httptest.NewRequest("GET", "/v1/apps", nil),
+	} {
+		req.Header.Set(RequestIDHeader, id)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	recorded := []objects.AuditEvent{}
+	assert.NilError(t, store.GetAuditEvents(context.Background(), objects.AuditFilter{}, &recorded))
+	for i := range recorded {
+		recorded[i].ID, recorded[i].CreatedAt = 0, time.Time{}
+	}
+	sort.Slice(recorded, func(i, j int) bool { return recorded[i].Target > recorded[j].Target })
+
+	// Only the mutating actions are audited, with their outcome.
+	assert.DeepEqual(t, recorded, []objects.AuditEvent{
+		{
+			Actor:     "jdoe@example.com",
+			Action:    "delete-app",
+			Space:     "jdoe-abc123",
+			Target:    "web",
+			Outcome:   objects.AuditSuccess,
+			RequestID: "delete-web",
+		},
+		{
+			Actor:     "jdoe@example.com",
+			Action:    "delete-app",
+			Space:     "jdoe-abc123",
+			Target:    "missing",
+			Outcome:   objects.AuditFailure,
+			Detail:    "Not Found",
+			RequestID: "delete-missing",
+		},
+	})
+}
+
+func TestAuditFilter(t *testing.T) {
+	filter, err := auditFilter(httptest.NewRequest("GET", "/v1/audit", nil))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, filter, objects.AuditFilter{Limit: defaultAuditLimit})
+
+	filter, err = auditFilter(httptest.NewRequest("GET",
+		"/v1/audit?actor=reaper&action=delete-app&space=ns&since=2022-03-01T10:00:00Z&limit=5000", nil))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, filter, objects.AuditFilter{
+		Actor:  "reaper",
+		Action: "delete-app",
+		Space:  "ns",
+		Since:  time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+		Limit:  maxAuditLimit,
+	})
+
+	for _, query := range []string{"since=yesterday", "limit=0", "limit=ten"} {
+		_, err = auditFilter(httptest.NewRequest("GET", "/v1/audit?"+query, nil))
+		assert.Assert(t, err != nil, query)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/domains"
+	"github.com/platform9/app-controller/pkg/knative"
+)
+
+// Server of the API, with the stores and clients used by the handlers.
+type Server struct {
+	Users       db.UserStore
+	Deployments db.DeploymentStore
+	Domains     db.DomainStore
+	Audit       db.AuditStore
+	Clients     *knative.Clients
+	// Validates the token of a request and returns its claims,
+	// ValidateToken against the JWKS by default.
+	ValidateToken func(r *http.Request) (jwt.Claims, error)
+	// Looks up the verification records of custom domains.
+	Resolver domains.Resolver
+
+	// Readiness checks by name.
+	readinessChecks map[string]Check
+	// Set to 1 once the server is shutting down, accessed atomically.
+	shuttingDown int32
+}
+
+// NewServer returns a server of the objects of the store, deploying the apps
+// with the clients.
+func NewServer(store db.Store, clients *knative.Clients) *Server {
+	s := &Server{
+		Users:         store,
+		Deployments:   store,
+		Domains:       store,
+		Audit:         store,
+		Clients:       clients,
+		ValidateToken: ValidateToken,
+		Resolver:      domains.DefaultResolver,
+	}
+	s.readinessChecks = map[string]Check{
+		"database":   s.checkDatabase,
+		"kubernetes": s.checkKubernetes,
+		"jwks":       checkJWKS,
+	}
+	return s
+}
+
+// The stores of the server as one store.
+func (s *Server) store() db.Store {
+	return db.Stores{UserStore: s.Users, DeploymentStore: s.Deployments, DomainStore: s.Domains, AuditStore: s.Audit}
+}
+
+// Store that can check its connectivity, like db.Querier.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
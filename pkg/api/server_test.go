@@ -0,0 +1,509 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/platform9/app-controller/pkg/api/apitest"
+	"github.com/platform9/app-controller/pkg/controller"
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+)
+
+// Server of the tests, with the users in memory and fake clusters.
+type testServer struct {
+	*Server
+	router  *mux.Router
+	users   *db.MemoryStore
+	kube    *k8sfake.Clientset
+	serving *servingfake.Clientset
+}
+
+func newTestServer(t *testing.T) *testServer {
+	apitest.SetOptions(t)
+	fakes := apitest.NewFakes()
+	ts := &testServer{users: fakes.Store, kube: fakes.Kube, serving: fakes.Serving}
+	ts.Server = NewServer(fakes.Store, fakes.Clients())
+	ts.ValidateToken = apitest.Token
+	ts.router = New(ts.Server)
+	return ts
+}
+
+// Audit events recorded by the server, the latest first.
+func (ts *testServer) audited(t *testing.T) []objects.AuditEvent {
+	t.Helper()
+	events := []objects.AuditEvent{}
+	assert.NilError(t, ts.users.GetAuditEvents(context.Background(), objects.AuditFilter{}, &events))
+	return events
+}
+
+// Get the knative service of an app, failing the test if it is missing.
+func (ts *testServer) app(t *testing.T, space string, name string) *servingv1.Service {
+	t.Helper()
+	service, err := ts.serving.ServingV1().Services(space).Get(context.Background(), name, metav1.GetOptions{})
+	assert.NilError(t, err)
+	return service
+}
+
+// Send the request as the user, if any.
+func (ts *testServer) do(method string, path string, user string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+user)
+	}
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, req)
+	return w
+}
+
+// Log the user in and return it.
+func (ts *testServer) login(t *testing.T, user string) objects.User {
+	t.Helper()
+	w := ts.do("POST", "/v1/apps/login", user, "")
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	found := objects.User{}
+	assert.NilError(t, ts.users.GetUserByEmail(context.Background(), user+"@example.com", &found))
+	assert.Assert(t, found.ID != 0)
+	return found
+}
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+
+	assert.Equal(t, ts.do("POST", "/v1/apps/login", "", "").Code, http.StatusForbidden)
+
+	user := ts.login(t, "jdoe")
+	assert.Equal(t, user.Name, "jdoe")
+	assert.Equal(t, user.Plan, "free")
+	assert.Equal(t, user.Issuer, "auth0")
+	audited := ts.audited(t)
+	assert.Equal(t, audited[len(audited)-1].Action, "create-user")
+
+	ns, err := ts.kube.CoreV1().Namespaces().Get(ctx, user.Space, metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Assert(t, knative.IsManagedNamespace(ns))
+	_, err = ts.kube.NetworkingV1().NetworkPolicies(user.Space).Get(ctx, util.TenantPolicyName, metav1.GetOptions{})
+	assert.NilError(t, err)
+
+	// A second login finds the user, and recreates its deleted namespace.
+	assert.NilError(t, ts.kube.CoreV1().Namespaces().Delete(ctx, user.Space, metav1.DeleteOptions{}))
+	assert.Equal(t, ts.login(t, "jdoe").ID, user.ID)
+	users := []objects.User{}
+	assert.NilError(t, ts.users.GetUsers(ctx, &users))
+	assert.Equal(t, len(users), 1)
+	_, err = ts.kube.CoreV1().Namespaces().Get(ctx, user.Space, metav1.GetOptions{})
+	assert.NilError(t, err)
+}
+
+func TestLoginWithoutNetworkPolicyRBAC(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	ts.kube.PrependReactor("*", "networkpolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "networking.k8s.io", Resource: "networkpolicies"}, "", nil)
+	})
+
+	// The namespace is left to reconcile, without failing the login.
+	user := ts.login(t, "jdoe")
+	assert.NilError(t, ts.kube.CoreV1().Namespaces().Delete(ctx, user.Space, metav1.DeleteOptions{}))
+	assert.Equal(t, ts.login(t, "jdoe").ID, user.ID)
+
+	report, err := controller.Reconcile(ctx, ts.kube, []objects.User{user}, controller.ReconcileOptions{
+		Isolation: controller.NetworkIsolationFromOptions(),
+	}, time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, len(report.Drift), 1)
+	assert.Equal(t, report.Drift[0].Type, controller.DriftNetworkPolicy)
+	assert.Equal(t, report.Drift[0].Space, user.Space)
+}
+
+func TestRequestsTouchUser(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.login(t, "jdoe")
+
+	seen := user
+	for _, route := range []struct{ method, path, body string }{
+		{"GET", "/v1/export", ""},
+		{"POST", "/v1/apps", `{"name": "web", "image": "nginx"}`},
+		{"GET", "/v1/apps", ""},
+		{"GET", "/v1/apps/web", ""},
+		{"GET", "/v1/quota", ""},
+		{"DELETE", "/v1/apps/web", ""},
+	} {
+		assert.Equal(t, ts.do(route.method, route.path, "jdoe", route.body).Code, http.StatusOK, route.path)
+		previous := seen.LastSeenAt
+		assert.NilError(t, ts.users.GetUserByID(context.Background(), user.ID, &seen))
+		assert.Assert(t, seen.LastSeenAt.After(previous), "%v %v: %v is not after %v",
+			route.method, route.path, seen.LastSeenAt, previous)
+	}
+}
+
+func TestApps(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	user := ts.login(t, "jdoe")
+
+	// Users must log in first.
+	assert.Equal(t, ts.do("GET", "/v1/apps", "asmith", "").Code, http.StatusInternalServerError)
+
+	w := ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "nginx", "port": "8080",
+		"envs": [{"key": "MODE", "value": "test"}], "visibility": "cluster-local"}`)
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	service, err := ts.serving.ServingV1().Services(user.Space).Get(ctx, "web", metav1.GetOptions{})
+	assert.NilError(t, err)
+	container := service.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, container.Image, "nginx")
+	assert.Equal(t, container.Ports[0].ContainerPort, int32(8080))
+	assert.DeepEqual(t, container.Env, []corev1.EnvVar{{Name: "MODE", Value: "test"}})
+	assert.Equal(t, knative.Visibility(service), knative.VisibilityClusterLocal)
+
+	// The quota of the plan is set on the namespace.
+	quota, err := ts.kube.CoreV1().ResourceQuotas(user.Space).Get(ctx, util.AppQuotaName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	limit := quota.Spec.Hard[util.AppQuotaResource]
+	assert.Equal(t, limit.Value(), int64(2))
+
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "nginx"}`).Code, http.StatusInternalServerError)
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "tmp", "image": "nginx", "ttl": "soon"}`).Code, http.StatusUnprocessableEntity)
+
+	w = ts.do("GET", "/v1/apps", "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	var list struct {
+		Items []struct {
+			Metadata   metav1.ObjectMeta `json:"metadata"`
+			Visibility string            `json:"visibility"`
+		} `json:"items"`
+	}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, len(list.Items), 1)
+	assert.Equal(t, list.Items[0].Metadata.Name, "web")
+	assert.Equal(t, list.Items[0].Visibility, knative.VisibilityClusterLocal)
+
+	w = ts.do("PUT", "/v1/apps/web/visibility", "jdoe", `{"visibility": "public"}`)
+	assert.Equal(t, w.Code, http.StatusOK)
+	w = ts.do("GET", "/v1/apps/web", "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Assert(t, strings.Contains(w.Body.String(), `"visibility":"public"`), w.Body.String())
+	assert.Equal(t, ts.do("PUT", "/v1/apps/missing/visibility", "jdoe", `{"visibility": "public"}`).Code, http.StatusNotFound)
+
+	w = ts.do("GET", "/v1/quota", "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	var usage struct {
+		Apps knative.QuotaUsage `json:"apps"`
+	}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.DeepEqual(t, usage.Apps, knative.QuotaUsage{Used: 1, Limit: 2})
+
+	assert.Equal(t, ts.do("DELETE", "/v1/apps/web", "jdoe", "").Code, http.StatusOK)
+	_, err = ts.serving.ServingV1().Services(user.Space).Get(ctx, "web", metav1.GetOptions{})
+	assert.Assert(t, err != nil)
+	assert.Equal(t, ts.do("GET", "/v1/apps/web", "jdoe", "").Code, http.StatusNotFound)
+	assert.Equal(t, ts.do("DELETE", "/v1/apps/web", "jdoe", "").Code, http.StatusNotFound)
+}
+
+func TestAdmin(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	user := ts.login(t, "jdoe")
+
+	assert.Equal(t, ts.do("GET", "/v1/users", "jdoe", "").Code, http.StatusForbidden)
+
+	w := ts.do("GET", "/v1/users", "admin", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	users := []objects.User{}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	assert.Equal(t, len(users), 1)
+
+	path := fmt.Sprintf("/v1/users/%d/plan", user.ID)
+	assert.Equal(t, ts.do("PUT", path, "admin", `{"plan": "enterprise"}`).Code, http.StatusBadRequest)
+	assert.Equal(t, ts.do("PUT", "/v1/users/99/plan", "admin", `{"plan": "pro"}`).Code, http.StatusNotFound)
+	w = ts.do("PUT", path, "admin", `{"plan": "pro"}`)
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+
+	assert.NilError(t, ts.users.GetUserByID(ctx, user.ID, &user))
+	assert.Equal(t, user.Plan, "pro")
+	quota, err := ts.kube.CoreV1().ResourceQuotas(user.Space).Get(ctx, util.AppQuotaName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	limit := quota.Spec.Hard[util.AppQuotaResource]
+	assert.Equal(t, limit.Value(), int64(10))
+}
+
+func TestDeleteMe(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	user := ts.login(t, "jdoe")
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "nginx"}`).Code, http.StatusOK)
+	assert.NilError(t, ts.users.AddDomain(ctx, &objects.Domain{UserID: user.ID, Space: user.Space, App: "web",
+		Domain: "web.example.com"}))
+
+	w := ts.do("DELETE", "/v1/me", "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+
+	// The user and its objects are removed from the store of the server.
+	found := objects.User{}
+	assert.NilError(t, ts.users.GetUserByID(ctx, user.ID, &found))
+	assert.Equal(t, found.ID, 0)
+	userDomains := []objects.Domain{}
+	assert.NilError(t, ts.users.GetDomainsByUser(ctx, user.ID, &userDomains))
+	assert.Equal(t, len(userDomains), 0)
+	deployments := []objects.Deployment{}
+	assert.NilError(t, ts.users.GetDeploymentsByApp(ctx, user.ID, "web", &deployments))
+	assert.Equal(t, len(deployments), 0)
+	assert.Equal(t, ts.audited(t)[0].Action, "delete-user")
+
+	assert.Equal(t, ts.do("DELETE", "/v1/me", "jdoe", "").Code, http.StatusNotFound)
+}
+
+func TestAuditEvents(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	user := ts.login(t, "jdoe")
+	other := ts.login(t, "asmith")
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "nginx"}`).Code, http.StatusOK)
+	for _, space := range []string{user.Space, other.Space} {
+		assert.NilError(t, ts.users.AddAuditEvent(ctx, &objects.AuditEvent{Actor: "reaper", Action: "delete-app",
+			Space: space, Target: "web", Outcome: objects.AuditSuccess}))
+	}
+
+	events := func(user string, query string) []objects.AuditEvent {
+		t.Helper()
+		w := ts.do("GET", "/v1/audit"+query, user, "")
+		assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+		resp := []objects.AuditEvent{}
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// Users filter the events of their namespace by actor, and only see those.
+	reaped := events("jdoe", "?actor=reaper")
+	assert.Equal(t, len(reaped), 1)
+	assert.Equal(t, reaped[0].Space, user.Space)
+	assert.Equal(t, len(events("jdoe", "?actor=asmith@example.com")), 0)
+	for _, event := range events("jdoe", "?space="+other.Space) {
+		assert.Equal(t, event.Space, user.Space)
+	}
+
+	assert.Equal(t, ts.do("GET", "/v1/audit?all=true", "jdoe", "").Code, http.StatusForbidden)
+	assert.Equal(t, len(events("admin", "?all=true&actor=reaper")), 2)
+}
+
+func TestDeployments(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	user := ts.login(t, "jdoe")
+	ts.login(t, "asmith")
+
+	deployments := func(user string) []DeploymentResponse {
+		t.Helper()
+		w := ts.do("GET", "/v1/apps/web/deployments", user, "")
+		assert.Equal(t, w.Code, http.StatusOK)
+		resp := []DeploymentResponse{}
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	w := ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "docker.io/jdoe/web:1",
+		"username": "jdoe", "password": "secret", "envs": [{"key": "TOKEN", "value": "t0ken"}]}`)
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	first := deployments("jdoe")
+	assert.Equal(t, len(first), 1)
+	assert.Equal(t, first[0].Outcome, objects.DeploymentSucceeded)
+	assert.Equal(t, first[0].Actor, "jdoe@example.com")
+	assert.Equal(t, first[0].Spec.Image, "docker.io/jdoe/web:1")
+	assert.Equal(t, first[0].Spec.UserName, "jdoe")
+	// The registry password and the values of the environment variables are not recorded.
+	assert.Equal(t, first[0].Spec.Password, "")
+	assert.DeepEqual(t, first[0].Spec.Envs, []Env{{Key: "TOKEN"}})
+	recorded := []objects.Deployment{}
+	assert.NilError(t, ts.users.GetDeploymentsByApp(ctx, user.ID, "web", &recorded))
+	assert.Assert(t, !strings.Contains(recorded[0].Spec, "t0ken"), recorded[0].Spec)
+	service, err := ts.serving.ServingV1().Services(user.Space).Get(ctx, "web", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, service.Spec.Template.Name, first[0].Revision)
+
+	// Failed deployments are recorded too.
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "nginx"}`).Code, http.StatusInternalServerError)
+	history := deployments("jdoe")
+	assert.Equal(t, len(history), 2)
+	assert.Equal(t, history[0].Outcome, objects.DeploymentFailed)
+	assert.Equal(t, history[0].Error, "Service already exists")
+	assert.Equal(t, len(deployments("asmith")), 0)
+
+	// Redeploying updates the app with a new revision, keeping its pull secret.
+	path := fmt.Sprintf("/v1/apps/web/deployments/%d/redeploy", first[0].ID)
+	w = ts.do("POST", path, "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	redeployed := DeploymentResponse{}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &redeployed))
+	assert.Equal(t, redeployed.Outcome, objects.DeploymentSucceeded)
+	assert.Assert(t, redeployed.Revision != first[0].Revision)
+	service, err = ts.serving.ServingV1().Services(user.Space).Get(ctx, "web", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, service.Spec.Template.Name, redeployed.Revision)
+	assert.Equal(t, service.Spec.Template.Spec.ImagePullSecrets[0].Name, "web")
+	// The values of the environment variables are those of the running app.
+	assert.DeepEqual(t, service.Spec.Template.Spec.Containers[0].Env, []corev1.EnvVar{{Name: "TOKEN", Value: "t0ken"}})
+	assert.Equal(t, len(deployments("jdoe")), 3)
+
+	// Deleted apps are created again, with the values of the environment variables.
+	assert.Equal(t, ts.do("DELETE", "/v1/apps/web", "jdoe", "").Code, http.StatusOK)
+	w = ts.do("POST", path, "jdoe", `{"username": "jdoe", "password": "secret"}`)
+	assert.Equal(t, w.Code, http.StatusUnprocessableEntity, w.Body.String())
+	assert.Assert(t, strings.Contains(w.Body.String(), `"field":"envs[0].value"`), w.Body.String())
+	w = ts.do("POST", path, "jdoe", `{"username": "jdoe", "password": "secret", "envs": [{"key": "TOKEN", "value": "n3w"}]}`)
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	assert.DeepEqual(t, ts.app(t, user.Space, "web").Spec.Template.Spec.Containers[0].Env,
+		[]corev1.EnvVar{{Name: "TOKEN", Value: "n3w"}})
+	_, err = ts.kube.CoreV1().Secrets(user.Space).Get(ctx, "web", metav1.GetOptions{})
+	assert.NilError(t, err)
+
+	assert.Equal(t, ts.do("POST", path, "asmith", "").Code, http.StatusNotFound)
+	assert.Equal(t, ts.do("POST", "/v1/apps/api/deployments/1/redeploy", "jdoe", "").Code, http.StatusNotFound)
+	assert.Equal(t, ts.do("POST", "/v1/apps/web/deployments/latest/redeploy", "jdoe", "").Code, http.StatusBadRequest)
+
+	// The request body is decoded strictly, up to the size of an app.
+	assert.Equal(t, ts.do("POST", path, "jdoe", `{"username": "jdoe"`).Code, http.StatusBadRequest)
+	assert.Equal(t, ts.do("POST", path, "jdoe", `{"user": "jdoe"}`).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, ts.do("POST", path, "jdoe", `{"envs": {"TOKEN": "n3w"}}`).Code, http.StatusUnprocessableEntity)
+	big := `{"envs": [{"key": "TOKEN", "value": "` + strings.Repeat("a", maxAppBodySize) + `"}]}`
+	assert.Equal(t, ts.do("POST", path, "jdoe", big).Code, http.StatusRequestEntityTooLarge)
+}
+
+func TestApply(t *testing.T) {
+	ts := newTestServer(t)
+	ctx := context.Background()
+	user := ts.login(t, "jdoe")
+
+	for _, body := range []string{
+		`{"name": "web", "image": "nginx:1", "port": "8080", "envs": [{"key": "MODE", "value": "test"}]}`,
+		`{"name": "old", "image": "nginx:1"}`,
+	} {
+		w := ts.do("POST", "/v1/apps", "jdoe", body)
+		assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	}
+
+	manifest := `
+apps:
+- name: web
+  image: nginx:2
+  port: "8080"
+  envs:
+  - key: MODE
+    value: prod
+- name: api
+  image: nginx:1
+  visibility: cluster-local
+  ttl: 24h
+`
+	apply := func(query string, status int) ApplyResponse {
+		t.Helper()
+		w := ts.do("POST", "/v1/apply"+query, "jdoe", manifest)
+		assert.Equal(t, w.Code, status, w.Body.String())
+		resp := ApplyResponse{}
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	// The dry run reports the changes without applying them.
+	resp := apply("?prune=true&dryRun=true", http.StatusOK)
+	assert.Assert(t, resp.DryRun)
+	assert.DeepEqual(t, resp.Apps, []AppDiff{
+		{Name: "web", Action: ApplyUpdate, Changes: []FieldChange{
+			{Field: "image", Current: "nginx:1", Desired: "nginx:2"},
+			{Field: "envs.MODE", Current: "test", Desired: "prod"},
+		}},
+		{Name: "api", Action: ApplyCreate, Changes: []FieldChange{
+			{Field: "image", Desired: "nginx:1"},
+			{Field: "visibility", Current: knative.VisibilityPublic, Desired: knative.VisibilityClusterLocal},
+			{Field: "ttl", Desired: "24h0m0s"},
+		}},
+		{Name: "old", Action: ApplyDelete, Changes: []FieldChange{{Field: "image", Current: "nginx:1"}}},
+	})
+	_, err := ts.serving.ServingV1().Services(user.Space).Get(ctx, "api", metav1.GetOptions{})
+	assert.Assert(t, err != nil)
+
+	// Without prune, the apps missing from the manifest are kept.
+	resp = apply("?dryRun=true", http.StatusOK)
+	assert.Equal(t, len(resp.Apps), 2)
+
+	resp = apply("?prune=true", http.StatusOK)
+	assert.Assert(t, !resp.DryRun)
+	for _, app := range resp.Apps {
+		assert.Equal(t, app.Error, "")
+		assert.Equal(t, app.Deployment != 0, app.Action != ApplyDelete, app.Name)
+	}
+	service, err := ts.serving.ServingV1().Services(user.Space).Get(ctx, "web", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, service.Spec.Template.Spec.Containers[0].Image, "nginx:2")
+	_, err = ts.serving.ServingV1().Services(user.Space).Get(ctx, "api", metav1.GetOptions{})
+	assert.NilError(t, err)
+	_, err = ts.serving.ServingV1().Services(user.Space).Get(ctx, "old", metav1.GetOptions{})
+	assert.Assert(t, err != nil)
+	event := ts.audited(t)[0]
+	assert.Equal(t, event.Action, "apply")
+	assert.Equal(t, event.Detail, "1 created, 1 updated, 1 deleted")
+
+	// Applying again changes nothing, not even the expiry of the ttl.
+	expiresAt := ts.app(t, user.Space, "api").Annotations[util.ExpiresAtAnnotation]
+	resp = apply("?prune=true", http.StatusOK)
+	for _, app := range resp.Apps {
+		assert.Equal(t, app.Action, ApplyUnchanged, app.Name)
+	}
+	assert.Equal(t, ts.app(t, user.Space, "api").Annotations[util.ExpiresAtAnnotation], expiresAt)
+
+	// The export is the manifest applied.
+	w := ts.do("GET", "/v1/export?format=yaml", "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	exported := Manifest{}
+	assert.NilError(t, yaml.Unmarshal(w.Body.Bytes(), &exported))
+	assert.DeepEqual(t, exported.Apps, []App{
+		{Name: "api", Image: "nginx:1", Visibility: knative.VisibilityClusterLocal, TTL: "24h0m0s"},
+		{Name: "web", Image: "nginx:2", Port: "8080", Envs: []Env{{Key: "MODE", Value: "prod"}},
+			Visibility: knative.VisibilityPublic},
+	})
+	w = ts.do("GET", "/v1/export", "jdoe", "")
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+	assert.Equal(t, len(exported.Apps), 2)
+	assert.Equal(t, ts.do("GET", "/v1/export?format=xml", "jdoe", "").Code, http.StatusBadRequest)
+
+	// A new ttl updates the app, and no ttl removes its expiry.
+	manifest = `{"apps": [{"name": "api", "image": "nginx:1", "visibility": "cluster-local", "ttl": "1440m"}]}`
+	resp = apply("?dryRun=true", http.StatusOK)
+	assert.Equal(t, resp.Apps[0].Action, ApplyUnchanged)
+	manifest = `{"apps": [{"name": "api", "image": "nginx:1", "visibility": "cluster-local", "ttl": "48h"}]}`
+	resp = apply("?dryRun=true", http.StatusOK)
+	assert.DeepEqual(t, resp.Apps[0].Changes, []FieldChange{{Field: "ttl", Current: "24h0m0s", Desired: "48h0m0s"}})
+	manifest = `{"apps": [{"name": "api", "image": "nginx:1", "visibility": "cluster-local"}]}`
+	resp = apply("", http.StatusOK)
+	assert.DeepEqual(t, resp.Apps[0].Changes, []FieldChange{{Field: "ttl", Current: "24h0m0s"}})
+	_, expires := knative.ExpiresAt(ts.app(t, user.Space, "api"))
+	assert.Assert(t, !expires)
+
+	assert.Equal(t, ts.do("POST", "/v1/apply?prune=maybe", "jdoe", manifest).Code, http.StatusBadRequest)
+	assert.Equal(t, ts.do("POST", "/v1/apply", "jdoe", `{"apps": [{"name": "web", "image": "nginx"},
+		{"name": "web", "image": "nginx"}]}`).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, ts.do("POST", "/v1/apply", "jdoe", `{"apps": [{"name": "web"}]}`).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, ts.do("POST", "/v1/apply", "jdoe", "apps: [").Code, http.StatusBadRequest)
+}
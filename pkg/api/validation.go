@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/platform9/app-controller/pkg/knative"
+)
+
+const (
+	// Maximum size of the body of an app request.
+	maxAppBodySize = 64 << 10
+	// Maximum size of a manifest of apps.
+	maxManifestBodySize = 1 << 20
+)
+
+// FieldError is an invalid field of a request, e.g. "envs[1].key".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError response structure, with every invalid field of the request.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Respond with the invalid fields of the request.
+func writeValidationError(w http.ResponseWriter, errs []FieldError) {
+	writeJSONStatus(w, http.StatusUnprocessableEntity, ValidationError{Errors: errs})
+}
+
+// Validate the fields of the app, named after the path of the app in the
+// request, e.g. "apps[0]." in a manifest.
+func (app *App) validate(path string) []FieldError {
+	errs := []FieldError{}
+	add := func(field string, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: path + field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if app.Name == "" {
+		add("name", "is required")
+	} else {
+		for _, msg := range validation.IsDNS1035Label(app.Name) {
+			add("name", "%s", msg)
+		}
+	}
+
+	if app.Image == "" {
+		add("image", "is required")
+	} else if _, err := name.ParseReference(app.Image); err != nil {
+		add("image", "is not a valid image reference: %v", err)
+	}
+
+	if app.Port != "" {
+		port, err := strconv.Atoi(app.Port)
+		if err != nil {
+			add("port", "must be a number")
+		} else {
+			for _, msg := range validation.IsValidPortNum(port) {
+				add("port", "%s", msg)
+			}
+		}
+	}
+
+	keys := map[string]int{}
+	for i, env := range app.Envs {
+		field := fmt.Sprintf("envs[%d].key", i)
+		if env.Key == "" {
+			add(field, "is required")
+			continue
+		}
+		for _, msg := range validation.IsEnvVarName(env.Key) {
+			add(field, "%s", msg)
+		}
+		if first, ok := keys[env.Key]; ok {
+			add(field, "duplicates envs[%d].key %v", first, env.Key)
+			continue
+		}
+		keys[env.Key] = i
+	}
+
+	if (app.UserName == "") != (app.Password == "") {
+		add("password", "must be set with username")
+	}
+
+	if app.TTL != "" {
+		if ttl, err := time.ParseDuration(app.TTL); err != nil || ttl <= 0 {
+			add("ttl", "must be a positive duration, e.g. 24h")
+		}
+	}
+
+	if !knative.IsVisibility(app.Visibility) {
+		add("visibility", "must be %v or %v", knative.VisibilityPublic, knative.VisibilityClusterLocal)
+	}
+	return errs
+}
+
+// Duration of a valid app before it is deleted, 0 to keep it.
+func (app *App) ttl() time.Duration {
+	ttl, _ := time.ParseDuration(app.TTL)
+	return ttl
+}
+
+// Read the body of the request, up to limit bytes. Responds with the error
+// status otherwise.
+func readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	log := requestLogger(r)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		if strings.Contains(err.Error(), "request body too large") {
+			http.Error(w, fmt.Sprintf("Request body is over %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		log.Errorf("Error while reading data in request body. Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return body, true
+}
+
+// Decode the JSON data into value, without unknown fields. Responds with
+// the error status otherwise.
+func decodeJSON(w http.ResponseWriter, r *http.Request, data []byte, value interface{}) bool {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(value)
+	if err == nil {
+		return true
+	}
+	requestLogger(r).Errorf("Invalid request body. Error: %v", err)
+
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		writeValidationError(w, []FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}})
+		return false
+	}
+	if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+		unquoted, errQuote := strconv.Unquote(field)
+		if errQuote == nil {
+			field = unquoted
+		}
+		writeValidationError(w, []FieldError{{Field: field, Message: "is not a known field"}})
+		return false
+	}
+	http.Error(w, "Invalid request body", http.StatusBadRequest)
+	return false
+}
+
+// Invalid fields reported by knative rejecting an app.
+func rejectedFields(err error) []FieldError {
+	errs := []FieldError{}
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			errs = append(errs, FieldError{Field: cause.Field, Message: cause.Message})
+		}
+	}
+	if len(errs) == 0 {
+		errs = append(errs, FieldError{Field: "spec", Message: err.Error()})
+	}
+	return errs
+}
+
+/*
+-- Validate app
+1. Check the fields of the app, as done before creating it.
+2. Submit the app to the server-side dry run of knative.
+3. Respond with every invalid field, nothing is deployed.
+*/
+func (s *Server) validateApp(w http.ResponseWriter, r *http.Request) {
+	log := requestLogger(r)
+	log.Info("***** Validate App *****")
+
+	_, userDB, ok := s.validateUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, ok := readBody(w, r, maxAppBodySize)
+	if !ok {
+		return
+	}
+	app := App{}
+	schemaErrs, ok := decodeSchema(w, r, body, "App", &app)
+	if !ok {
+		return
+	}
+	if errs := mergeFieldErrors(app.validate(""), schemaErrs); len(errs) > 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	secretname := ""
+	if app.UserName != "" {
+		secretname = app.Name
+	}
+	err := s.Clients.DryRunApp(r.Context(), app.Name, userDB.Space, app.Image, app.envVars(), app.Port,
+		secretname, GetUserPlan(userDB), app.ttl(), app.Visibility)
+	if err != nil {
+		if knative.IsRejected(err) {
+			log.Infof("App %v rejected by the dry run. Error: %v", app.Name, err)
+			writeValidationError(w, rejectedFields(err))
+			return
+		}
+		log.Errorf("Error while running the dry run of app %v. Error: %v", app.Name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Infof("App %v is valid. Space: %v", app.Name, userDB.Space)
+	w.WriteHeader(http.StatusOK)
+}
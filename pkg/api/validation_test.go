@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	k8stesting "k8s.io/client-go/testing"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// Fields of the validation errors.
+func errorFields(errs []FieldError) []string {
+	fields := []string{}
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func TestAppValidate(t *testing.T) {
+	valid := App{
+		Name:       "web",
+		Image:      "docker.io/jdoe/web:1",
+		Port:       "8080",
+		Envs:       []Env{{Key: "MODE", Value: "test"}, {Key: "LOG_LEVEL", Value: "debug"}},
+		UserName:   "jdoe",
+		Password:   "secret",
+		TTL:        "24h",
+		Visibility: "cluster-local",
+	}
+	assert.DeepEqual(t, valid.validate(""), []FieldError{})
+
+	invalid := App{
+		Name:       "1-Web",
+		Image:      "docker.io/jdoe/Web:1",
+		Port:       "70000",
+		Envs:       []Env{{Key: "MODE"}, {Key: "1MODE"}, {Key: "MODE"}, {}},
+		UserName:   "jdoe",
+		TTL:        "-1h",
+		Visibility: "private",
+	}
+	assert.DeepEqual(t, errorFields(invalid.validate("apps[2].")), []string{
+		"apps[2].name",
+		"apps[2].image",
+		"apps[2].port",
+		"apps[2].envs[1].key",
+		"apps[2].envs[2].key",
+		"apps[2].envs[3].key",
+		"apps[2].password",
+		"apps[2].ttl",
+		"apps[2].visibility",
+	})
+	assert.DeepEqual(t, errorFields((&App{Port: "http"}).validate("")), []string{"name", "image", "port"})
+	assert.DeepEqual(t, errorFields((&App{Name: strings.Repeat("a", 64), Image: "nginx"}).validate("")), []string{"name"})
+}
+
+func TestCreateAppValidation(t *testing.T) {
+	ts := newTestServer(t)
+	ts.login(t, "jdoe")
+
+	validationErrors := func(body string) []string {
+		t.Helper()
+		w := ts.do("POST", "/v1/apps", "jdoe", body)
+		assert.Equal(t, w.Code, http.StatusUnprocessableEntity, w.Body.String())
+		resp := ValidationError{}
+		assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return errorFields(resp.Errors)
+	}
+
+	// Every invalid field is reported at once.
+	assert.DeepEqual(t, validationErrors(`{"name": "Web", "image": "nginx", "port": "0",
+		"envs": [{"key": "MODE", "value": "a"}, {"key": "MODE", "value": "b"}]}`), []string{"name", "port", "envs[1].key"})
+	assert.DeepEqual(t, validationErrors(`{"name": "web", "image": "nginx", "replicas": 3}`), []string{"replicas"})
+	assert.DeepEqual(t, validationErrors(`{"name": "web", "image": "nginx", "port": 8080}`), []string{"port"})
+	// Fields only checked by the schema of the OpenAPI document are reported too.
+	assert.DeepEqual(t, validationErrors(`{"name": "web", "image": "nginx", "envs": [{"key": "MODE", "secret": true}]}`),
+		[]string{"envs.secret"})
+
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "web"`).Code, http.StatusBadRequest)
+	big := `{"name": "web", "image": "nginx", "envs": [{"key": "DATA", "value": "` + strings.Repeat("a", maxAppBodySize) + `"}]}`
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", big).Code, http.StatusRequestEntityTooLarge)
+}
+
+func TestValidateApp(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.login(t, "jdoe")
+
+	// The fake clientset ignores the dry run, so the rejections of knative
+	// are simulated and the other services are not created.
+	ts.serving.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		service := action.(k8stesting.CreateAction).GetObject().(*servingv1.Service)
+		if service.Name == "rejected" {
+			return true, nil, apierrors.NewInvalid(schema.GroupKind{Group: "serving.knative.dev", Kind: "Service"}, service.Name,
+				field.ErrorList{field.Invalid(field.NewPath("spec.template.spec.containers[0].ports"), "8012", "reserved port")})
+		}
+		return true, service, nil
+	})
+
+	w := ts.do("POST", "/v1/apps/validate", "jdoe", `{"name": "web", "image": "nginx", "port": "8080"}`)
+	assert.Equal(t, w.Code, http.StatusOK, w.Body.String())
+	_, err := ts.serving.ServingV1().Services(user.Space).Get(context.Background(), "web", metav1.GetOptions{})
+	assert.Assert(t, apierrors.IsNotFound(err))
+
+	w = ts.do("POST", "/v1/apps/validate", "jdoe", `{"name": "rejected", "image": "nginx", "port": "8012"}`)
+	assert.Equal(t, w.Code, http.StatusUnprocessableEntity, w.Body.String())
+	resp := ValidationError{}
+	assert.NilError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.DeepEqual(t, errorFields(resp.Errors), []string{"spec.template.spec.containers[0].ports"})
+
+	w = ts.do("POST", "/v1/apps/validate", "jdoe", `{"name": "web", "image": ""}`)
+	assert.Equal(t, w.Code, http.StatusUnprocessableEntity, w.Body.String())
+}
+
+func TestSetVisibilityErrors(t *testing.T) {
+	ts := newTestServer(t)
+	ts.login(t, "jdoe")
+	assert.Equal(t, ts.do("POST", "/v1/apps", "jdoe", `{"name": "web", "image": "nginx"}`).Code, http.StatusOK)
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"visibility": "private"}`, http.StatusBadRequest},
+		{`{"visibility": ""}`, http.StatusBadRequest},
+		{`{"visibility": "public"`, http.StatusBadRequest},
+		{`{"visibility": "public", "replicas": 3}`, http.StatusUnprocessableEntity},
+		{`{"visibility": 1}`, http.StatusUnprocessableEntity},
+		{`{"visibility": "` + strings.Repeat("a", maxAppBodySize) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		w := ts.do("PUT", "/v1/apps/web/visibility", "jdoe", tc.body)
+		assert.Equal(t, w.Code, tc.status, w.Body.String())
+	}
+
+	// Only a missing app is not found, other errors of knative are not.
+	ts.serving.PrependReactor("get", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("knative is down")
+	})
+	assert.Equal(t, ts.do("PUT", "/v1/apps/web/visibility", "jdoe", `{"visibility": "public"}`).Code, http.StatusInternalServerError)
+}
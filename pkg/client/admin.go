@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// ListPlans returns the subscription plans.
+func (c *Client) ListPlans(ctx context.Context) ([]objects.Plan, error) {
+	plans := []objects.Plan{}
+	if err := c.do(ctx, http.MethodGet, "/v1/plans", nil, nil, &plans); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// ListUsers returns all the users, admin only.
+func (c *Client) ListUsers(ctx context.Context) ([]objects.User, error) {
+	users := []objects.User{}
+	if err := c.do(ctx, http.MethodGet, "/v1/users", nil, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SetUserPlan changes the subscription plan of a user, admin only.
+func (c *Client) SetUserPlan(ctx context.Context, userID int, plan string) (*objects.User, error) {
+	user := &objects.User{}
+	body := map[string]string{"plan": plan}
+	if err := c.do(ctx, http.MethodPut, route("/v1/users/%s/plan", userID), nil, body, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListAuditEvents returns the audit events of the namespace of the user, or
+// with All of every user, the latest first.
+func (c *Client) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]objects.AuditEvent, error) {
+	query := url.Values{}
+	if filter.All {
+		query.Set("all", "true")
+	}
+	for name, value := range map[string]string{"actor": filter.Actor, "action": filter.Action, "space": filter.Space} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		query.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	events := []objects.AuditEvent{}
+	if err := c.do(ctx, http.MethodGet, "/v1/audit", query, nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Log level of the service.
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// LogLevel returns the log level of the service, admin only.
+func (c *Client) LogLevel(ctx context.Context) (string, error) {
+	level := logLevel{}
+	if err := c.do(ctx, http.MethodGet, "/v1/log/level", nil, nil, &level); err != nil {
+		return "", err
+	}
+	return level.Level, nil
+}
+
+// SetLogLevel changes the log level of the service, e.g. to debug, admin only.
+func (c *Client) SetLogLevel(ctx context.Context, level string) error {
+	return c.do(ctx, http.MethodPut, "/v1/log/level", nil, logLevel{Level: level}, nil)
+}
+
+// Health checks the service is alive.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	health := &Health{}
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+// Ready returns the readiness checks of the service, along with
+// ErrUnavailable if any failed. It is not retried.
+func (c *Client) Ready(ctx context.Context) (*Health, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/readyz", nil, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	health := &Health{}
+	if errJSON := json.Unmarshal(resp.Body, health); errJSON != nil {
+		health = nil
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return health, newError(resp)
+	}
+	return health, nil
+}
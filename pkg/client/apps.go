@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/platform9/app-controller/pkg/knative"
+)
+
+// Login sets up the user of the token on its first login, with its namespace.
+func (c *Client) Login(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/apps/login", nil, nil, nil)
+}
+
+// ListApps returns the apps of the user.
+func (c *Client) ListApps(ctx context.Context) ([]knative.AppView, error) {
+	list := knative.AppListView{}
+	if err := c.do(ctx, http.MethodGet, "/v1/apps", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// GetApp returns an app of the user by name.
+func (c *Client) GetApp(ctx context.Context, name string) (*knative.AppView, error) {
+	app := &knative.AppView{}
+	if err := c.do(ctx, http.MethodGet, route("/v1/apps/%s", name), nil, nil, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// CreateApp deploys a new app.
+func (c *Client) CreateApp(ctx context.Context, app App) error {
+	return c.do(ctx, http.MethodPost, "/v1/apps", nil, app, nil)
+}
+
+// ValidateApp checks the spec of an app without deploying it, including
+// with the server-side dry run of knative.
+func (c *Client) ValidateApp(ctx context.Context, app App) error {
+	return c.do(ctx, http.MethodPost, "/v1/apps/validate", nil, app, nil)
+}
+
+// DeleteApp deletes an app by name.
+func (c *Client) DeleteApp(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, route("/v1/apps/%s", name), nil, nil, nil)
+}
+
+// SetVisibility makes an app public or cluster-local.
+func (c *Client) SetVisibility(ctx context.Context, name string, visibility string) error {
+	body := map[string]string{"visibility": visibility}
+	return c.do(ctx, http.MethodPut, route("/v1/apps/%s/visibility", name), nil, body, nil)
+}
+
+// ListDeployments returns the deployments of an app, the latest first.
+func (c *Client) ListDeployments(ctx context.Context, name string) ([]Deployment, error) {
+	deployments := []Deployment{}
+	if err := c.do(ctx, http.MethodGet, route("/v1/apps/%s/deployments", name), nil, nil, &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+// Redeploy deploys the spec of a past deployment of an app again, with the
+// registry credentials and the values of the environment variables of opts.
+func (c *Client) Redeploy(ctx context.Context, name string, id int, opts RedeployOptions) (*Deployment, error) {
+	var body interface{}
+	if opts.UserName != "" || opts.Password != "" || len(opts.Envs) > 0 {
+		body = opts
+	}
+	deployment := &Deployment{}
+	path := route("/v1/apps/%s/deployments/%s/redeploy", name, id)
+	if err := c.do(ctx, http.MethodPost, path, nil, body, deployment); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// ListDomains returns the custom domains of an app.
+func (c *Client) ListDomains(ctx context.Context, name string) ([]Domain, error) {
+	domains := []Domain{}
+	if err := c.do(ctx, http.MethodGet, route("/v1/apps/%s/domains", name), nil, nil, &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// AddDomain adds a custom domain to an app, to verify once its token is
+// published in its TXT record.
+func (c *Client) AddDomain(ctx context.Context, name string, domain string) (*Domain, error) {
+	added := &Domain{}
+	body := map[string]string{"domain": domain}
+	if err := c.do(ctx, http.MethodPost, route("/v1/apps/%s/domains", name), nil, body, added); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// VerifyDomain checks the TXT record of a custom domain and maps it to the app.
+func (c *Client) VerifyDomain(ctx context.Context, name string, domain string) (*Domain, error) {
+	verified := &Domain{}
+	path := route("/v1/apps/%s/domains/%s/verify", name, domain)
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, verified); err != nil {
+		return nil, err
+	}
+	return verified, nil
+}
+
+// DeleteDomain removes a custom domain from an app.
+func (c *Client) DeleteDomain(ctx context.Context, name string, domain string) error {
+	return c.do(ctx, http.MethodDelete, route("/v1/apps/%s/domains/%s", name, domain), nil, nil, nil)
+}
+
+// Apply creates, updates and with Prune deletes the apps to match the
+// manifest. The result is returned along with the error if some apps failed.
+func (c *Client) Apply(ctx context.Context, manifest Manifest, opts ApplyOptions) (*ApplyResult, error) {
+	query := url.Values{}
+	if opts.Prune {
+		query.Set("prune", strconv.FormatBool(opts.Prune))
+	}
+	if opts.DryRun {
+		query.Set("dryRun", strconv.FormatBool(opts.DryRun))
+	}
+	resp, err := c.send(ctx, http.MethodPost, "/v1/apply", query, manifest, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ApplyResult{}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		// The outcome of every app is returned when some failed.
+		if resp.StatusCode != http.StatusInternalServerError || json.Unmarshal(resp.Body, result) != nil {
+			result = nil
+		}
+		return result, newError(resp)
+	}
+	if err = json.Unmarshal(resp.Body, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Export returns the apps of the user as a manifest.
+func (c *Client) Export(ctx context.Context) (*Manifest, error) {
+	manifest := &Manifest{}
+	if err := c.do(ctx, http.MethodGet, "/v1/export", nil, nil, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// GetQuota returns the apps deployed against the limits of the plan of the user.
+func (c *Client) GetQuota(ctx context.Context) (*knative.Quota, error) {
+	quota := &knative.Quota{}
+	if err := c.do(ctx, http.MethodGet, "/v1/quota", nil, nil, quota); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// DeleteAccount deletes the user of the token, with all its apps.
+func (c *Client) DeleteAccount(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/v1/me", nil, nil, nil)
+}
@@ -0,0 +1,167 @@
+// Package client is the Go client of the app-controller API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Retries of the idempotent requests by default.
+	defaultMaxRetries = 3
+	// Wait before the first retry by default, doubled at each retry.
+	defaultRetryWait = 500 * time.Millisecond
+)
+
+// Client of the app-controller API.
+type Client struct {
+	// URL of the API, e.g. http://localhost:6112.
+	BaseURL string
+	// Returns the token sent as bearer, e.g. the ID token of Auth0. No
+	// token is sent if it is empty.
+	Token      func(ctx context.Context) (string, error)
+	HTTPClient *http.Client
+	// Retries of the GET, PUT and DELETE requests failing with a network
+	// error or a 502, 503 or 504 status. The wait before a retry starts
+	// at RetryWait and doubles at each retry.
+	MaxRetries int
+	RetryWait  time.Duration
+}
+
+// New returns a client of the API at baseURL, authenticated with the token.
+func New(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      StaticToken(token),
+		HTTPClient: http.DefaultClient,
+		MaxRetries: defaultMaxRetries,
+		RetryWait:  defaultRetryWait,
+	}
+}
+
+// StaticToken returns the same token for every request.
+func StaticToken(token string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+// Response of a request, read at once.
+type response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Check if a request with the method can be sent again safely.
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead ||
+		method == http.MethodPut || method == http.MethodDelete
+}
+
+// Check if a request failing with the status can succeed later.
+func retryable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// Send the request with the body marshalled as JSON, if any, and return the
+// response. Failures are retried if retry is set.
+func (c *Client) send(ctx context.Context, method string, path string, query url.Values, body interface{},
+	retry bool) (*response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	token, err := c.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get token. Error: %v", err)
+	}
+
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		resp, err := c.sendOnce(ctx, method, target, token, data)
+		last := !retry || attempt >= c.MaxRetries
+		if err == nil && (last || !retryable(resp.StatusCode)) {
+			return resp, nil
+		}
+		if err != nil && (last || ctx.Err() != nil) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, method string, target string, token string, data []byte) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}, nil
+}
+
+// Send the request and decode the JSON response into out, if set. Error
+// responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{},
+	out interface{}) error {
+	resp, err := c.send(ctx, method, path, query, body, idempotent(method))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return newError(resp)
+	}
+	if out == nil || len(resp.Body) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(resp.Body, out); err != nil {
+		return fmt.Errorf("Invalid response of %v %v. Error: %v", method, path, err)
+	}
+	return nil
+}
+
+// Path of the route with the escaped values, e.g. "/v1/apps/%s".
+func route(format string, values ...interface{}) string {
+	escaped := make([]interface{}, len(values))
+	for i, value := range values {
+		escaped[i] = url.PathEscape(fmt.Sprint(value))
+	}
+	return fmt.Sprintf(format, escaped...)
+}
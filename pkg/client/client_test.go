@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	"github.com/platform9/app-controller/pkg/api"
+	"github.com/platform9/app-controller/pkg/api/apitest"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// Serve the API router with the users in memory and fake clusters. The
+// bearer token is the nickname of the user, e.g. "jdoe".
+func newTestAPI(t *testing.T) *httptest.Server {
+	apitest.SetOptions(t)
+	fakes := apitest.NewFakes()
+	s := api.NewServer(fakes.Store, fakes.Clients())
+	s.ValidateToken = apitest.Token
+	server := httptest.NewServer(api.New(s))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Client of the test API as the user.
+func newTestClient(server *httptest.Server, user string) *Client {
+	c := New(server.URL, user)
+	c.RetryWait = time.Millisecond
+	return c
+}
+
+func TestApps(t *testing.T) {
+	server := newTestAPI(t)
+	c := newTestClient(server, "jdoe")
+	ctx := context.Background()
+
+	err := newTestClient(server, "").Login(ctx)
+	assert.Assert(t, errors.Is(err, ErrForbidden), err)
+	assert.NilError(t, c.Login(ctx))
+
+	assert.NilError(t, c.CreateApp(ctx, App{Name: "web", Image: "nginx:1", Port: "8080",
+		Envs: []Env{{Key: "MODE", Value: "test"}}}))
+	apps, err := c.ListApps(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(apps), 1)
+	assert.Equal(t, apps[0].Name, "web")
+	assert.Equal(t, apps[0].Visibility, knative.VisibilityPublic)
+
+	assert.NilError(t, c.SetVisibility(ctx, "web", knative.VisibilityClusterLocal))
+	app, err := c.GetApp(ctx, "web")
+	assert.NilError(t, err)
+	assert.Equal(t, app.Visibility, knative.VisibilityClusterLocal)
+	err = c.SetVisibility(ctx, "missing", knative.VisibilityPublic)
+	assert.Assert(t, errors.Is(err, ErrNotFound), err)
+
+	// Invalid specs are reported field by field.
+	err = c.CreateApp(ctx, App{Name: "Web", Image: "nginx", Port: "0"})
+	assert.Assert(t, errors.Is(err, ErrInvalid), err)
+	apiErr := &Error{}
+	assert.Assert(t, errors.As(err, &apiErr))
+	assert.Equal(t, len(apiErr.Fields), 2)
+	assert.Assert(t, apiErr.RequestID != "")
+	err = c.ValidateApp(ctx, App{Name: "web", Image: "nginx", TTL: "soon"})
+	assert.Assert(t, errors.Is(err, ErrInvalid), err)
+
+	quota, err := c.GetQuota(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, quota.Apps, knative.QuotaUsage{Used: 1, Limit: 2})
+
+	deployments, err := c.ListDeployments(ctx, "web")
+	assert.NilError(t, err)
+	assert.Equal(t, len(deployments), 1)
+	assert.Equal(t, deployments[0].Spec.Image, "nginx:1")
+	redeployed, err := c.Redeploy(ctx, "web", deployments[0].ID, RedeployOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, redeployed.Outcome, objects.DeploymentSucceeded)
+	_, err = c.Redeploy(ctx, "api", deployments[0].ID, RedeployOptions{})
+	assert.Assert(t, errors.Is(err, ErrNotFound), err)
+
+	manifest := Manifest{Apps: []App{{Name: "api", Image: "nginx:2"}}}
+	result, err := c.Apply(ctx, manifest, ApplyOptions{Prune: true, DryRun: true})
+	assert.NilError(t, err)
+	assert.Assert(t, result.DryRun)
+	assert.Equal(t, len(result.Apps), 2)
+	result, err = c.Apply(ctx, manifest, ApplyOptions{Prune: true})
+	assert.NilError(t, err)
+	assert.Equal(t, result.Apps[0].Action, "create")
+	assert.Equal(t, result.Apps[1].Action, "delete")
+	exported, err := c.Export(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, exported.Apps, []App{{Name: "api", Image: "nginx:2", Visibility: knative.VisibilityPublic}})
+	_, err = c.Apply(ctx, Manifest{Apps: []App{{Name: "api"}}}, ApplyOptions{})
+	assert.Assert(t, errors.Is(err, ErrInvalid), err)
+
+	events, err := c.ListAuditEvents(ctx, AuditFilter{Action: "apply"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 1)
+
+	assert.NilError(t, c.DeleteApp(ctx, "api"))
+	apps, err = c.ListApps(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(apps), 0)
+	assert.NilError(t, c.DeleteAccount(ctx))
+}
+
+func TestRedeployDeletedApp(t *testing.T) {
+	server := newTestAPI(t)
+	c := newTestClient(server, "jdoe")
+	ctx := context.Background()
+	assert.NilError(t, c.Login(ctx))
+
+	assert.NilError(t, c.CreateApp(ctx, App{Name: "web", Image: "nginx", Envs: []Env{{Key: "TOKEN", Value: "secret"}}}))
+	deployments, err := c.ListDeployments(ctx, "web")
+	assert.NilError(t, err)
+	assert.NilError(t, c.DeleteApp(ctx, "web"))
+
+	// The values of the environment variables are gone with the app.
+	_, err = c.Redeploy(ctx, "web", deployments[0].ID, RedeployOptions{})
+	assert.Assert(t, errors.Is(err, ErrInvalid), err)
+	redeployed, err := c.Redeploy(ctx, "web", deployments[0].ID, RedeployOptions{Envs: []Env{{Key: "TOKEN", Value: "rotated"}}})
+	assert.NilError(t, err)
+	assert.Equal(t, redeployed.Outcome, objects.DeploymentSucceeded)
+	assert.DeepEqual(t, redeployed.Spec.Envs, []Env{{Key: "TOKEN"}})
+
+	app, err := c.GetApp(ctx, "web")
+	assert.NilError(t, err)
+	env := app.Spec.Template.Spec.Containers[0].Env
+	assert.Equal(t, len(env), 1)
+	assert.Equal(t, env[0].Value, "rotated")
+}
+
+func TestAdmin(t *testing.T) {
+	server := newTestAPI(t)
+	ctx := context.Background()
+	user := newTestClient(server, "jdoe")
+	admin := newTestClient(server, "admin")
+	assert.NilError(t, user.Login(ctx))
+
+	plans, err := user.ListPlans(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(plans), 2)
+	for _, plan := range plans {
+		if plan.Name == "pro" {
+			assert.Equal(t, plan.Retention, time.Hour)
+		}
+	}
+
+	_, err = user.ListUsers(ctx)
+	assert.Assert(t, errors.Is(err, ErrForbidden), err)
+	users, err := admin.ListUsers(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(users), 1)
+
+	updated, err := admin.SetUserPlan(ctx, users[0].ID, "pro")
+	assert.NilError(t, err)
+	assert.Equal(t, updated.Plan, "pro")
+	_, err = admin.SetUserPlan(ctx, 99, "pro")
+	assert.Assert(t, errors.Is(err, ErrNotFound), err)
+	_, err = admin.SetUserPlan(ctx, users[0].ID, "enterprise")
+	assert.Assert(t, errors.Is(err, ErrBadRequest), err)
+	assert.ErrorContains(t, err, "Unknown plan enterprise")
+
+	assert.NilError(t, admin.SetLogLevel(ctx, "debug"))
+	level, err := admin.LogLevel(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, level, "debug")
+	assert.NilError(t, admin.SetLogLevel(ctx, "info"))
+
+	health, err := user.Health(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, health.Status, "ok")
+}
+
+func TestRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer token")
+		// Fail the first two attempts of each request.
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status": "ok"}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	c := New(server.URL, "token")
+	c.RetryWait = time.Millisecond
+
+	health, err := c.Health(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, health.Status, "ok")
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(3))
+
+	// Requests that aren't idempotent are sent once.
+	atomic.StoreInt32(&calls, 0)
+	err = c.CreateApp(ctx, App{Name: "web", Image: "nginx"})
+	assert.Assert(t, errors.Is(err, ErrUnavailable), err)
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+
+	// Retries stop after MaxRetries.
+	atomic.StoreInt32(&calls, 0)
+	c.MaxRetries = 1
+	_, err = c.Health(ctx)
+	assert.Assert(t, errors.Is(err, ErrServer) || errors.Is(err, ErrUnavailable), err)
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(2))
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/platform9/app-controller/pkg/util"
+)
+
+// Errors of the response status codes, matched by the Error of a response
+// with errors.Is.
+var (
+	// The request or its body is invalid, 400.
+	ErrBadRequest = errors.New("bad request")
+	// The token is missing, invalid or expired, or the user not an admin, 403.
+	ErrForbidden = errors.New("forbidden")
+	// The app, deployment, domain or user doesn't exist, 404.
+	ErrNotFound = errors.New("not found")
+	// The object already exists, 409.
+	ErrConflict = errors.New("conflict")
+	// The body of the request is too large, 413.
+	ErrTooLarge = errors.New("request too large")
+	// The fields of the request are invalid, listed in Error.Fields, 422.
+	ErrInvalid = errors.New("invalid")
+	// The maximum apps of the plan of the user are deployed, 429.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// The service is not ready, 503.
+	ErrUnavailable = errors.New("unavailable")
+	// The service failed to serve the request, 5xx.
+	ErrServer = errors.New("server error")
+)
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	// Body of the response, e.g. the reason of a 400.
+	Message string
+	// Invalid fields of a 422 response.
+	Fields []FieldError
+	// ID of the request, to find it in the logs of the service.
+	RequestID string
+}
+
+// Get the error of the response of the request.
+func newError(resp *response) *Error {
+	err := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+	if resp.StatusCode == http.StatusUnprocessableEntity {
+		invalid := struct {
+			Errors []FieldError `json:"errors"`
+		}{}
+		if json.Unmarshal(resp.Body, &invalid) == nil {
+			err.Fields = invalid.Errors
+			return err
+		}
+	}
+	err.Message = strings.TrimSpace(string(resp.Body))
+	return err
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("app-controller: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	for _, field := range e.Fields {
+		msg += fmt.Sprintf("; %s %s", field.Field, field.Message)
+	}
+	return msg
+}
+
+// Is matches the error of the status code, e.g. ErrNotFound for a 404.
+func (e *Error) Is(target error) bool {
+	return target == statusError(e.StatusCode)
+}
+
+// Error of the status code, nil if there is none.
+func statusError(status int) error {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusRequestEntityTooLarge:
+		return ErrTooLarge
+	case http.StatusUnprocessableEntity:
+		return ErrInvalid
+	case util.MaxAppDeployStatusCode:
+		return ErrQuotaExceeded
+	case http.StatusServiceUnavailable:
+		return ErrUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return ErrServer
+	}
+	return nil
+}
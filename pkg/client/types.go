@@ -0,0 +1,119 @@
+package client
+
+import (
+	"time"
+
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// App is the spec of an app, as created and applied.
+type App struct {
+	Name     string `json:"name"`
+	Image    string `json:"image"`
+	Port     string `json:"port,omitempty"`
+	Envs     []Env  `json:"envs,omitempty"`
+	UserName string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Optional duration after which the app is deleted, e.g. "24h".
+	TTL string `json:"ttl,omitempty"`
+	// Either "public" (default) or "cluster-local".
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// Env is an environment variable of an app.
+type Env struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Manifest of the apps of a namespace.
+type Manifest struct {
+	Apps []App `json:"apps"`
+}
+
+// ApplyOptions of an apply of a manifest.
+type ApplyOptions struct {
+	// Delete the apps missing from the manifest.
+	Prune bool
+	// Only report the changes, without applying them.
+	DryRun bool
+}
+
+// RedeployOptions of a redeploy of a past deployment.
+type RedeployOptions struct {
+	// Registry credentials of a private image.
+	UserName string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Values of the environment variables, which aren't recorded with the
+	// deployment. Required once the app is deleted.
+	Envs []Env `json:"envs,omitempty"`
+}
+
+// ApplyResult is the action of an apply on every app.
+type ApplyResult struct {
+	DryRun bool      `json:"dryRun"`
+	Apps   []AppDiff `json:"apps"`
+}
+
+// AppDiff is the action of an apply on an app, one of create, update,
+// delete or unchanged, with the changed fields.
+type AppDiff struct {
+	Name    string        `json:"name"`
+	Action  string        `json:"action"`
+	Changes []FieldChange `json:"changes,omitempty"`
+	// ID of the deployment of a created or updated app.
+	Deployment int    `json:"deployment,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// FieldChange is the change of a field of an app, e.g. "image" or "envs.MODE".
+type FieldChange struct {
+	Field   string `json:"field"`
+	Current string `json:"current,omitempty"`
+	Desired string `json:"desired,omitempty"`
+}
+
+// Deployment of an app, with the spec deployed.
+type Deployment struct {
+	objects.Deployment
+	Spec *App `json:"spec"`
+}
+
+// Domain is a custom domain of an app, with the TXT record to publish its
+// token in.
+type Domain struct {
+	objects.Domain
+	Record   string `json:"record"`
+	Verified bool   `json:"verified"`
+}
+
+// FieldError is an invalid field of a request, e.g. "envs[1].key".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// AuditFilter selects the audit events listed, empty fields match any value.
+type AuditFilter struct {
+	// Events of every user, admin only.
+	All    bool
+	Actor  string
+	Action string
+	Space  string
+	// Events created at or after Since.
+	Since time.Time
+	// Maximum number of events, the latest first.
+	Limit int
+}
+
+// Health is the status of the service, with the result of every check.
+type Health struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is the result of a check of the service.
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
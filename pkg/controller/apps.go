@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// DeleteApp deletes an app of the user with its registry secret, and then
+// detaches its custom domains. It is shared by the API, the apply prune and
+// the reaper, so that no domain is left behind.
+func DeleteApp(ctx context.Context, clients *knative.Clients, store db.DomainStore, user *objects.User, appName string) error {
+	if err := clients.DeleteApp(ctx, user.Space, appName); err != nil {
+		return err
+	}
+	removeAppDomains(ctx, clients, store, user, appName)
+	return nil
+}
+
+// Detach all the custom domains of an app. The domains whose mapping can't
+// be deleted are kept, to retry with the user deletion.
+func removeAppDomains(ctx context.Context, clients *knative.Clients, store db.DomainStore, user *objects.User, appName string) {
+	appDomains := []objects.Domain{}
+	if err := store.GetDomainsByApp(ctx, user.ID, appName, &appDomains); err != nil {
+		zap.S().Errorf("Failed to get domains of app %v from DB. Error: %v", appName, err)
+		return
+	}
+	for _, domain := range appDomains {
+		if err := clients.DeleteDomainMapping(ctx, user.Space, domain.Domain); err != nil {
+			continue
+		}
+		if err := store.RemoveDomain(ctx, &domain); err != nil {
+			zap.S().Errorf("Failed to remove domain %v from DB. Error: %v", domain.Domain, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1beta1 "knative.dev/serving/pkg/apis/serving/v1beta1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+func TestDeleteApp(t *testing.T) {
+	ctx := context.Background()
+	user := &objects.User{ID: 1, Space: "jdoe-abc"}
+	serving := servingfake.NewSimpleClientset(
+		&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: user.Space}},
+		&servingv1beta1.DomainMapping{ObjectMeta: metav1.ObjectMeta{Name: "www.example.com", Namespace: user.Space}},
+	)
+	clients := &knative.Clients{Kube: k8sfake.NewSimpleClientset(), Serving: serving}
+	store := db.NewMemoryStore()
+	for _, domain := range []objects.Domain{
+		{UserID: user.ID, Space: user.Space, App: "web", Domain: "www.example.com"},
+		{UserID: user.ID, Space: user.Space, App: "api", Domain: "api.example.com"},
+	} {
+		assert.NilError(t, store.AddDomain(ctx, &domain))
+	}
+
+	assert.NilError(t, DeleteApp(ctx, clients, store, user, "web"))
+
+	services, err := serving.ServingV1().Services(user.Space).List(ctx, metav1.ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(services.Items), 0)
+	mappings, err := serving.ServingV1beta1().DomainMappings(user.Space).List(ctx, metav1.ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(mappings.Items), 0)
+
+	// The domains of the other apps are kept.
+	domains := []objects.Domain{}
+	assert.NilError(t, store.GetDomainsByUser(ctx, user.ID, &domains))
+	assert.Equal(t, len(domains), 1)
+	assert.Equal(t, domains[0].App, "api")
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BackfillReport lists the namespaces labelled by a backfill.
+type BackfillReport struct {
+	Users     int      `json:"users"`
+	Labelled  []string `json:"labelled"`
+	Missing   []string `json:"missing"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// BackfillNamespaces sets the managed-by, owner and extra labels on the
+// existing namespaces of users, created before they were labelled.
+func BackfillNamespaces(ctx context.Context, clientset kubernetes.Interface, users []objects.User,
+	labels map[string]string) (*BackfillReport, error) {
+	report := &BackfillReport{Users: len(users), Labelled: []string{}, Missing: []string{}}
+	for _, user := range users {
+		if user.Space == "" {
+			continue
+		}
+		changed, err := knative.LabelNamespace(ctx, clientset, user.Space, user.Owner(), knative.NamespaceLabels(&user, labels))
+		if apierrors.IsNotFound(err) {
+			report.Missing = append(report.Missing, user.Space)
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("Failed to label namespace %v. Error: %v", user.Space, err)
+		}
+		if changed {
+			report.Labelled = append(report.Labelled, user.Space)
+		} else {
+			report.Unchanged++
+		}
+	}
+	return report, nil
+}
+
+// Backfill labels the namespaces of the users in DB, with the Pod Security
+// labels configured through options.
+func Backfill(ctx context.Context, clients *knative.Clients, store db.Store) (*BackfillReport, error) {
+	users := []objects.User{}
+	if err := store.GetUsers(ctx, &users); err != nil {
+		return nil, fmt.Errorf("Failed to get users from DB. Error: %v", err)
+	}
+	return BackfillNamespaces(ctx, clients.Kube, users, options.GetPodSecurityLabels())
+}
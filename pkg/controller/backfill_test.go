@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBackfillNamespaces(t *testing.T) {
+	ctx := context.Background()
+	bare := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bare"}}
+	clientset := k8sfake.NewSimpleClientset(bare)
+	users := []objects.User{
+		{ID: 7, Email: "owner@example.com", Space: "bare", Plan: "team", Issuer: "github"},
+		{ID: 8, Email: "gone@example.com", Space: "gone"},
+	}
+	podSecurity := map[string]string{"pod-security.kubernetes.io/enforce": "baseline"}
+
+	report, err := BackfillNamespaces(ctx, clientset, users, podSecurity)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, report, &BackfillReport{Users: 2, Labelled: []string{"bare"}, Missing: []string{"gone"}})
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "bare", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ns.Labels, map[string]string{
+		util.ManagedByLabel:                  util.ManagedByValue,
+		util.UserIDLabel:                     "7",
+		util.IssuerLabel:                     "github",
+		util.PlanLabel:                       "team",
+		"pod-security.kubernetes.io/enforce": "baseline",
+	})
+	assert.Equal(t, ns.Annotations[util.OwnerAnnotation], "owner@example.com")
+
+	// Running it again changes nothing.
+	report, err = BackfillNamespaces(ctx, clientset, users, podSecurity)
+	assert.NilError(t, err)
+	assert.Equal(t, report.Unchanged, 1)
+	assert.Equal(t, len(report.Labelled), 0)
+}
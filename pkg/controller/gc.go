@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+	"github.com/platform9/app-controller/pkg/util"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Actor recorded in the audit events of the garbage collector.
+	gcActor = "garbage-collector"
+)
+
+// DeleteUser removes the apps, secrets and namespace of a user and then the
+// user, its domains and deployments from the store. The user row is removed
+// last, so that a failure can be retried.
+func DeleteUser(ctx context.Context, clients *knative.Clients, store db.Store, user *objects.User, actor string) error {
+	err := deleteUser(ctx, clients, store, user)
+
+	outcome := objects.AuditSuccess
+	if err != nil {
+		outcome = objects.AuditFailure
+	}
+	auditErr := store.AddAuditEvent(ctx, &objects.AuditEvent{
+		Actor:   actor,
+		Action:  "delete-user",
+		Space:   user.Space,
+		Target:  fmt.Sprintf("%d", user.ID),
+		Outcome: outcome,
+	})
+	if auditErr != nil {
+		zap.S().Errorf("Failed to record audit event. Error: %v", auditErr)
+	}
+	return err
+}
+
+func deleteUser(ctx context.Context, clients *knative.Clients, store db.Store, user *objects.User) error {
+	managed, err := isManagedSpace(ctx, clients.Kube, user.Space)
+	if err != nil {
+		return err
+	}
+	if managed {
+		services, err := clients.ServingClient(user.Space).ListServices(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to list apps of space %v. Error: %v", user.Space, err)
+		}
+		for _, service := range services.Items {
+			if err = clients.DeleteApp(ctx, user.Space, service.Name); err != nil {
+				return fmt.Errorf("Failed to delete app %v of space %v. Error: %v", service.Name, user.Space, err)
+			}
+		}
+
+		if err = deleteNamespace(ctx, clients.Kube, user.Space); err != nil {
+			return err
+		}
+	}
+
+	if err = store.RemoveDomainsByUser(ctx, user); err != nil {
+		return fmt.Errorf("Failed to remove domains of user %v from DB. Error: %v", user.ID, err)
+	}
+	if err = store.RemoveDeploymentsByUser(ctx, user); err != nil {
+		return fmt.Errorf("Failed to remove deployments of user %v from DB. Error: %v", user.ID, err)
+	}
+	if err = store.RemoveUserByID(ctx, user); err != nil {
+		return fmt.Errorf("Failed to remove user %v from DB. Error: %v", user.ID, err)
+	}
+	zap.S().Infof("Deleted user %v and space %v", user.ID, user.Space)
+	return nil
+}
+
+// Check if the namespace of a user exists and is managed by app-controller.
+// A namespace without the managed-by label is never touched, the user can only
+// be deleted once the namespace is backfilled or removed.
+func isManagedSpace(ctx context.Context, clientset kubernetes.Interface, space string) (bool, error) {
+	if space == "" {
+		return false, nil
+	}
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, space, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Failed to get namespace %v. Error: %v", space, err)
+	}
+	if !knative.IsManagedNamespace(ns) {
+		return false, fmt.Errorf("Namespace %v is not managed by app-controller", space)
+	}
+	return true, nil
+}
+
+// Delete the secrets left in the namespace, and then the namespace.
+func deleteNamespace(ctx context.Context, clientset kubernetes.Interface, space string) error {
+	err := clientset.CoreV1().Secrets(space).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete secrets of space %v. Error: %v", space, err)
+	}
+
+	err = clientset.CoreV1().Namespaces().Delete(ctx, space, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete namespace %v. Error: %v", space, err)
+	}
+	return nil
+}
+
+// GarbageCollector deletes users inactive for longer than a period, and the
+// app-controller namespaces without a matching user.
+type GarbageCollector struct {
+	Clients  *knative.Clients
+	Store    db.Store
+	Interval time.Duration
+	// Inactivity after which users are deleted, 0 to keep them.
+	InactivePeriod time.Duration
+	// Age of an orphan namespace before it is deleted, to not race with login.
+	OrphanGrace time.Duration
+
+	now func() time.Time
+}
+
+// NewGarbageCollector returns a garbage collector configured through options.
+func NewGarbageCollector(clients *knative.Clients, store db.Store) *GarbageCollector {
+	return &GarbageCollector{
+		Clients:        clients,
+		Store:          store,
+		Interval:       options.GetGCInterval(),
+		InactivePeriod: options.GetGCInactivePeriod(),
+		OrphanGrace:    options.GetGCOrphanGrace(),
+		now:            time.Now,
+	}
+}
+
+// Run collects garbage once per interval until the context is cancelled.
+func (gc *GarbageCollector) Run(ctx context.Context) {
+	zap.S().Infof("Starting garbage collector, interval: %v, inactive period: %v", gc.Interval, gc.InactivePeriod)
+	Every(ctx, gc.Interval, gc.collect)
+}
+
+func (gc *GarbageCollector) collect(ctx context.Context) {
+	users := []objects.User{}
+	if err := gc.Store.GetUsers(ctx, &users); err != nil {
+		zap.S().Errorf("Garbage collector failed to get users from DB. Error: %v", err)
+		return
+	}
+
+	for _, user := range inactiveUsers(users, gc.now(), gc.InactivePeriod) {
+		if ctx.Err() != nil {
+			return
+		}
+		zap.S().Infof("Deleting user %v inactive since %v", user.ID, user.LastSeenAt)
+		if err := DeleteUser(ctx, gc.Clients, gc.Store, &user, gcActor); err != nil {
+			zap.S().Errorf("Garbage collector failed to delete user %v. Error: %v", user.ID, err)
+		}
+	}
+
+	namespaces, err := knative.ListManagedNamespaces(ctx, gc.Clients.Kube)
+	if err != nil {
+		zap.S().Errorf("Garbage collector failed to list namespaces. Error: %v", err)
+		return
+	}
+
+	// The namespaces quarantined by reconcile are kept for their owner to log in again.
+	for _, space := range orphanNamespaces(unquarantined(namespaces), users, gc.now(), gc.OrphanGrace) {
+		zap.S().Infof("Deleting namespace %v without a user", space)
+		err = deleteNamespace(ctx, gc.Clients.Kube, space)
+		if err != nil {
+			zap.S().Errorf("Garbage collector failed to delete namespace %v. Error: %v", space, err)
+		}
+		outcome := objects.AuditSuccess
+		if err != nil {
+			outcome = objects.AuditFailure
+		}
+		auditErr := gc.Store.AddAuditEvent(ctx, &objects.AuditEvent{
+			Actor:   gcActor,
+			Action:  "delete-namespace",
+			Space:   space,
+			Target:  space,
+			Outcome: outcome,
+			Detail:  "namespace without a user",
+		})
+		if auditErr != nil {
+			zap.S().Errorf("Failed to record audit event. Error: %v", auditErr)
+		}
+	}
+}
+
+// Users last seen longer than the period ago. Users never seen since
+// activity tracking was added are kept.
+func inactiveUsers(users []objects.User, now time.Time, period time.Duration) []objects.User {
+	inactive := []objects.User{}
+	if period <= 0 {
+		return inactive
+	}
+	for _, user := range users {
+		if !user.LastSeenAt.IsZero() && now.Sub(user.LastSeenAt) > period {
+			inactive = append(inactive, user)
+		}
+	}
+	return inactive
+}
+
+// The namespaces not quarantined by reconcile.
+func unquarantined(namespaces []corev1.Namespace) []corev1.Namespace {
+	kept := []corev1.Namespace{}
+	for _, ns := range namespaces {
+		if ns.Labels[util.QuarantinedLabel] != "true" {
+			kept = append(kept, ns)
+		}
+	}
+	return kept
+}
+
+// Names of the namespaces older than the grace period not owned by any user.
+func orphanNamespaces(namespaces []corev1.Namespace, users []objects.User, now time.Time, grace time.Duration) []string {
+	owned := map[string]bool{}
+	for _, user := range users {
+		owned[user.Space] = true
+	}
+
+	orphans := []string{}
+	for _, ns := range namespaces {
+		if owned[ns.Name] || ns.Status.Phase == corev1.NamespaceTerminating {
+			continue
+		}
+		if now.Sub(ns.CreationTimestamp.Time) < grace {
+			continue
+		}
+		orphans = append(orphans, ns.Name)
+	}
+	return orphans
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInactiveUsers(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	users := []objects.User{
+		{ID: 1, LastSeenAt: now.Add(-time.Hour)},
+		{ID: 2, LastSeenAt: now.Add(-100 * 24 * time.Hour)},
+		{ID: 3},
+	}
+
+	inactive := inactiveUsers(users, now, 90*24*time.Hour)
+	assert.Equal(t, len(inactive), 1)
+	assert.Equal(t, inactive[0].ID, 2)
+
+	assert.Equal(t, len(inactiveUsers(users, now, 0)), 0)
+}
+
+func TestOrphanNamespaces(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	namespace := func(name string, age time.Duration, phase corev1.NamespacePhase) corev1.Namespace {
+		return corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))},
+			Status:     corev1.NamespaceStatus{Phase: phase},
+		}
+	}
+	namespaces := []corev1.Namespace{
+		namespace("owned", 48*time.Hour, corev1.NamespaceActive),
+		namespace("orphan", 48*time.Hour, corev1.NamespaceActive),
+		namespace("new", time.Minute, corev1.NamespaceActive),
+		namespace("terminating", 48*time.Hour, corev1.NamespaceTerminating),
+	}
+	users := []objects.User{{ID: 1, Space: "owned"}}
+
+	assert.DeepEqual(t, orphanNamespaces(namespaces, users, now, time.Hour), []string{"orphan"})
+
+	// The quarantined namespaces are not collected.
+	quarantined := namespace("quarantined", 48*time.Hour, corev1.NamespaceActive)
+	quarantined.Labels = map[string]string{util.QuarantinedLabel: "true"}
+	namespaces = append(namespaces, quarantined)
+	assert.DeepEqual(t, orphanNamespaces(namespaces, users, now, time.Hour), []string{"orphan", "quarantined"})
+	assert.DeepEqual(t, orphanNamespaces(unquarantined(namespaces), users, now, time.Hour), []string{"orphan"})
+}
+
+func TestDeleteNamespace(t *testing.T) {
+	ctx := context.Background()
+	clientset := k8sfake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "space"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "space"}},
+	)
+
+	assert.NilError(t, deleteNamespace(ctx, clientset, "space"))
+	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(namespaces.Items), 0)
+
+	// Deleting a missing namespace is not an error, so deletion can be retried.
+	assert.NilError(t, deleteNamespace(ctx, clientset, "space"))
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/options"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NetworkIsolation configures the NetworkPolicy isolating the namespaces of
+// users from each other.
+type NetworkIsolation struct {
+	Enabled bool
+	// Namespaces allowed to reach the apps, such as the Knative activator and ingress.
+	AllowedNamespaces []string
+}
+
+// NetworkIsolationFromOptions returns the network isolation configured through options.
+func NetworkIsolationFromOptions() NetworkIsolation {
+	return NetworkIsolation{
+		Enabled:           options.GetNetworkPolicyEnabled(),
+		AllowedNamespaces: options.GetNetworkPolicyAllowedNamespaces(),
+	}
+}
+
+// Isolate applies the tenant NetworkPolicy to the namespace, if enabled.
+func (ni NetworkIsolation) Isolate(ctx context.Context, clientset kubernetes.Interface, space string) error {
+	if !ni.Enabled {
+		return nil
+	}
+	_, err := knative.EnsureTenantPolicy(ctx, clientset, space, ni.AllowedNamespaces)
+	return err
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/options"
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Loop is a background controller running until the context is cancelled.
+type Loop func(ctx context.Context)
+
+// RunLeaderElected runs the loops while this replica holds the leader
+// election lease, so that multiple replicas of app-controller don't act twice.
+// It returns when the context is cancelled.
+func RunLeaderElected(ctx context.Context, clientset kubernetes.Interface, loops ...Loop) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	identity := fmt.Sprintf("%s_%d", hostname, os.Getpid())
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      options.GetLeaseName(),
+			Namespace: options.GetLeaseNamespace(),
+		},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	// Campaign again if the lease is lost, until the context is cancelled.
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					zap.S().Infof("Started leading as %v, running background controllers.", identity)
+					runLoops(ctx, loops)
+				},
+				OnStoppedLeading: func() {
+					zap.S().Infof("Stopped leading as %v.", identity)
+				},
+				OnNewLeader: func(leader string) {
+					if leader != identity {
+						zap.S().Infof("Background controllers are run by leader %v.", leader)
+					}
+				},
+			},
+		})
+	}
+	return nil
+}
+
+// Run the loops and wait for all of them to return.
+func runLoops(ctx context.Context, loops []Loop) {
+	var wg sync.WaitGroup
+	for _, loop := range loops {
+		wg.Add(1)
+		go func(loop Loop) {
+			defer wg.Done()
+			loop(ctx)
+		}(loop)
+	}
+	wg.Wait()
+}
+
+// Every calls the function immediately and then once per interval, until the
+// context is cancelled.
+func Every(ctx context.Context, interval time.Duration, f func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		f(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Notification sent to the owner of an app before and after it is reaped.
+type Notification struct {
+	Space  string    `json:"space"`
+	App    string    `json:"app"`
+	Owner  string    `json:"owner"`
+	Action string    `json:"action"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// Notifier delivers notifications to app owners.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NewNotifier returns a webhook notifier when a URL is set, else a notifier
+// that only logs.
+func NewNotifier(url string) Notifier {
+	if url == "" {
+		return logNotifier{}
+	}
+	return &webhookNotifier{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(ctx context.Context, n Notification) error {
+	zap.S().Warnf("App %v in space %v of %v: %v at %v, %v", n.App, n.Space, n.Owner, n.Action, n.At, n.Reason)
+	return nil
+}
+
+// webhookNotifier posts the notification as JSON to a URL.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (wn *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wn.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Notification webhook returned status %v", resp.StatusCode)
+	}
+	return nil
+}
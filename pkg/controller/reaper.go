@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+	"github.com/platform9/app-controller/pkg/util"
+	"go.uber.org/zap"
+	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// Actions taken by the reaper on an app.
+const (
+	actionNone   = ""
+	actionWarn   = "warn"
+	actionDelete = "delete"
+	actionMark   = "mark"
+	actionReset  = "reset"
+
+	// Actor recorded in the audit events of the reaper.
+	reaperActor = "reaper"
+)
+
+// Reaper deletes, or marks, apps that are idle longer than the retention of
+// their owner's plan, and apps whose ttl has expired.
+type Reaper struct {
+	Clients  *knative.Clients
+	Store    db.Store
+	Interval time.Duration
+	// Idle period for plans without a retention, 0 to keep their apps.
+	IdlePeriod    time.Duration
+	WarningPeriod time.Duration
+	// Action on idle apps, "delete" or "mark".
+	Mode     string
+	Notifier Notifier
+
+	now func() time.Time
+}
+
+// NewReaper returns a reaper configured through options.
+func NewReaper(clients *knative.Clients, store db.Store) *Reaper {
+	return &Reaper{
+		Clients:       clients,
+		Store:         store,
+		Interval:      options.GetReaperInterval(),
+		IdlePeriod:    options.GetReaperIdlePeriod(),
+		WarningPeriod: options.GetReaperWarningPeriod(),
+		Mode:          options.GetReaperMode(),
+		Notifier:      NewNotifier(options.GetReaperNotifyURL()),
+		now:           time.Now,
+	}
+}
+
+// Run reaps apps once per interval until the context is cancelled.
+func (r *Reaper) Run(ctx context.Context) {
+	zap.S().Infof("Starting app reaper, interval: %v, mode: %v", r.Interval, r.Mode)
+	Every(ctx, r.Interval, r.reap)
+}
+
+func (r *Reaper) reap(ctx context.Context) {
+	users := []objects.User{}
+	if err := r.Store.GetUsers(ctx, &users); err != nil {
+		zap.S().Errorf("Reaper failed to get users from DB. Error: %v", err)
+		return
+	}
+
+	// Only act on the namespaces created by app-controller.
+	namespaces, err := knative.ListManagedNamespaces(ctx, r.Clients.Kube)
+	if err != nil {
+		zap.S().Errorf("Reaper failed to list namespaces. Error: %v", err)
+		return
+	}
+	managed := map[string]bool{}
+	for _, ns := range namespaces {
+		managed[ns.Name] = true
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		if !managed[user.Space] {
+			continue
+		}
+
+		if err := r.reapNamespace(ctx, r.Clients.ServingClient(user.Space), user); err != nil {
+			zap.S().Errorf("Reaper failed for space %v. Error: %v", user.Space, err)
+		}
+	}
+}
+
+// Reap the apps of a user namespace.
+func (r *Reaper) reapNamespace(ctx context.Context, client clientservingv1.KnServingClient, user objects.User) error {
+	retention := options.GetPlan(user.Plan).Retention
+	if retention <= 0 {
+		retention = r.IdlePeriod
+	}
+
+	services, err := client.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range services.Items {
+		service := &services.Items[i]
+		idleSince, idle, err := knative.IdleSince(ctx, client, service)
+		if err != nil {
+			zap.S().Errorf("Reaper failed to get the revision of app %v in space %v. Error: %v", service.Name, user.Space, err)
+			continue
+		}
+
+		action, at, reason := r.decide(service, idleSince, idle, retention)
+		if action == actionNone {
+			continue
+		}
+		if err = r.act(ctx, client, user, service, action, at, reason); err != nil {
+			zap.S().Errorf("Reaper failed to %v app %v in space %v. Error: %v", action, service.Name, user.Space, err)
+		}
+	}
+	return nil
+}
+
+// Decide the action on an app, the time it is due and why.
+func (r *Reaper) decide(service *servingv1.Service, idleSince time.Time, idle bool,
+	retention time.Duration) (string, time.Time, string) {
+	now := r.now()
+	warnedAt, warned := knative.WarnedAt(service)
+	// An app is deleted once due, and no sooner than the warning period after
+	// its owner was warned, even if it was already past due then.
+	deleteAt := func(dueAt time.Time) time.Time {
+		if warned {
+			return later(dueAt, warnedAt.Add(r.WarningPeriod))
+		}
+		return dueAt
+	}
+
+	// An expired ttl always deletes the app, idle or not.
+	if expiresAt, ok := knative.ExpiresAt(service); ok {
+		reason := fmt.Sprintf("ttl expires at %v", expiresAt.Format(time.RFC3339))
+		if at := deleteAt(expiresAt); !now.Before(at) && (warned || r.WarningPeriod <= 0) {
+			return actionDelete, at, reason
+		}
+		if !warned && !now.Before(expiresAt.Add(-r.WarningPeriod)) {
+			return actionWarn, later(expiresAt, now.Add(r.WarningPeriod)), reason
+		}
+	}
+
+	if !idle {
+		// The app received traffic again after being warned or marked.
+		_, marked := service.Labels[util.IdleLabel]
+		if _, expires := knative.ExpiresAt(service); (warned && !expires) || marked {
+			return actionReset, now, "app is active"
+		}
+		return actionNone, time.Time{}, ""
+	}
+	if retention <= 0 || service.Labels[util.IdleLabel] == "true" {
+		return actionNone, time.Time{}, ""
+	}
+
+	dueAt := idleSince.Add(retention)
+	reason := fmt.Sprintf("idle since %v", idleSince.Format(time.RFC3339))
+	if at := deleteAt(dueAt); !now.Before(at) && (warned || r.WarningPeriod <= 0) {
+		if r.Mode == actionMark {
+			return actionMark, at, reason
+		}
+		return actionDelete, at, reason
+	}
+	if !warned && !now.Before(dueAt.Add(-r.WarningPeriod)) {
+		return actionWarn, later(dueAt, now.Add(r.WarningPeriod)), reason
+	}
+	return actionNone, time.Time{}, ""
+}
+
+// The later of two times.
+func later(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Take the action on an app, notifying its owner and recording an audit event.
+func (r *Reaper) act(ctx context.Context, client clientservingv1.KnServingClient, user objects.User,
+	service *servingv1.Service, action string, at time.Time, reason string) error {
+	var err error
+	switch action {
+	case actionWarn:
+		err = knative.UpdateAppMeta(ctx, client, service.Name,
+			map[string]string{util.WarnedAtAnnotation: r.now().UTC().Format(time.RFC3339)}, nil)
+	case actionMark:
+		err = knative.UpdateAppMeta(ctx, client, service.Name, nil, map[string]string{util.IdleLabel: "true"})
+	case actionReset:
+		// The warning of a ttl stays valid while the app is active.
+		annotations := map[string]string{}
+		if _, expires := knative.ExpiresAt(service); !expires {
+			annotations[util.WarnedAtAnnotation] = ""
+		}
+		return knative.UpdateAppMeta(ctx, client, service.Name, annotations, map[string]string{util.IdleLabel: ""})
+	case actionDelete:
+		err = DeleteApp(ctx, r.Clients, r.Store, &user, service.Name)
+	}
+
+	if action != actionWarn {
+		outcome := objects.AuditSuccess
+		if err != nil {
+			outcome = objects.AuditFailure
+		}
+		auditErr := r.Store.AddAuditEvent(ctx, &objects.AuditEvent{
+			Actor:   reaperActor,
+			Action:  action + "-app",
+			Space:   user.Space,
+			Target:  service.Name,
+			Outcome: outcome,
+			Detail:  reason,
+		})
+		if auditErr != nil {
+			zap.S().Errorf("Reaper failed to record audit event. Error: %v", auditErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	owner := user.Email
+	if owner == "" {
+		owner = user.Name
+	}
+	return r.Notifier.Notify(ctx, Notification{
+		Space:  user.Space,
+		App:    service.Name,
+		Owner:  owner,
+		Action: action,
+		Reason: reason,
+		At:     at,
+	})
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/util"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func newTestReaper(now time.Time) *Reaper {
+	return &Reaper{
+		WarningPeriod: 24 * time.Hour,
+		Mode:          actionDelete,
+		now:           func() time.Time { return now },
+	}
+}
+
+func newTestService(annotations map[string]string, labels map[string]string) *servingv1.Service {
+	return &servingv1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name:        "app",
+		Annotations: annotations,
+		Labels:      labels,
+	}}
+}
+
+func TestReaperDecide(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	// Warned longer ago than the warning period, and just now.
+	warned := map[string]string{util.WarnedAtAnnotation: now.Add(-2 * 24 * time.Hour).Format(time.RFC3339)}
+	warnedNow := map[string]string{util.WarnedAtAnnotation: now.Format(time.RFC3339)}
+	expiresIn := func(d time.Duration, extra map[string]string) map[string]string {
+		annotations := map[string]string{util.ExpiresAtAnnotation: now.Add(d).Format(time.RFC3339)}
+		for key, value := range extra {
+			annotations[key] = value
+		}
+		return annotations
+	}
+
+	tests := []struct {
+		name      string
+		service   *servingv1.Service
+		idleFor   time.Duration
+		idle      bool
+		retention time.Duration
+		mode      string
+		action    string
+	}{
+		{"active app", newTestService(nil, nil), 0, false, week, actionDelete, actionNone},
+		{"idle below warning period", newTestService(nil, nil), 2 * 24 * time.Hour, true, week, actionDelete, actionNone},
+		{"idle within warning period", newTestService(nil, nil), week - time.Hour, true, week, actionDelete, actionWarn},
+		{"idle past retention without warning", newTestService(nil, nil), 2 * week, true, week, actionDelete, actionWarn},
+		{"idle past retention after warning", newTestService(warned, nil), 2 * week, true, week, actionDelete, actionDelete},
+		{"idle past retention in mark mode", newTestService(warned, nil), 2 * week, true, week, actionMark, actionMark},
+		{"already marked", newTestService(warned, map[string]string{util.IdleLabel: "true"}), 2 * week, true, week, actionMark, actionNone},
+		{"no retention", newTestService(nil, nil), 2 * week, true, 0, actionDelete, actionNone},
+		{"active after warning", newTestService(warned, nil), 0, false, week, actionDelete, actionReset},
+		{"ttl far away", newTestService(expiresIn(week, nil), nil), 0, false, 0, actionDelete, actionNone},
+		{"ttl within warning period", newTestService(expiresIn(time.Hour, nil), nil), 0, false, 0, actionDelete, actionWarn},
+		{"ttl expired after warning", newTestService(expiresIn(-time.Hour, warned), nil), 0, false, 0, actionMark, actionDelete},
+		{"ttl warning kept while active", newTestService(expiresIn(time.Hour, warned), nil), 0, false, 0, actionDelete, actionNone},
+		// Apps already past due when warned get the whole warning period.
+		{"idle past retention warned just now", newTestService(warnedNow, nil), 2 * week, true, week, actionDelete, actionNone},
+		{"idle past retention in mark mode warned just now", newTestService(warnedNow, nil), 2 * week, true, week, actionMark, actionNone},
+		{"ttl expired warned just now", newTestService(expiresIn(-week, warnedNow), nil), 0, false, 0, actionDelete, actionNone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reaper := newTestReaper(now)
+			reaper.Mode = test.mode
+			action, _, _ := reaper.decide(test.service, now.Add(-test.idleFor), test.idle, test.retention)
+			assert.Equal(t, action, test.action)
+		})
+	}
+}
+
+func TestReaperDecidePastDue(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	expired := func() map[string]string {
+		return map[string]string{util.ExpiresAtAnnotation: now.Add(-week).Format(time.RFC3339)}
+	}
+
+	tests := []struct {
+		name    string
+		service *servingv1.Service
+		idle    bool
+	}{
+		{"ttl expired", newTestService(expired(), nil), false},
+		{"idle past retention", newTestService(nil, nil), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reaper := newTestReaper(now)
+			idleSince := now.Add(-2 * week)
+
+			// The warning announces the deletion after the warning period.
+			action, at, _ := reaper.decide(test.service, idleSince, test.idle, week)
+			assert.Equal(t, action, actionWarn)
+			assert.Equal(t, at, now.Add(reaper.WarningPeriod))
+
+			// The next runs within the warning period leave the app, then it is deleted.
+			if test.service.Annotations == nil {
+				test.service.Annotations = map[string]string{}
+			}
+			test.service.Annotations[util.WarnedAtAnnotation] = now.Format(time.RFC3339)
+			for _, elapsed := range []time.Duration{time.Minute, time.Hour, reaper.WarningPeriod - time.Second} {
+				reaper.now = func() time.Time { return now.Add(elapsed) }
+				action, _, _ = reaper.decide(test.service, idleSince, test.idle, week)
+				assert.Equal(t, action, actionNone, "after %v", elapsed)
+			}
+			reaper.now = func() time.Time { return now.Add(reaper.WarningPeriod) }
+			action, at, _ = reaper.decide(test.service, idleSince, test.idle, week)
+			assert.Equal(t, action, actionDelete)
+			assert.Equal(t, at, now.Add(reaper.WarningPeriod))
+		})
+	}
+}
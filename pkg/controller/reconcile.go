@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+	"github.com/platform9/app-controller/pkg/util"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kinds of drift between DB and cluster.
+const (
+	// A user in DB whose namespace is missing from the cluster.
+	DriftMissingNamespace = "missing-namespace"
+	// A namespace created by app-controller without a user in DB.
+	DriftOrphanNamespace = "orphan-namespace"
+	// A namespace of a user without the tenant NetworkPolicy, or with an outdated one.
+	DriftNetworkPolicy = "network-policy"
+	// A namespace of a user without the managed-by label, left alone until backfilled.
+	DriftUnmanagedNamespace = "unmanaged-namespace"
+)
+
+// Drift between a user in DB and the cluster.
+type Drift struct {
+	Type   string `json:"type"`
+	Space  string `json:"space"`
+	UserID int    `json:"userId,omitempty"`
+	// Fix applied, empty if none.
+	Fix   string `json:"fix,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// DriftReport lists the drift found by a reconciliation.
+type DriftReport struct {
+	CheckedAt  time.Time `json:"checkedAt"`
+	Users      int       `json:"users"`
+	Namespaces int       `json:"namespaces"`
+	Drift      []Drift   `json:"drift"`
+}
+
+// ReconcileOptions configures the drift checked and fixed by Reconcile.
+type ReconcileOptions struct {
+	Fix bool
+	// Age of an orphan namespace before it is quarantined, to not race with login.
+	OrphanGrace time.Duration
+	Isolation   NetworkIsolation
+	// Extra labels of recreated namespaces, such as the Pod Security ones.
+	NamespaceLabels map[string]string
+}
+
+// Reconcile compares the users in DB with the namespaces created by
+// app-controller. With fix, missing namespaces are recreated, orphan
+// namespaces older than the grace period are quarantined and the tenant
+// NetworkPolicy is applied to the namespaces of users. Namespaces without
+// the managed-by label are only reported.
+func Reconcile(ctx context.Context, clientset kubernetes.Interface, users []objects.User,
+	opts ReconcileOptions, now time.Time) (*DriftReport, error) {
+	fix, isolation := opts.Fix, opts.Isolation
+	namespaces, err := knative.ListManagedNamespaces(ctx, clientset)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list namespaces. Error: %v", err)
+	}
+
+	report := &DriftReport{
+		CheckedAt:  now.UTC(),
+		Users:      len(users),
+		Namespaces: len(namespaces),
+		Drift:      []Drift{},
+	}
+
+	existing := map[string]bool{}
+	quarantined := map[string]bool{}
+	for _, ns := range namespaces {
+		existing[ns.Name] = true
+		if ns.Labels[util.QuarantinedLabel] == "true" {
+			quarantined[ns.Name] = true
+		}
+	}
+
+	for _, user := range users {
+		if user.Space != "" && existing[user.Space] {
+			continue
+		}
+		// The namespace may exist without the app-controller label.
+		if user.Space != "" {
+			_, err := clientset.CoreV1().Namespaces().Get(ctx, user.Space, metav1.GetOptions{})
+			if err == nil {
+				report.Drift = append(report.Drift, Drift{Type: DriftUnmanagedNamespace, Space: user.Space, UserID: user.ID})
+				continue
+			}
+		}
+
+		drift := Drift{Type: DriftMissingNamespace, Space: user.Space, UserID: user.ID}
+		if fix && user.Space != "" {
+			if _, err := knative.EnsureNamespace(ctx, clientset, user.Space, user.Owner(),
+				knative.NamespaceLabels(&user, opts.NamespaceLabels)); err != nil {
+				drift.Error = err.Error()
+			} else if err = isolation.Isolate(ctx, clientset, user.Space); err != nil {
+				drift.Error = err.Error()
+			} else {
+				drift.Fix = "recreated"
+			}
+		}
+		report.Drift = append(report.Drift, drift)
+	}
+
+	for _, space := range orphanNamespaces(namespaces, users, now, 0) {
+		drift := Drift{Type: DriftOrphanNamespace, Space: space}
+		if fix && !quarantined[space] && isOlder(namespaces, space, now, opts.OrphanGrace) {
+			if err := knative.QuarantineNamespace(ctx, clientset, space); err != nil {
+				drift.Error = err.Error()
+			} else {
+				drift.Fix = "quarantined"
+			}
+		}
+		report.Drift = append(report.Drift, drift)
+	}
+
+	if isolation.Enabled {
+		for _, user := range users {
+			if !existing[user.Space] || quarantined[user.Space] {
+				continue
+			}
+			// A namespace failing doesn't stop the others from being checked.
+			if drift := reconcileNetworkPolicy(ctx, clientset, user, fix, isolation); drift != nil {
+				report.Drift = append(report.Drift, *drift)
+			}
+		}
+	}
+	return report, nil
+}
+
+// Check the tenant NetworkPolicy of the namespace of a user, and apply it
+// with fix. Returns nil without drift, or the drift with the error of the
+// namespace.
+func reconcileNetworkPolicy(ctx context.Context, clientset kubernetes.Interface, user objects.User,
+	fix bool, isolation NetworkIsolation) *Drift {
+	drift := &Drift{Type: DriftNetworkPolicy, Space: user.Space, UserID: user.ID}
+	ok, err := knative.CheckTenantPolicy(ctx, clientset, user.Space, isolation.AllowedNamespaces)
+	if err != nil {
+		drift.Error = fmt.Sprintf("Failed to get network policy. Error: %v", err)
+		return drift
+	}
+	if ok {
+		return nil
+	}
+
+	if fix {
+		if err = isolation.Isolate(ctx, clientset, user.Space); err != nil {
+			drift.Error = err.Error()
+		} else {
+			drift.Fix = "applied"
+		}
+	}
+	return drift
+}
+
+// Check if the namespace was created longer than the grace period ago.
+func isOlder(namespaces []corev1.Namespace, name string, now time.Time, grace time.Duration) bool {
+	for _, ns := range namespaces {
+		if ns.Name == name {
+			return now.Sub(ns.CreationTimestamp.Time) >= grace
+		}
+	}
+	return false
+}
+
+// Reconciler periodically reconciles DB with the cluster and logs the drift.
+type Reconciler struct {
+	ReconcileOptions
+	Clients  *knative.Clients
+	Store    db.Store
+	Interval time.Duration
+}
+
+// NewReconciler returns a reconciler configured through options.
+func NewReconciler(clients *knative.Clients, store db.Store) *Reconciler {
+	return &Reconciler{
+		ReconcileOptions: ReconcileOptions{
+			Fix:             options.GetReconcileFix(),
+			OrphanGrace:     options.GetGCOrphanGrace(),
+			Isolation:       NetworkIsolationFromOptions(),
+			NamespaceLabels: options.GetPodSecurityLabels(),
+		},
+		Clients:  clients,
+		Store:    store,
+		Interval: options.GetReconcileInterval(),
+	}
+}
+
+// Run reconciles once per interval until the context is cancelled.
+func (rc *Reconciler) Run(ctx context.Context) {
+	zap.S().Infof("Starting reconciler, interval: %v, fix: %v", rc.Interval, rc.Fix)
+	Every(ctx, rc.Interval, func(ctx context.Context) {
+		report, err := rc.Reconcile(ctx)
+		if err != nil {
+			zap.S().Errorf("Reconcile failed. Error: %v", err)
+			return
+		}
+		if len(report.Drift) > 0 {
+			data, _ := json.Marshal(report)
+			zap.S().Warnf("Drift between DB and cluster: %s", data)
+		}
+	})
+}
+
+// Reconcile runs a single reconciliation of the users in DB.
+func (rc *Reconciler) Reconcile(ctx context.Context) (*DriftReport, error) {
+	users := []objects.User{}
+	if err := rc.Store.GetUsers(ctx, &users); err != nil {
+		return nil, fmt.Errorf("Failed to get users from DB. Error: %v", err)
+	}
+	return Reconcile(ctx, rc.Clients.Kube, users, rc.ReconcileOptions, time.Now())
+}
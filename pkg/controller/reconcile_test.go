@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	orphan := knative.NewNamespaceObj("orphan", "gone@example.com", nil)
+	orphan.CreationTimestamp = metav1.NewTime(now.Add(-48 * time.Hour))
+	owned := knative.NewNamespaceObj("owned", "owner@example.com", nil)
+	users := []objects.User{
+		{ID: 1, Email: "owner@example.com", Space: "owned"},
+		{ID: 2, Email: "missing@example.com", Space: "missing"},
+	}
+
+	t.Run("report drift without fixing it", func(t *testing.T) {
+		clientset := k8sfake.NewSimpleClientset(orphan.DeepCopy(), owned.DeepCopy())
+		report, err := Reconcile(ctx, clientset, users, ReconcileOptions{OrphanGrace: time.Hour}, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, report.Drift, []Drift{
+			{Type: DriftMissingNamespace, Space: "missing", UserID: 2},
+			{Type: DriftOrphanNamespace, Space: "orphan"},
+		})
+
+		_, err = clientset.CoreV1().Namespaces().Get(ctx, "missing", metav1.GetOptions{})
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("fix recreates and quarantines namespaces", func(t *testing.T) {
+		clientset := k8sfake.NewSimpleClientset(orphan.DeepCopy(), owned.DeepCopy())
+		report, err := Reconcile(ctx, clientset, users, ReconcileOptions{Fix: true, OrphanGrace: time.Hour}, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, report.Drift, []Drift{
+			{Type: DriftMissingNamespace, Space: "missing", UserID: 2, Fix: "recreated"},
+			{Type: DriftOrphanNamespace, Space: "orphan", Fix: "quarantined"},
+		})
+
+		missing, err := clientset.CoreV1().Namespaces().Get(ctx, "missing", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, missing.Annotations[util.OwnerAnnotation], "missing@example.com")
+
+		quarantined, err := clientset.CoreV1().Namespaces().Get(ctx, "orphan", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, quarantined.Labels[util.QuarantinedLabel], "true")
+
+		// The owner logging in again resumes with the quarantined namespace.
+		space, err := knative.FindOwnedNamespace(ctx, clientset, "gone@example.com")
+		assert.NilError(t, err)
+		assert.Equal(t, space, "orphan")
+		assert.NilError(t, knative.ReleaseNamespace(ctx, clientset, space))
+	})
+
+	t.Run("fix applies network policies to namespaces of users", func(t *testing.T) {
+		isolation := NetworkIsolation{Enabled: true, AllowedNamespaces: []string{"knative-serving"}}
+		opts := ReconcileOptions{OrphanGrace: time.Hour, Isolation: isolation}
+		clientset := k8sfake.NewSimpleClientset(orphan.DeepCopy(), owned.DeepCopy())
+		report, err := Reconcile(ctx, clientset, users, opts, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, report.Drift[2:], []Drift{
+			{Type: DriftNetworkPolicy, Space: "owned", UserID: 1},
+		})
+
+		opts.Fix = true
+		report, err = Reconcile(ctx, clientset, users, opts, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, report.Drift[2:], []Drift{
+			{Type: DriftNetworkPolicy, Space: "owned", UserID: 1, Fix: "applied"},
+		})
+
+		// The recreated namespace is isolated too, and the orphan is not.
+		for space, isolated := range map[string]bool{"owned": true, "missing": true, "orphan": false} {
+			ok, err := knative.CheckTenantPolicy(ctx, clientset, space, isolation.AllowedNamespaces)
+			assert.NilError(t, err)
+			assert.Equal(t, ok, isolated, space)
+		}
+
+		report, err = Reconcile(ctx, clientset, users, opts, now)
+		assert.NilError(t, err)
+		assert.Equal(t, len(report.Drift), 1)
+	})
+
+	t.Run("network policy errors are reported per namespace", func(t *testing.T) {
+		isolation := NetworkIsolation{Enabled: true, AllowedNamespaces: []string{"knative-serving"}}
+		opts := ReconcileOptions{Fix: true, OrphanGrace: time.Hour, Isolation: isolation}
+		other := knative.NewNamespaceObj("other", "other@example.com", nil)
+		clientset := k8sfake.NewSimpleClientset(owned.DeepCopy(), other)
+		clientset.PrependReactor("get", "networkpolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetNamespace() != "owned" {
+				return false, nil, nil
+			}
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "networkpolicies"}, "", nil)
+		})
+		report, err := Reconcile(ctx, clientset, []objects.User{users[0], {ID: 4, Space: "other"}}, opts, now)
+		assert.NilError(t, err)
+		assert.Equal(t, len(report.Drift), 2)
+		assert.Equal(t, report.Drift[0].Space, "owned")
+		assert.Assert(t, strings.Contains(report.Drift[0].Error, "forbidden"), report.Drift[0].Error)
+		assert.DeepEqual(t, report.Drift[1], Drift{Type: DriftNetworkPolicy, Space: "other", UserID: 4, Fix: "applied"})
+	})
+
+	t.Run("report namespaces without the managed-by label", func(t *testing.T) {
+		bare := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "bare"}}
+		clientset := k8sfake.NewSimpleClientset(bare)
+		report, err := Reconcile(ctx, clientset, []objects.User{{ID: 3, Space: "bare"}},
+			ReconcileOptions{Fix: true, OrphanGrace: time.Hour}, now)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, report.Drift, []Drift{
+			{Type: DriftUnmanagedNamespace, Space: "bare", UserID: 3},
+		})
+
+		// Left untouched, even with fix.
+		ns, err := clientset.CoreV1().Namespaces().Get(ctx, "bare", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(ns.Labels), 0)
+	})
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/db"
+	"github.com/platform9/app-controller/pkg/knative"
+	"github.com/platform9/app-controller/pkg/metrics"
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/options"
+	"go.uber.org/zap"
+)
+
+// UsageCollector periodically sets the gauges of users and apps per namespace.
+type UsageCollector struct {
+	Clients  *knative.Clients
+	Store    db.Store
+	Interval time.Duration
+}
+
+// NewUsageCollector returns a usage collector configured through options.
+func NewUsageCollector(clients *knative.Clients, store db.Store) *UsageCollector {
+	return &UsageCollector{
+		Clients:  clients,
+		Store:    store,
+		Interval: options.GetMetricsInterval(),
+	}
+}
+
+// Run collects the usage once per interval until the context is cancelled.
+func (uc *UsageCollector) Run(ctx context.Context) {
+	zap.S().Infof("Starting usage collector, interval: %v", uc.Interval)
+	Every(ctx, uc.Interval, uc.collect)
+}
+
+func (uc *UsageCollector) collect(ctx context.Context) {
+	users := []objects.User{}
+	if err := uc.Store.GetUsers(ctx, &users); err != nil {
+		zap.S().Errorf("Usage collector failed to get users from DB. Error: %v", err)
+		return
+	}
+	metrics.Users.Set(float64(len(users)))
+
+	counts, err := uc.Clients.CountApps(ctx)
+	if err != nil {
+		zap.S().Errorf("Usage collector failed to count apps. Error: %v", err)
+		return
+	}
+	setAppGauges(users, counts)
+}
+
+// Set the apps gauge of the namespaces of users, dropping deleted ones.
+func setAppGauges(users []objects.User, counts map[string]int) {
+	metrics.Apps.Reset()
+	for _, user := range users {
+		if user.Space != "" {
+			metrics.Apps.WithLabelValues(user.Space).Set(float64(counts[user.Space]))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/platform9/app-controller/pkg/metrics"
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// Columns of the audit_events table read into objects.AuditEvent by scanAuditEvent.
+const auditColumns = "id, created_at, actor, action, space, target, outcome, detail, request_id"
+
+// Scan a row of auditColumns into event.
+func scanAuditEvent(rows *sql.Rows, event *objects.AuditEvent) error {
+	var actor, action, space, target, outcome, detail, requestID sql.NullString
+	var createdAt time.Time
+	var id int
+	if err := rows.Scan(&id, &createdAt, &actor, &action, &space, &target, &outcome, &detail, &requestID); err != nil {
+		return err
+	}
+
+	*event = objects.AuditEvent{
+		ID:        id,
+		CreatedAt: createdAt,
+		Actor:     NullStrToStr(actor),
+		Action:    NullStrToStr(action),
+		Space:     NullStrToStr(space),
+		Target:    NullStrToStr(target),
+		Outcome:   NullStrToStr(outcome),
+		Detail:    NullStrToStr(detail),
+		RequestID: NullStrToStr(requestID),
+	}
+	return nil
+}
+
+// AddAuditEvent adds an audit event to database
+func (q *Querier) AddAuditEvent(ctx context.Context, event *objects.AuditEvent) error {
+	defer metrics.ObserveQuery("AddAuditEvent")()
+	tx, err := q.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmtIns, err := tx.PrepareContext(ctx, q.dialect.Rebind("INSERT INTO audit_events(actor, action, space, target, outcome, detail, request_id) values(?, ?, ?, ?, ?, ?, ?)"))
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	defer stmtIns.Close()
+
+	if _, err = stmtIns.ExecContext(ctx, event.Actor, event.Action, event.Space, event.Target, event.Outcome, event.Detail, event.RequestID); err != nil {
+		log.Error(err, ": Error inserting audit event ", event.Action)
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAuditEvents returns the audit events matching the filter, the latest first.
+func (q *Querier) GetAuditEvents(ctx context.Context, filter objects.AuditFilter, events *[]objects.AuditEvent) error {
+	defer metrics.ObserveQuery("GetAuditEvents")()
+	where := []string{"1=1"}
+	args := []interface{}{}
+	for _, match := range []struct{ column, value string }{
+		{"actor", filter.Actor}, {"action", filter.Action}, {"space", filter.Space},
+	} {
+		if match.value != "" {
+			where = append(where, match.column+"=?")
+			args = append(args, match.value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at>=?")
+		args = append(args, filter.Since.UTC())
+	}
+	query := "SELECT " + auditColumns + " FROM audit_events WHERE " + strings.Join(where, " AND ") + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := q.handle.QueryContext(ctx, q.dialect.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event objects.AuditEvent
+		if err = scanAuditEvent(rows, &event); err != nil {
+			return err
+		}
+		*events = append(*events, event)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/platform9/app-controller/pkg/metrics"
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// Columns of the deployments table read into objects.Deployment by scanDeployment.
+const deploymentColumns = "id, created_at, finished_at, user_id, space, app, actor, spec, revision, outcome, error"
+
+// Scan a row of deploymentColumns into deployment.
+func scanDeployment(rows *sql.Rows, deployment *objects.Deployment) error {
+	var space, app, actor, spec, revision, outcome, errMsg sql.NullString
+	var finishedAt sql.NullTime
+	var createdAt time.Time
+	var id, userID int
+	if err := rows.Scan(&id, &createdAt, &finishedAt, &userID, &space, &app, &actor, &spec, &revision, &outcome, &errMsg); err != nil {
+		return err
+	}
+
+	*deployment = objects.Deployment{
+		ID:         id,
+		CreatedAt:  createdAt,
+		FinishedAt: finishedAt.Time,
+		UserID:     userID,
+		Space:      NullStrToStr(space),
+		App:        NullStrToStr(app),
+		Actor:      NullStrToStr(actor),
+		Spec:       NullStrToStr(spec),
+		Revision:   NullStrToStr(revision),
+		Outcome:    NullStrToStr(outcome),
+		Error:      NullStrToStr(errMsg),
+	}
+	return nil
+}
+
+// AddDeployment adds a pending deployment to database, and sets its ID.
+func (q *Querier) AddDeployment(ctx context.Context, deployment *objects.Deployment) error {
+	defer metrics.ObserveQuery("AddDeployment")()
+	tx, err := q.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	deployment.CreatedAt = time.Now().UTC()
+	deployment.Outcome = objects.DeploymentPending
+	id, err := q.insertID(ctx, tx, "INSERT INTO deployments(created_at, user_id, space, app, actor, spec, outcome) values(?, ?, ?, ?, ?, ?, ?)",
+		deployment.CreatedAt, deployment.UserID, deployment.Space, deployment.App, deployment.Actor, deployment.Spec, deployment.Outcome)
+	if err != nil {
+		log.Error(err, ": Error inserting deployment of ", deployment.App)
+		tx.Rollback()
+		return err
+	}
+	deployment.ID = id
+
+	return tx.Commit()
+}
+
+// FinishDeployment records the outcome and revision of a deployment.
+func (q *Querier) FinishDeployment(ctx context.Context, deployment *objects.Deployment) error {
+	defer metrics.ObserveQuery("FinishDeployment")()
+	deployment.FinishedAt = time.Now().UTC()
+	_, err := q.handle.ExecContext(ctx, q.dialect.Rebind("UPDATE deployments SET finished_at=?, revision=?, outcome=?, error=? WHERE id=?"),
+		deployment.FinishedAt, deployment.Revision, deployment.Outcome, deployment.Error, deployment.ID)
+	return err
+}
+
+// RemoveDeploymentsByUser removes the deployments of a user from database
+func (q *Querier) RemoveDeploymentsByUser(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("RemoveDeploymentsByUser")()
+	_, err := q.handle.ExecContext(ctx, q.dialect.Rebind("DELETE FROM deployments WHERE user_id=?"), user.ID)
+	return err
+}
+
+// Get the deployments matching the where clause, the latest first.
+func (q *Querier) getDeploymentsWhere(ctx context.Context, deployments *[]objects.Deployment, where string, args ...interface{}) error {
+	rows, err := q.handle.QueryContext(ctx, q.dialect.Rebind("SELECT "+deploymentColumns+" FROM deployments WHERE "+where+" ORDER BY id DESC"), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var deployment objects.Deployment
+		if err = scanDeployment(rows, &deployment); err != nil {
+			return err
+		}
+		*deployments = append(*deployments, deployment)
+	}
+	return rows.Err()
+}
+
+// GetDeploymentsByApp returns the deployments of an app, the latest first
+func (q *Querier) GetDeploymentsByApp(ctx context.Context, userID int, app string, deployments *[]objects.Deployment) error {
+	defer metrics.ObserveQuery("GetDeploymentsByApp")()
+	return q.getDeploymentsWhere(ctx, deployments, "user_id=? AND app=?", userID, app)
+}
+
+// GetDeployment returns a deployment given its ID, deployment is left
+// unchanged if it doesn't exist.
+func (q *Querier) GetDeployment(ctx context.Context, id int, deployment *objects.Deployment) error {
+	defer metrics.ObserveQuery("GetDeployment")()
+	deployments := []objects.Deployment{}
+	if err := q.getDeploymentsWhere(ctx, &deployments, "id=?", id); err != nil {
+		return err
+	}
+	if len(deployments) > 0 {
+		*deployment = deployments[0]
+	}
+	return nil
+}
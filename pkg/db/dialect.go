@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect of the SQL understood by a database.
+type Dialect string
+
+// Supported dialects, named after their database/sql driver.
+const (
+	SQLite   Dialect = "sqlite3"
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+)
+
+// Rebind rewrites the ? placeholders of a query into the ones of the
+// dialect, $1, $2... for Postgres. Placeholders in quoted literals are kept.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote rune
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// SchemaDir returns the directory of the migrations of the dialect. SQLite
+// and MySQL share the schema files, Postgres has its own.
+func (d Dialect) SchemaDir() string {
+	if d == Postgres {
+		return "schema/postgres"
+	}
+	return "schema"
+}
+
+// Statement creating the table recording the migrations ran.
+func (d Dialect) migrationsTable() string {
+	id := "id INTEGER PRIMARY KEY /*!40101 AUTO_INCREMENT*/"
+	if d == Postgres {
+		id = "id SERIAL PRIMARY KEY"
+	}
+	return `CREATE TABLE IF NOT EXISTS migrations (
+		` + id + `,
+		created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+		name VARCHAR(128) NOT NULL UNIQUE,
+		checksum VARCHAR(64)
+	  );`
+}
+
+// Whether schema changes can be rolled back with a transaction, MySQL
+// commits them implicitly.
+func (d Dialect) transactionalDDL() bool {
+	return d != MySQL
+}
+
+// Name of the MySQL lock and key of the Postgres advisory lock of the
+// migrations.
+const (
+	migrationsLockName = "app-controller-migrations"
+	migrationsLockKey  = 6112043
+)
+
+// Take the migrations lock on the connection, until the returned function
+// is called. SQLite databases are local to a replica and are not locked.
+func (d Dialect) lockMigrations(ctx context.Context, conn *sql.Conn) (func() error, error) {
+	switch d {
+	case MySQL:
+		var locked sql.NullInt64
+		timeout := int(migrationsLockTimeout.Seconds())
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationsLockName, timeout).Scan(&locked); err != nil {
+			return nil, err
+		}
+		if locked.Int64 != 1 {
+			return nil, fmt.Errorf("timed out after %v", migrationsLockTimeout)
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationsLockName)
+			return err
+		}, nil
+	case Postgres:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+			return nil, err
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+			return err
+		}, nil
+	}
+	return func() error { return nil }, nil
+}
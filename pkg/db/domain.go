@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/platform9/app-controller/pkg/metrics"
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// Columns of the domains table read into objects.Domain by scanDomain.
+const domainColumns = "id, created_at, user_id, space, app, domain, token, verified_at"
+
+// Scan a row of domainColumns into domain.
+func scanDomain(rows *sql.Rows, domain *objects.Domain) error {
+	var space, app, name, token sql.NullString
+	var verifiedAt sql.NullTime
+	var createdAt time.Time
+	var id, userID int
+	if err := rows.Scan(&id, &createdAt, &userID, &space, &app, &name, &token, &verifiedAt); err != nil {
+		return err
+	}
+
+	*domain = objects.Domain{
+		ID:         id,
+		CreatedAt:  createdAt,
+		UserID:     userID,
+		Space:      NullStrToStr(space),
+		App:        NullStrToStr(app),
+		Domain:     NullStrToStr(name),
+		Token:      NullStrToStr(token),
+		VerifiedAt: verifiedAt.Time,
+	}
+	return nil
+}
+
+// AddDomain adds a claim of a custom domain pending verification to
+// database, and sets its ID. Several users can claim the same domain until
+// one of them verifies it.
+func (q *Querier) AddDomain(ctx context.Context, domain *objects.Domain) error {
+	defer metrics.ObserveQuery("AddDomain")()
+	tx, err := q.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	domain.CreatedAt = time.Now().UTC()
+	id, err := q.insertID(ctx, tx, "INSERT INTO domains(created_at, user_id, space, app, domain, token) values(?, ?, ?, ?, ?, ?)",
+		domain.CreatedAt, domain.UserID, domain.Space, domain.App, domain.Domain, domain.Token)
+	if err != nil {
+		log.Error(err, ": Error inserting ", domain.Domain)
+		tx.Rollback()
+		return err
+	}
+	domain.ID = id
+
+	return tx.Commit()
+}
+
+// SetDomainVerified records the ownership of the domain as verified now.
+func (q *Querier) SetDomainVerified(ctx context.Context, domain *objects.Domain) error {
+	defer metrics.ObserveQuery("SetDomainVerified")()
+	domain.VerifiedAt = time.Now().UTC()
+	_, err := q.handle.ExecContext(ctx, q.dialect.Rebind("UPDATE domains SET verified_at=? WHERE id=?"), domain.VerifiedAt, domain.ID)
+	return err
+}
+
+// RemoveDomain removes a custom domain from database
+func (q *Querier) RemoveDomain(ctx context.Context, domain *objects.Domain) error {
+	defer metrics.ObserveQuery("RemoveDomain")()
+	_, err := q.handle.ExecContext(ctx, q.dialect.Rebind("DELETE FROM domains WHERE id=?"), domain.ID)
+	return err
+}
+
+// RemoveDomainsByUser removes the custom domains of a user from database
+func (q *Querier) RemoveDomainsByUser(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("RemoveDomainsByUser")()
+	_, err := q.handle.ExecContext(ctx, q.dialect.Rebind("DELETE FROM domains WHERE user_id=?"), user.ID)
+	return err
+}
+
+// Get the domains matching the where clause.
+func (q *Querier) getDomainsWhere(ctx context.Context, domains *[]objects.Domain, where string, args ...interface{}) error {
+	rows, err := q.handle.QueryContext(ctx, q.dialect.Rebind("SELECT "+domainColumns+" FROM domains WHERE "+where+" ORDER BY id"), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var domain objects.Domain
+		if err = scanDomain(rows, &domain); err != nil {
+			return err
+		}
+		*domains = append(*domains, domain)
+	}
+	return rows.Err()
+}
+
+// GetDomainsByUser returns the custom domains of a user
+func (q *Querier) GetDomainsByUser(ctx context.Context, userID int, domains *[]objects.Domain) error {
+	defer metrics.ObserveQuery("GetDomainsByUser")()
+	return q.getDomainsWhere(ctx, domains, "user_id=?", userID)
+}
+
+// GetDomainsByApp returns the custom domains of an app
+func (q *Querier) GetDomainsByApp(ctx context.Context, userID int, app string, domains *[]objects.Domain) error {
+	defer metrics.ObserveQuery("GetDomainsByApp")()
+	return q.getDomainsWhere(ctx, domains, "user_id=? AND app=?", userID, app)
+}
+
+// GetDomainsByName returns the claims of a custom domain given its name, the
+// first added first. At most one of them is verified.
+func (q *Querier) GetDomainsByName(ctx context.Context, name string, domains *[]objects.Domain) error {
+	defer metrics.ObserveQuery("GetDomainsByName")()
+	return q.getDomainsWhere(ctx, domains, "domain=?", name)
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// MemoryStore keeps the users, deployments, domains and audit events in
+// memory, e.g. for tests and local runs. It is lost on restart.
+type MemoryStore struct {
+	mu          sync.Mutex
+	users       []objects.User
+	deployments []objects.Deployment
+	domains     []objects.Domain
+	auditEvents []objects.AuditEvent
+	nextID      int
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+// Return a new ID, unique across the objects of the store.
+func (m *MemoryStore) newID() int {
+	id := m.nextID
+	m.nextID++
+	return id
+}
+
+// AddUser adds the user with a new ID.
+func (m *MemoryStore) AddUser(ctx context.Context, user *objects.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	added := *user
+	added.ID = m.newID()
+	added.LastSeenAt = time.Now().UTC()
+	m.users = append(m.users, added)
+	return nil
+}
+
+// GetUsers appends all the users to users.
+func (m *MemoryStore) GetUsers(ctx context.Context, users *[]objects.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	*users = append(*users, m.users...)
+	return nil
+}
+
+// Get the first user matching, user is left unchanged if none matches.
+func (m *MemoryStore) getUserWhere(match func(*objects.User) bool, user *objects.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.users {
+		if match(&m.users[i]) {
+			*user = m.users[i]
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetUserByName returns a user given userName
+func (m *MemoryStore) GetUserByName(ctx context.Context, userName string, user *objects.User) error {
+	return m.getUserWhere(func(u *objects.User) bool { return u.Name == userName }, user)
+}
+
+// GetUserByEmail returns a user given userEmail
+func (m *MemoryStore) GetUserByEmail(ctx context.Context, userEmail string, user *objects.User) error {
+	return m.getUserWhere(func(u *objects.User) bool { return u.Email == userEmail }, user)
+}
+
+// GetUserByID returns a user given userID
+func (m *MemoryStore) GetUserByID(ctx context.Context, userID int, user *objects.User) error {
+	return m.getUserWhere(func(u *objects.User) bool { return u.ID == userID }, user)
+}
+
+// GetUserBySpace returns the user owning a namespace
+func (m *MemoryStore) GetUserBySpace(ctx context.Context, space string, user *objects.User) error {
+	return m.getUserWhere(func(u *objects.User) bool { return u.Space == space }, user)
+}
+
+// Apply update to the user with the ID of user, if any.
+func (m *MemoryStore) updateUser(user *objects.User, update func(*objects.User)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.users {
+		if m.users[i].ID == user.ID {
+			update(&m.users[i])
+		}
+	}
+	return nil
+}
+
+// SetUserMaxApps updates the maximum apps deploy count override of a user.
+func (m *MemoryStore) SetUserMaxApps(ctx context.Context, user *objects.User) error {
+	return m.updateUser(user, func(u *objects.User) { u.MaxApps = user.MaxApps })
+}
+
+// SetUserPlan updates the subscription plan of a user.
+func (m *MemoryStore) SetUserPlan(ctx context.Context, user *objects.User) error {
+	return m.updateUser(user, func(u *objects.User) { u.Plan = user.Plan })
+}
+
+// SetUserIssuer updates the identity provider of a user.
+func (m *MemoryStore) SetUserIssuer(ctx context.Context, user *objects.User) error {
+	return m.updateUser(user, func(u *objects.User) { u.Issuer = user.Issuer })
+}
+
+// TouchUser records the user as active now.
+func (m *MemoryStore) TouchUser(ctx context.Context, user *objects.User) error {
+	now := time.Now().UTC()
+	return m.updateUser(user, func(u *objects.User) { u.LastSeenAt = now })
+}
+
+// Remove the users matching.
+func (m *MemoryStore) removeUsersWhere(match func(*objects.User) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.users[:0]
+	for _, u := range m.users {
+		if !match(&u) {
+			kept = append(kept, u)
+		}
+	}
+	m.users = kept
+	return nil
+}
+
+// RemoveUserByEmail removes the users with the email of user.
+func (m *MemoryStore) RemoveUserByEmail(ctx context.Context, user *objects.User) error {
+	return m.removeUsersWhere(func(u *objects.User) bool { return u.Email == user.Email })
+}
+
+// RemoveUserByID removes the user with the ID of user.
+func (m *MemoryStore) RemoveUserByID(ctx context.Context, user *objects.User) error {
+	return m.removeUsersWhere(func(u *objects.User) bool { return u.ID == user.ID })
+}
+
+// RemoveUserByName removes the users with the name of user.
+func (m *MemoryStore) RemoveUserByName(ctx context.Context, user *objects.User) error {
+	return m.removeUsersWhere(func(u *objects.User) bool { return u.Name == user.Name })
+}
+
+// AddDeployment adds a pending deployment, and sets its ID.
+func (m *MemoryStore) AddDeployment(ctx context.Context, deployment *objects.Deployment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deployment.ID = m.newID()
+	deployment.CreatedAt = time.Now().UTC()
+	deployment.Outcome = objects.DeploymentPending
+	m.deployments = append(m.deployments, *deployment)
+	return nil
+}
+
+// FinishDeployment records the outcome and revision of a deployment.
+func (m *MemoryStore) FinishDeployment(ctx context.Context, deployment *objects.Deployment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deployment.FinishedAt = time.Now().UTC()
+	for i := range m.deployments {
+		if m.deployments[i].ID == deployment.ID {
+			m.deployments[i].FinishedAt = deployment.FinishedAt
+			m.deployments[i].Revision = deployment.Revision
+			m.deployments[i].Outcome = deployment.Outcome
+			m.deployments[i].Error = deployment.Error
+		}
+	}
+	return nil
+}
+
+// GetDeploymentsByApp returns the deployments of an app, the latest first
+func (m *MemoryStore) GetDeploymentsByApp(ctx context.Context, userID int, app string, deployments *[]objects.Deployment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.deployments) - 1; i >= 0; i-- {
+		if m.deployments[i].UserID == userID && m.deployments[i].App == app {
+			*deployments = append(*deployments, m.deployments[i])
+		}
+	}
+	return nil
+}
+
+// GetDeployment returns a deployment given its ID, deployment is left
+// unchanged if it doesn't exist.
+func (m *MemoryStore) GetDeployment(ctx context.Context, id int, deployment *objects.Deployment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deployments {
+		if d.ID == id {
+			*deployment = d
+		}
+	}
+	return nil
+}
+
+// RemoveDeploymentsByUser removes the deployments of a user.
+func (m *MemoryStore) RemoveDeploymentsByUser(ctx context.Context, user *objects.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.deployments[:0]
+	for _, d := range m.deployments {
+		if d.UserID != user.ID {
+			kept = append(kept, d)
+		}
+	}
+	m.deployments = kept
+	return nil
+}
+
+// AddDomain adds a claim of a custom domain pending verification, and sets its ID.
+func (m *MemoryStore) AddDomain(ctx context.Context, domain *objects.Domain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	domain.ID = m.newID()
+	domain.CreatedAt = time.Now().UTC()
+	m.domains = append(m.domains, *domain)
+	return nil
+}
+
+// SetDomainVerified records the ownership of the domain as verified now.
+func (m *MemoryStore) SetDomainVerified(ctx context.Context, domain *objects.Domain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	domain.VerifiedAt = time.Now().UTC()
+	for i := range m.domains {
+		if m.domains[i].ID == domain.ID {
+			m.domains[i].VerifiedAt = domain.VerifiedAt
+		}
+	}
+	return nil
+}
+
+// Remove the domains matching.
+func (m *MemoryStore) removeDomainsWhere(match func(*objects.Domain) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.domains[:0]
+	for _, d := range m.domains {
+		if !match(&d) {
+			kept = append(kept, d)
+		}
+	}
+	m.domains = kept
+	return nil
+}
+
+// RemoveDomain removes a custom domain.
+func (m *MemoryStore) RemoveDomain(ctx context.Context, domain *objects.Domain) error {
+	return m.removeDomainsWhere(func(d *objects.Domain) bool { return d.ID == domain.ID })
+}
+
+// RemoveDomainsByUser removes the custom domains of a user.
+func (m *MemoryStore) RemoveDomainsByUser(ctx context.Context, user *objects.User) error {
+	return m.removeDomainsWhere(func(d *objects.Domain) bool { return d.UserID == user.ID })
+}
+
+// Append the domains matching to domains.
+func (m *MemoryStore) getDomainsWhere(match func(*objects.Domain) bool, domains *[]objects.Domain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.domains {
+		if match(&m.domains[i]) {
+			*domains = append(*domains, m.domains[i])
+		}
+	}
+	return nil
+}
+
+// GetDomainsByUser returns the custom domains of a user
+func (m *MemoryStore) GetDomainsByUser(ctx context.Context, userID int, domains *[]objects.Domain) error {
+	return m.getDomainsWhere(func(d *objects.Domain) bool { return d.UserID == userID }, domains)
+}
+
+// GetDomainsByApp returns the custom domains of an app
+func (m *MemoryStore) GetDomainsByApp(ctx context.Context, userID int, app string, domains *[]objects.Domain) error {
+	return m.getDomainsWhere(func(d *objects.Domain) bool { return d.UserID == userID && d.App == app }, domains)
+}
+
+// GetDomainsByName returns the claims of a custom domain given its name, the
+// first added first.
+func (m *MemoryStore) GetDomainsByName(ctx context.Context, name string, domains *[]objects.Domain) error {
+	return m.getDomainsWhere(func(d *objects.Domain) bool { return d.Domain == name }, domains)
+}
+
+// AddAuditEvent adds an audit event, and sets its ID.
+func (m *MemoryStore) AddAuditEvent(ctx context.Context, event *objects.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event.ID = m.newID()
+	event.CreatedAt = time.Now().UTC()
+	m.auditEvents = append(m.auditEvents, *event)
+	return nil
+}
+
+// GetAuditEvents returns the audit events matching the filter, the latest first.
+func (m *MemoryStore) GetAuditEvents(ctx context.Context, filter objects.AuditFilter, events *[]objects.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	matched := 0
+	for i := len(m.auditEvents) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && matched >= filter.Limit {
+			break
+		}
+		event := m.auditEvents[i]
+		if (filter.Actor != "" && event.Actor != filter.Actor) || (filter.Action != "" && event.Action != filter.Action) ||
+			(filter.Space != "" && event.Space != filter.Space) || event.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		*events = append(*events, event)
+		matched++
+	}
+	return nil
+}
@@ -1,86 +1,450 @@
 package db
 
 import (
-	"log"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
 	"sort"
 	"strings"
+	"time"
 
-	"database/sql"
+	"go.uber.org/zap"
 )
 
-// RunMigrations runs DB migrations at startup
-func RunMigrations(db *sql.DB) error {
-	if err := verifyMigrationsTable(db); err != nil {
-		return err
-	}
+// Migrations of every dialect. A migration NNN_name.sql can be rolled back
+// by its paired NNN_name.down.sql.
+//
+//go:embed schema
+var schemaFS embed.FS
+
+const downSuffix = ".down.sql"
+
+// How long to wait for the migrations lock held by another replica.
+var migrationsLockTimeout = 5 * time.Minute
+
+// States of a migration.
+const (
+	MigrationApplied  = "applied"
+	MigrationPending  = "pending"
+	MigrationModified = "modified"
+	// Applied migration missing from the schema files, e.g. after a
+	// downgrade of the controller.
+	MigrationMissing = "missing"
+)
+
+// Migration of the schema, its checksum is the one of the up statements.
+type Migration struct {
+	Name     string
+	Checksum string
+	Up       string
+	Down     string
+}
+
+// MigrationStatus is the state of a migration in the database.
+type MigrationStatus struct {
+	Name      string
+	State     string
+	AppliedAt time.Time
+}
+
+// Migration recorded in the migrations table.
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
 
-	count, err := countMigrations(db)
+// LoadMigrations returns the migrations of the dialect, sorted by name.
+func LoadMigrations(dialect Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(schemaFS, dialect.SchemaDir())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	names := AssetNames()
-	sort.Strings(names)
-
-	log.Printf("Files: %s", names)
-	for i, file := range names {
-		// skip running ones we've clearly already ran
-		if count > 0 {
-			count--
+	migrations := []Migration{}
+	downs := map[string]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		data, err := fs.ReadFile(schemaFS, path.Join(dialect.SchemaDir(), name))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(name, downSuffix) {
+			downs[strings.TrimSuffix(name, downSuffix)+".sql"] = string(data)
 			continue
 		}
+		migrations = append(migrations, Migration{Name: name, Checksum: checksum(data), Up: string(data)})
+	}
 
-		migration, err := Asset(file)
+	for i := range migrations {
+		migrations[i].Down = downs[migrations[i].Name]
+		delete(downs, migrations[i].Name)
+	}
+	for name := range downs {
+		return nil, fmt.Errorf("Down migration of %v has no up migration", name)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Name < migrations[j].Name })
+	return migrations, nil
+}
+
+// Find the migration named name, with or without its .sql extension, or
+// numbered name, e.g. 003.
+func findMigration(migrations []Migration, name string) (*Migration, error) {
+	for i, migration := range migrations {
+		base := strings.TrimSuffix(migration.Name, ".sql")
+		if name == migration.Name || name == base || name == strings.SplitN(base, "_", 2)[0] {
+			return &migrations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Migration %v not found", name)
+}
+
+// Migrate initializes and upgrades database
+func (db *Querier) Migrate(ctx context.Context) error {
+	return db.MigrateUp(ctx, "")
+}
+
+// MigrateUp runs the pending migrations, up to and including the migration
+// to if set. It fails if an applied migration was modified.
+func (db *Querier) MigrateUp(ctx context.Context, to string) error {
+	migrations, err := LoadMigrations(db.dialect)
+	if err != nil {
+		return err
+	}
+	last := ""
+	if to != "" {
+		target, err := findMigration(migrations, to)
 		if err != nil {
 			return err
 		}
+		last = target.Name
+	}
 
-		log.Printf("Running %s", file)
-		err = runMigration(i, migration, db)
+	return db.withMigrationsLock(ctx, func() error {
+		applied, err := db.appliedMigrations(ctx)
 		if err != nil {
 			return err
 		}
 
-		log.Printf("Migrations ran successfully %s", file)
-		cleanName := strings.TrimPrefix(file, "schema/")
-		err = recordMigration(cleanName, db)
+		for _, migration := range migrations {
+			if done, ok := applied[migration.Name]; ok {
+				if err := db.verifyChecksum(ctx, migration, done); err != nil {
+					return err
+				}
+				continue
+			}
+			if last != "" && migration.Name > last {
+				continue
+			}
+
+			zap.S().Infof("Running migration %v", migration.Name)
+			if err := db.runMigration(ctx, migration.Name, migration.Up, func(tx execer) error {
+				_, err := tx.ExecContext(ctx, db.dialect.Rebind("INSERT INTO migrations (name, checksum) VALUES (?, ?)"),
+					migration.Name, migration.Checksum)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the applied migrations after the migration to, or
+// the last applied migration if to is not set.
+func (db *Querier) MigrateDown(ctx context.Context, to string) error {
+	migrations, err := LoadMigrations(db.dialect)
+	if err != nil {
+		return err
+	}
+	first := ""
+	if to != "" {
+		target, err := findMigration(migrations, to)
 		if err != nil {
 			return err
 		}
+		first = target.Name
+	}
+
+	return db.withMigrationsLock(ctx, func() error {
+		applied, err := db.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Name]; !ok {
+				continue
+			}
+			if migration.Name <= first {
+				break
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("Migration %v has no down migration", migration.Name)
+			}
+
+			zap.S().Infof("Rolling back migration %v", migration.Name)
+			if err := db.runMigration(ctx, migration.Name, migration.Down, func(tx execer) error {
+				_, err := tx.ExecContext(ctx, db.dialect.Rebind("DELETE FROM migrations WHERE name=?"), migration.Name)
+				return err
+			}); err != nil {
+				return err
+			}
+			if to == "" {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// MigrationStatus returns the state of the migrations of the schema files,
+// followed by the applied ones missing from them.
+func (db *Querier) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(db.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.verifyMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	statuses := []MigrationStatus{}
+	for _, migration := range migrations {
+		status := MigrationStatus{Name: migration.Name, State: MigrationPending}
+		if done, ok := applied[migration.Name]; ok {
+			status.State = MigrationApplied
+			status.AppliedAt = done.appliedAt
+			if done.checksum != "" && done.checksum != migration.Checksum {
+				status.State = MigrationModified
+			}
+			delete(applied, migration.Name)
+		}
+		statuses = append(statuses, status)
+	}
+
+	missing := []MigrationStatus{}
+	for name, done := range applied {
+		missing = append(missing, MigrationStatus{Name: name, State: MigrationMissing, AppliedAt: done.appliedAt})
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Name < missing[j].Name })
+	return append(statuses, missing...), nil
+}
+
+// Fail if the applied migration was modified since. Migrations applied
+// before checksums were recorded get the current one.
+func (db *Querier) verifyChecksum(ctx context.Context, migration Migration, applied appliedMigration) error {
+	if applied.checksum == "" {
+		_, err := db.handle.ExecContext(ctx, db.dialect.Rebind("UPDATE migrations SET checksum=? WHERE name=?"),
+			migration.Checksum, migration.Name)
+		return err
+	}
+	if applied.checksum != migration.Checksum {
+		return fmt.Errorf("Migration %v was modified after it was applied, add a new migration instead", migration.Name)
+	}
 	return nil
 }
 
-func verifyMigrationsTable(db *sql.DB) error {
-	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS migrations (
-		id INTEGER PRIMARY KEY /*!40101 AUTO_INCREMENT*/,
-		created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
-		name VARCHAR(128) NOT NULL UNIQUE
-	  );`)
+// ExecContext of sql.DB and sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Run the statements of a migration and record it, in a transaction if the
+// dialect supports transactional DDL.
+func (db *Querier) runMigration(ctx context.Context, name, statements string, record func(execer) error) error {
+	run := func(exec execer) error {
+		for i, statement := range SplitStatements(statements, db.dialect) {
+			if _, err := exec.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("Migration %v failed at statement %v: %v", name, i+1, err)
+			}
+		}
+		return record(exec)
+	}
+
+	if !db.dialect.transactionalDDL() {
+		// Every statement is committed, a failed migration is fixed by hand.
+		return run(db.handle)
+	}
+
+	tx, err := db.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = run(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Create the migrations table, or add the checksum column to the table of
+// the previous versions.
+func (db *Querier) verifyMigrationsTable(ctx context.Context) error {
+	if _, err := db.handle.ExecContext(ctx, db.dialect.migrationsTable()); err != nil {
+		return err
+	}
+	rows, err := db.handle.QueryContext(ctx, "SELECT checksum FROM migrations WHERE 1=0")
+	if err == nil {
+		return rows.Close()
+	}
+	_, err = db.handle.ExecContext(ctx, "ALTER TABLE migrations ADD COLUMN checksum VARCHAR(64)")
 	return err
 }
 
-func countMigrations(db *sql.DB) (int, error) {
-	row := db.QueryRow(`SELECT count(*) as count FROM migrations;`)
+// Get the migrations recorded in the migrations table, by name.
+func (db *Querier) appliedMigrations(ctx context.Context) (map[string]appliedMigration, error) {
+	rows, err := db.handle.QueryContext(ctx, "SELECT name, checksum, created_at FROM migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]appliedMigration{}
+	for rows.Next() {
+		var name string
+		var sum sql.NullString
+		var createdAt time.Time
+		if err = rows.Scan(&name, &sum, &createdAt); err != nil {
+			return nil, err
+		}
+		applied[name] = appliedMigration{checksum: NullStrToStr(sum), appliedAt: createdAt}
+	}
+	return applied, rows.Err()
+}
+
+// Run fn holding the migrations lock of the dialect, so that the replicas
+// starting at once migrate one after the other.
+func (db *Querier) withMigrationsLock(ctx context.Context, fn func() error) error {
+	lockCtx, cancel := context.WithTimeout(ctx, migrationsLockTimeout)
+	defer cancel()
 
-	var count int
-	err := row.Scan(&count)
+	// Session locks are bound to a connection of the pool.
+	conn, err := db.handle.Conn(lockCtx)
 	if err != nil {
-		return 0, err
+		return err
 	}
+	defer conn.Close()
 
-	return count, nil
+	unlock, err := db.dialect.lockMigrations(lockCtx, conn)
+	if err != nil {
+		return fmt.Errorf("Failed to lock the migrations: %v", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			zap.S().Errorf("Failed to unlock the migrations: %v", err)
+		}
+	}()
+
+	if err = db.verifyMigrationsTable(ctx); err != nil {
+		return err
+	}
+	return fn()
 }
 
-func runMigration(num int, buf []byte, db *sql.DB) error {
-	// TODO: split queries, in single file.
-	_, err := db.Exec(string(buf))
-	return err
+// SplitStatements splits a migration into its statements, on the semicolons
+// outside of quotes and comments. Statements holding only comments are
+// dropped, the comments of the others are kept, e.g. the MySQL /*!40101 */.
+func SplitStatements(statements string, dialect Dialect) []string {
+	split := []string{}
+	start := 0
+	// Whether the current statement has anything but comments and spaces.
+	code := false
+	add := func(end int) {
+		if statement := strings.TrimSpace(statements[start:end]); code && statement != "" {
+			split = append(split, statement)
+		}
+		start = end + 1
+		code = false
+	}
+
+	for i := 0; i < len(statements); i++ {
+		c := statements[i]
+		switch {
+		case c == ';':
+			add(i)
+		case c == '-' && strings.HasPrefix(statements[i:], "--"):
+			i = skipUntil(statements, i+2, "\n") - 1
+		case c == '/' && strings.HasPrefix(statements[i:], "/*"):
+			if strings.HasPrefix(statements[i:], "/*!") {
+				// Executable MySQL comment.
+				code = true
+			}
+			i = skipUntil(statements, i+2, "*/") - 1
+		case c == '\'' || c == '"' || c == '`':
+			code = true
+			i = skipQuoted(statements, i, dialect == MySQL)
+		case c == '$' && dialect == Postgres:
+			code = true
+			if tag := dollarTag(statements[i:]); tag != "" {
+				i = skipUntil(statements, i+len(tag), tag) - 1
+			}
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			code = true
+		}
+	}
+	add(len(statements))
+	return split
 }
 
-func recordMigration(name string, db *sql.DB) error {
-	query := `INSERT INTO migrations (name) VALUES (?);`
-	_, err := db.Exec(query, name)
-	return err
+// Return the index after the end marker searched from i, or the length of s.
+func skipUntil(s string, i int, end string) int {
+	if i > len(s) {
+		return len(s)
+	}
+	if j := strings.Index(s[i:], end); j >= 0 {
+		return i + j + len(end)
+	}
+	return len(s)
+}
+
+// Return the index of the quote closing the one at i, doubled quotes and
+// backslash escapes if enabled are part of the literal.
+func skipQuoted(s string, i int, backslash bool) int {
+	quote := s[i]
+	for i++; i < len(s); i++ {
+		switch {
+		case backslash && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(s)
+}
+
+// Return the Postgres dollar quote tag starting s, e.g. $$ or $body$.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
 }
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Open an empty database of the dialect, without running the migrations.
+func openEmpty(t *testing.T, dialect Dialect, src string) *Querier {
+	t.Helper()
+	q, err := Open(dialect, src)
+	assert.NilError(t, err)
+	t.Cleanup(func() { q.GetHandle().Close() })
+	return q
+}
+
+func sqliteSrc(t *testing.T) string {
+	return fmt.Sprintf("file:%v?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+}
+
+// Names of the migrations in the state.
+func migrationsIn(t *testing.T, q *Querier, state string) []string {
+	t.Helper()
+	statuses, err := q.MigrationStatus(context.Background())
+	assert.NilError(t, err)
+	names := []string{}
+	for _, status := range statuses {
+		if status.State == state {
+			names = append(names, status.Name)
+		}
+	}
+	return names
+}
+
+func TestLoadMigrations(t *testing.T) {
+	sqlite, err := LoadMigrations(SQLite)
+	assert.NilError(t, err)
+	postgres, err := LoadMigrations(Postgres)
+	assert.NilError(t, err)
+
+	// Every dialect has the same migrations, and each can be rolled back.
+	assert.Equal(t, len(sqlite), len(postgres))
+	for i, migration := range sqlite {
+		assert.Equal(t, migration.Name, postgres[i].Name)
+		assert.Assert(t, !strings.HasSuffix(migration.Name, downSuffix), migration.Name)
+		assert.Assert(t, migration.Down != "" && postgres[i].Down != "", migration.Name)
+		assert.Equal(t, len(migration.Checksum), 64)
+	}
+
+	migration, err := findMigration(sqlite, "003")
+	assert.NilError(t, err)
+	assert.Equal(t, migration.Name, "003_audit_events.sql")
+	migration, err = findMigration(sqlite, "005_domains")
+	assert.NilError(t, err)
+	assert.Equal(t, migration.Name, "005_domains.sql")
+	_, err = findMigration(sqlite, "999")
+	assert.ErrorContains(t, err, "Migration 999 not found")
+}
+
+func TestSplitStatements(t *testing.T) {
+	for _, tc := range []struct {
+		dialect    Dialect
+		statements string
+		expected   []string
+	}{
+		{SQLite, "", []string{}},
+		{SQLite, "ALTER TABLE users ADD COLUMN plan VARCHAR(64);", []string{"ALTER TABLE users ADD COLUMN plan VARCHAR(64)"}},
+		{MySQL, `CREATE TABLE users(
+        id INTEGER PRIMARY KEY /*!40101 AUTO_INCREMENT */,
+        name VARCHAR(1024)
+);
+-- The plan; of the user.
+ALTER TABLE users ADD COLUMN plan VARCHAR(64) DEFAULT 'free;paid';
+/* Done; */`, []string{
+			"CREATE TABLE users(\n        id INTEGER PRIMARY KEY /*!40101 AUTO_INCREMENT */,\n        name VARCHAR(1024)\n)",
+			"-- The plan; of the user.\nALTER TABLE users ADD COLUMN plan VARCHAR(64) DEFAULT 'free;paid'",
+		}},
+		{MySQL, `INSERT INTO t VALUES ('it\'s;', "a;b", ` + "`c;d`" + `); SELECT 1`, []string{
+			`INSERT INTO t VALUES ('it\'s;', "a;b", ` + "`c;d`" + `)`, "SELECT 1",
+		}},
+		{Postgres, `INSERT INTO t VALUES ('it''s;'); CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;`, []string{
+			`INSERT INTO t VALUES ('it''s;')`, "CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql",
+		}},
+		{Postgres, "SELECT $1; SELECT 2", []string{"SELECT $1", "SELECT 2"}},
+	} {
+		assert.DeepEqual(t, SplitStatements(tc.statements, tc.dialect), tc.expected)
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	testMigrate(t, openEmpty(t, SQLite, sqliteSrc(t)))
+}
+
+func TestMigratePostgres(t *testing.T) {
+	src := os.Getenv(postgresDSNEnv)
+	if src == "" {
+		t.Skipf("%v is not set", postgresDSNEnv)
+	}
+	ctx := context.Background()
+	q := openEmpty(t, Postgres, src)
+	// Start from an empty schema, and leave a migrated one.
+	assert.NilError(t, q.Migrate(ctx))
+	assert.NilError(t, q.MigrateDown(ctx, "000"))
+	assert.NilError(t, q.MigrateDown(ctx, ""))
+	t.Cleanup(func() { q.Migrate(ctx) })
+	testMigrate(t, q)
+}
+
+// Run the migrations of the dialect up and down on an empty database.
+func testMigrate(t *testing.T, q *Querier) {
+	ctx := context.Background()
+	migrations, err := LoadMigrations(q.Dialect())
+	assert.NilError(t, err)
+	all := []string{}
+	for _, migration := range migrations {
+		all = append(all, migration.Name)
+	}
+
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationPending), all)
+
+	assert.NilError(t, q.MigrateUp(ctx, "002"))
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationApplied), all[:3])
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationPending), all[3:])
+
+	assert.NilError(t, q.Migrate(ctx))
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationApplied), all)
+	// Migrations are not ran twice.
+	assert.NilError(t, q.Migrate(ctx))
+
+	// The last migration is rolled back, and can be applied again.
+	assert.NilError(t, q.MigrateDown(ctx, ""))
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationPending), all[len(all)-1:])
+	assert.NilError(t, q.Migrate(ctx))
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationApplied), all)
+
+	assert.NilError(t, q.MigrateDown(ctx, "003_audit_events.sql"))
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationApplied), all[:4])
+	_, err = q.GetHandle().Exec("SELECT domain FROM domains")
+	assert.Assert(t, err != nil, "domains were not dropped")
+
+	// Modified migrations are reported, and stop the upgrade.
+	_, err = q.GetHandle().Exec(q.Dialect().Rebind("UPDATE migrations SET checksum=? WHERE name=?"), "modified", all[1])
+	assert.NilError(t, err)
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationModified), all[1:2])
+	assert.ErrorContains(t, q.Migrate(ctx), all[1]+" was modified")
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationPending), all[4:])
+
+	// Migrations applied before checksums were recorded get the current one.
+	_, err = q.GetHandle().Exec(q.Dialect().Rebind("UPDATE migrations SET checksum=NULL WHERE name=?"), all[1])
+	assert.NilError(t, err)
+	assert.NilError(t, q.Migrate(ctx))
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationApplied), all)
+
+	// Applied migrations unknown to this version are reported.
+	_, err = q.GetHandle().Exec("INSERT INTO migrations (name) VALUES ('999_future.sql')")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, migrationsIn(t, q, MigrationMissing), []string{"999_future.sql"})
+	_, err = q.GetHandle().Exec("DELETE FROM migrations WHERE name='999_future.sql'")
+	assert.NilError(t, err)
+}
+
+func TestMigrateLegacyTable(t *testing.T) {
+	q := openEmpty(t, SQLite, sqliteSrc(t))
+	// Table and first migrations of the previous versions, without checksums.
+	_, err := q.GetHandle().Exec(`CREATE TABLE migrations (
+		id INTEGER PRIMARY KEY,
+		created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+		name VARCHAR(128) NOT NULL UNIQUE
+	);`)
+	assert.NilError(t, err)
+	migrations, err := LoadMigrations(SQLite)
+	assert.NilError(t, err)
+	for _, migration := range migrations[:2] {
+		_, err = q.GetHandle().Exec(migration.Up)
+		assert.NilError(t, err)
+		_, err = q.GetHandle().Exec("INSERT INTO migrations (name) VALUES (?)", migration.Name)
+		assert.NilError(t, err)
+	}
+
+	assert.NilError(t, q.Migrate(context.Background()))
+	assert.Equal(t, len(migrationsIn(t, q, MigrationApplied)), len(migrations))
+
+	var sum string
+	assert.NilError(t, q.GetHandle().QueryRow("SELECT checksum FROM migrations WHERE name=?", migrations[0].Name).Scan(&sum))
+	assert.Equal(t, sum, migrations[0].Checksum)
+}
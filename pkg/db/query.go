@@ -1,10 +1,17 @@
 package db
 
 import (
+	"context"
+	"database/sql/driver"
 	"fmt"
-	// MySQL drivers
+	"time"
+
+	// SQL drivers
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+
 	"github.com/platform9/app-controller/pkg/options"
 
 	"database/sql"
@@ -13,43 +20,185 @@ import (
 
 // Querier facilitates querying appbert objects from DB
 type Querier struct {
-	handle *sql.DB
+	handle  *sql.DB
+	dialect Dialect
 }
 
-var dbInstance *Querier
+var (
+	dbInstance *Querier
+	dbMutex    sync.Mutex
+)
+
+// Delay before the first retry to connect, doubled up to maxConnectBackoff.
+var (
+	connectBackoff    = 500 * time.Millisecond
+	maxConnectBackoff = 15 * time.Second
+)
+
+// Config of the database connection and its pool.
+type Config struct {
+	Dialect Dialect
+	Src     string
+
+	// Zero keeps the defaults of database/sql: unlimited open connections
+	// and lifetimes, and 2 idle connections.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// How long Connect retries to reach the database.
+	ConnectTimeout time.Duration
+}
+
+// ConfigFromOptions returns the database config of the options.
+func ConfigFromOptions() (Config, error) {
+	config := Config{
+		Dialect:         Dialect(options.GetDBType()),
+		MaxOpenConns:    options.GetDBMaxOpenConns(),
+		MaxIdleConns:    options.GetDBMaxIdleConns(),
+		ConnMaxLifetime: options.GetDBConnMaxLifetime(),
+		ConnMaxIdleTime: options.GetDBConnMaxIdleTime(),
+		ConnectTimeout:  options.GetDBConnectTimeout(),
+	}
+	switch config.Dialect {
+	case SQLite:
+		config.Src = options.GetDBSrc()
+	case MySQL:
+		config.Src = options.GetDBCreds()
+	case Postgres:
+		config.Src = options.GetPostgresDSN()
+	default:
+		return config, fmt.Errorf("DB type %s is not supported", config.Dialect)
+	}
+	return config, nil
+}
+
+// Init connects to the database of the options, retrying until it is
+// reachable or the connect timeout expires. It is called at startup, so
+// that Get returns the connected database.
+func Init(ctx context.Context) error {
+	config, err := ConfigFromOptions()
+	if err != nil {
+		return err
+	}
+	q, err := Connect(ctx, config)
+	if err != nil {
+		return err
+	}
 
-//var pkgDBInstance *Querier
-var onceDB sync.Once
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	dbInstance = q
+	return nil
+}
 
-// Get returns handle to DB
-//go:generate go-bindata -pkg db -o migrations_generated.go schema/
+// Get returns handle to DB, connected by Init. If Init was not called the
+// database is opened without checking that it is reachable, its outages are
+// then returned by the queries.
 func Get() *Querier {
-	onceDB.Do(func() {
-		var db *sql.DB
-		var err error
-		switch dbType := options.GetDBType(); dbType {
-		case "sqlite3":
-			db, err = sql.Open(dbType, options.GetDBSrc())
-		case "mysql":
-			db, err = sql.Open(dbType, options.GetDBCreds())
-		default:
-			panic(fmt.Sprintf("DB type %s is not supported", dbType))
-		}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if dbInstance != nil {
+		return dbInstance
+	}
 
-		if err != nil {
-			panic(err)
-		}
+	config, err := ConfigFromOptions()
+	if err == nil {
+		dbInstance, err = open(config)
+	}
+	if err != nil {
+		zap.S().Errorf("Failed to open the database: %v", err)
+		dbInstance = &Querier{handle: sql.OpenDB(errConnector{err}), dialect: config.Dialect}
+	}
+	return dbInstance
+}
+
+// Connector of a database that can't be opened, every query returns err.
+type errConnector struct {
+	err error
+}
+
+func (c errConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
 
-		if err = db.Ping(); err != nil {
-			panic(err)
+func (c errConnector) Driver() driver.Driver {
+	return nil
+}
+
+// Open the database of the config and set up its pool.
+func open(config Config) (*Querier, error) {
+	db, err := sql.Open(string(config.Dialect), config.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	return &Querier{
+		handle:  db,
+		dialect: config.Dialect,
+	}, nil
+}
+
+// Connect opens the database of the config, and waits for it to be
+// reachable with an exponential backoff, up to the connect timeout if set.
+func Connect(ctx context.Context, config Config) (*Querier, error) {
+	q, err := open(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+		defer cancel()
+	}
+
+	backoff := connectBackoff
+	for attempt := 1; ; attempt++ {
+		err = q.Ping(ctx)
+		if err == nil {
+			return q, nil
+		}
+		if ctx.Err() == nil {
+			zap.S().Warnf("Failed to connect to the %v database, retrying in %v. Error: %v", config.Dialect, backoff, err)
 		}
 
-		dbInstance = &Querier{
-			handle: db,
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+			if backoff *= 2; backoff > maxConnectBackoff {
+				backoff = maxConnectBackoff
+			}
+			continue
 		}
-	})
+		q.handle.Close()
+		return nil, fmt.Errorf("Failed to connect to the %v database after %v attempts: %v", config.Dialect, attempt, err)
+	}
+}
 
-	return dbInstance
+// Open connects to the database of the dialect at src, without retrying.
+func Open(dialect Dialect, src string) (*Querier, error) {
+	q, err := open(Config{Dialect: dialect, Src: src})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = q.Ping(context.Background()); err != nil {
+		q.handle.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Dialect returns the SQL dialect of the database.
+func (db *Querier) Dialect() Dialect {
+	return db.dialect
 }
 
 // GetHandle returns DB handle
@@ -57,26 +206,41 @@ func (db *Querier) GetHandle() *sql.DB {
 	return db.handle
 }
 
-func (db *Querier) DropData() {
-	tx, err := db.handle.Begin()
+// Ping checks the connectivity to the database.
+func (db *Querier) Ping(ctx context.Context) error {
+	return db.handle.PingContext(ctx)
+}
+
+// DropData removes the users, audit events, domains and deployments.
+func (db *Querier) DropData(ctx context.Context) error {
+	tx, err := db.handle.BeginTx(ctx, nil)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	for _, t := range []string{"users"} {
-		stmt, err := tx.Prepare(fmt.Sprintf("delete from %s", t))
-		if err != nil {
-			panic(err)
-		}
-		if _, err = stmt.Exec(); err != nil {
-			panic(err)
+	for _, t := range []string{"users", "audit_events", "domains", "deployments"} {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("delete from %s", t)); err != nil {
+			tx.Rollback()
+			return err
 		}
 	}
 
-	tx.Commit()
+	return tx.Commit()
 }
 
-// Migrate initializes and upgrades database
-func (db *Querier) Migrate() error {
-	return RunMigrations(db.handle)
+// Run the insert statement in the transaction and return the ID of the row
+// added. Postgres doesn't support LastInsertId, the ID is returned instead.
+func (db *Querier) insertID(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int, error) {
+	if db.dialect == Postgres {
+		var id int
+		err := tx.QueryRowContext(ctx, db.dialect.Rebind(query+" RETURNING id"), args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
 }
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+
+	"github.com/platform9/app-controller/pkg/options"
+)
+
+func TestConnect(t *testing.T) {
+	defer func(backoff time.Duration) { connectBackoff = backoff }(connectBackoff)
+	connectBackoff = 10 * time.Millisecond
+
+	q, err := Connect(context.Background(), Config{
+		Dialect:        SQLite,
+		Src:            sqliteSrc(t),
+		MaxOpenConns:   4,
+		ConnectTimeout: time.Second,
+	})
+	assert.NilError(t, err)
+	defer q.GetHandle().Close()
+	assert.Equal(t, q.GetHandle().Stats().MaxOpenConnections, 4)
+
+	// The directory of the database doesn't exist, every attempt fails.
+	start := time.Now()
+	_, err = Connect(context.Background(), Config{
+		Dialect:        SQLite,
+		Src:            "file:" + filepath.Join(t.TempDir(), "missing", "app.db"),
+		ConnectTimeout: 100 * time.Millisecond,
+	})
+	assert.ErrorContains(t, err, "Failed to connect to the sqlite3 database after")
+	assert.Assert(t, time.Since(start) >= 100*time.Millisecond)
+}
+
+func TestGetUnavailable(t *testing.T) {
+	config := options.Default()
+	config.DB.Type = "oracle"
+	options.Set(config)
+	defer options.Set(options.Default())
+	defer func() { dbInstance = nil }()
+	dbInstance = nil
+
+	// Queries fail instead of panicking.
+	q := Get()
+	assert.ErrorContains(t, q.Ping(context.Background()), "DB type oracle is not supported")
+	assert.ErrorContains(t, q.DropData(context.Background()), "DB type oracle is not supported")
+	assert.Equal(t, Get(), q)
+}
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN plan VARCHAR(64);
@@ -0,0 +1,10 @@
+CREATE TABLE audit_events(
+        id INTEGER PRIMARY KEY /*!40101 AUTO_INCREMENT */,
+        created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+        actor VARCHAR(1024),
+        action VARCHAR(128),
+        space VARCHAR(1024),
+        target VARCHAR(1024),
+        outcome VARCHAR(64),
+        detail VARCHAR(4096)
+);
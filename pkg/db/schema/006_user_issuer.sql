@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN issuer VARCHAR(64);
@@ -0,0 +1,15 @@
+CREATE TABLE domain_claims(
+        id INTEGER PRIMARY KEY /*!40101 AUTO_INCREMENT */,
+        created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+        user_id INTEGER NOT NULL,
+        space VARCHAR(1024),
+        app VARCHAR(1024),
+        domain VARCHAR(253) NOT NULL,
+        token VARCHAR(128),
+        verified_at TIMESTAMP NULL DEFAULT NULL
+);
+INSERT INTO domain_claims(id, created_at, user_id, space, app, domain, token, verified_at)
+        SELECT id, created_at, user_id, space, app, domain, token, verified_at FROM domains;
+DROP TABLE domains;
+ALTER TABLE domain_claims RENAME TO domains;
+CREATE INDEX domains_domain ON domains(domain);
@@ -0,0 +1,7 @@
+CREATE TABLE users(
+        id SERIAL PRIMARY KEY,
+        name VARCHAR(1024),
+        email VARCHAR(1024),
+        space VARCHAR(1024)
+);
+
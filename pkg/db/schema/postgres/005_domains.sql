@@ -0,0 +1,10 @@
+CREATE TABLE domains(
+        id SERIAL PRIMARY KEY,
+        created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+        user_id INTEGER NOT NULL,
+        space VARCHAR(1024),
+        app VARCHAR(1024),
+        domain VARCHAR(253) NOT NULL UNIQUE,
+        token VARCHAR(128),
+        verified_at TIMESTAMP NULL DEFAULT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE audit_events ADD COLUMN request_id VARCHAR(64);
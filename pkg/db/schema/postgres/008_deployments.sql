@@ -0,0 +1,14 @@
+CREATE TABLE deployments(
+        id SERIAL PRIMARY KEY,
+        created_at TIMESTAMP NOT NULL DEFAULT current_timestamp,
+        finished_at TIMESTAMP NULL DEFAULT NULL,
+        user_id INTEGER NOT NULL,
+        space VARCHAR(1024),
+        app VARCHAR(1024),
+        actor VARCHAR(1024),
+        spec TEXT,
+        revision VARCHAR(1024),
+        outcome VARCHAR(64),
+        error VARCHAR(4096)
+);
+CREATE INDEX deployments_user_id ON deployments(user_id);
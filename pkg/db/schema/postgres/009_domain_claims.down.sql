@@ -0,0 +1,5 @@
+DELETE FROM domains
+        WHERE NOT (verified_at IS NOT NULL OR (id IN (SELECT MIN(id) FROM domains GROUP BY domain)
+                AND domain NOT IN (SELECT domain FROM domains WHERE verified_at IS NOT NULL)));
+DROP INDEX domains_domain;
+ALTER TABLE domains ADD CONSTRAINT domains_domain_key UNIQUE (domain);
@@ -0,0 +1,2 @@
+ALTER TABLE domains DROP CONSTRAINT domains_domain_key;
+CREATE INDEX domains_domain ON domains(domain);
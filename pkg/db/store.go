@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// UserStore persists the users. The getters leave the user unchanged if
+// none matches.
+type UserStore interface {
+	AddUser(ctx context.Context, user *objects.User) error
+	GetUsers(ctx context.Context, users *[]objects.User) error
+	GetUserByName(ctx context.Context, userName string, user *objects.User) error
+	GetUserByEmail(ctx context.Context, userEmail string, user *objects.User) error
+	GetUserByID(ctx context.Context, userID int, user *objects.User) error
+	GetUserBySpace(ctx context.Context, space string, user *objects.User) error
+	SetUserMaxApps(ctx context.Context, user *objects.User) error
+	SetUserPlan(ctx context.Context, user *objects.User) error
+	SetUserIssuer(ctx context.Context, user *objects.User) error
+	TouchUser(ctx context.Context, user *objects.User) error
+	RemoveUserByEmail(ctx context.Context, user *objects.User) error
+	RemoveUserByID(ctx context.Context, user *objects.User) error
+	RemoveUserByName(ctx context.Context, user *objects.User) error
+}
+
+// DeploymentStore persists the history of the deployments of the apps.
+type DeploymentStore interface {
+	AddDeployment(ctx context.Context, deployment *objects.Deployment) error
+	FinishDeployment(ctx context.Context, deployment *objects.Deployment) error
+	GetDeploymentsByApp(ctx context.Context, userID int, app string, deployments *[]objects.Deployment) error
+	GetDeployment(ctx context.Context, id int, deployment *objects.Deployment) error
+	RemoveDeploymentsByUser(ctx context.Context, user *objects.User) error
+}
+
+// DomainStore persists the custom domains claimed by the apps.
+type DomainStore interface {
+	AddDomain(ctx context.Context, domain *objects.Domain) error
+	SetDomainVerified(ctx context.Context, domain *objects.Domain) error
+	RemoveDomain(ctx context.Context, domain *objects.Domain) error
+	RemoveDomainsByUser(ctx context.Context, user *objects.User) error
+	GetDomainsByUser(ctx context.Context, userID int, domains *[]objects.Domain) error
+	GetDomainsByApp(ctx context.Context, userID int, app string, domains *[]objects.Domain) error
+	GetDomainsByName(ctx context.Context, name string, domains *[]objects.Domain) error
+}
+
+// AuditStore persists the audit events.
+type AuditStore interface {
+	AddAuditEvent(ctx context.Context, event *objects.AuditEvent) error
+	GetAuditEvents(ctx context.Context, filter objects.AuditFilter, events *[]objects.AuditEvent) error
+}
+
+// Store persists all the objects of the API.
+type Store interface {
+	UserStore
+	DeploymentStore
+	DomainStore
+	AuditStore
+}
+
+// Stores combines a store of each object into a Store.
+type Stores struct {
+	UserStore
+	DeploymentStore
+	DomainStore
+	AuditStore
+}
+
+var _ Store = Stores{}
+
+var _ Store = (*Querier)(nil)
@@ -1,22 +1,26 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"go.uber.org/zap"
 
+	"github.com/platform9/app-controller/pkg/metrics"
 	"github.com/platform9/app-controller/pkg/objects"
 )
 
 // AddUser adds user to database
-func (q *Querier) AddUser(user *objects.User) error {
-	tx, err := q.handle.Begin()
+func (q *Querier) AddUser(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("AddUser")()
+	tx, err := q.handle.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	stmtIns, err := tx.Prepare("INSERT INTO users(name, email, space) values(?, ?, ?)")
+	stmtIns, err := tx.PrepareContext(ctx, q.dialect.Rebind("INSERT INTO users(name, email, space, plan, last_seen_at, issuer) values(?, ?, ?, ?, ?, ?)"))
 
 	if err != nil {
 		return err
@@ -24,7 +28,7 @@ func (q *Querier) AddUser(user *objects.User) error {
 
 	defer stmtIns.Close()
 
-	if _, err = stmtIns.Exec(user.Name, user.Email, user.Space); err != nil {
+	if _, err = stmtIns.ExecContext(ctx, user.Name, user.Email, user.Space, user.Plan, time.Now().UTC(), user.Issuer); err != nil {
 		log.Error(err, ": Error inserting ", user.Name)
 		return err
 	}
@@ -32,175 +36,290 @@ func (q *Querier) AddUser(user *objects.User) error {
 	return tx.Commit()
 }
 
-// RemoveUser removes user from database based on email
-func (q *Querier) RemoveUserByEmail(user *objects.User) error {
-	tx, err := q.handle.Begin()
+// SetUserMaxApps updates the maximum apps deploy count override of a user,
+// a value of 0 removes the override.
+func (q *Querier) SetUserMaxApps(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("SetUserMaxApps")()
+	tx, err := q.handle.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	stmtIns, err := tx.Prepare("DELETE FROM users WHERE email=?")
+	stmtUpd, err := tx.PrepareContext(ctx, q.dialect.Rebind("UPDATE users SET max_apps=? WHERE id=?"))
 
 	if err != nil {
-		zap.S().Errorf(err.Error())
+		tx.Rollback()
 		return err
 	}
 
-	defer stmtIns.Close()
-	if _, err = stmtIns.Exec(user.Email); err != nil {
-		log.Error(err, ": Error deleting ", user.Email)
+	defer stmtUpd.Close()
+
+	if _, err = stmtUpd.ExecContext(ctx, IntToNullInt(user.MaxApps), user.ID); err != nil {
+		log.Error(err, ": Error updating ", user.Name)
+		tx.Rollback()
 		return err
 	}
 
 	return tx.Commit()
 }
 
-// RemoveUser removes user from database based on name
-func (q *Querier) RemoveUserByName(user *objects.User) error {
-	tx, err := q.handle.Begin()
+// SetUserPlan updates the subscription plan of a user.
+func (q *Querier) SetUserPlan(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("SetUserPlan")()
+	tx, err := q.handle.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	stmtIns, err := tx.Prepare("DELETE FROM users WHERE name=?")
+	stmtUpd, err := tx.PrepareContext(ctx, q.dialect.Rebind("UPDATE users SET plan=? WHERE id=?"))
 
 	if err != nil {
-		zap.S().Errorf(err.Error())
+		tx.Rollback()
 		return err
 	}
 
-	defer stmtIns.Close()
-	if _, err = stmtIns.Exec(user.Name); err != nil {
-		log.Error(err, ": Error deleting ", user.Name)
+	defer stmtUpd.Close()
+
+	if _, err = stmtUpd.ExecContext(ctx, user.Plan, user.ID); err != nil {
+		log.Error(err, ": Error updating ", user.Name)
+		tx.Rollback()
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func NullStrToStr(ns sql.NullString) string {
-	if ns.Valid {
-		return ns.String
-	} else {
-		return ""
+// SetUserIssuer updates the identity provider of a user.
+func (q *Querier) SetUserIssuer(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("SetUserIssuer")()
+	tx, err := q.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmtUpd, err := tx.PrepareContext(ctx, q.dialect.Rebind("UPDATE users SET issuer=? WHERE id=?"))
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	defer stmtUpd.Close()
+
+	if _, err = stmtUpd.ExecContext(ctx, user.Issuer, user.ID); err != nil {
+		log.Error(err, ": Error updating ", user.Name)
+		tx.Rollback()
+		return err
 	}
+
+	return tx.Commit()
 }
 
-// GetUsers returns a list of users from database
-func (q *Querier) GetUsers(users *[]objects.User) error {
-	tx, err := q.handle.Begin()
+// RemoveUser removes user from database based on email
+func (q *Querier) RemoveUserByEmail(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("RemoveUserByEmail")()
+	tx, err := q.handle.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	rows, err := tx.Query("SELECT id, name, email, space FROM users")
+	stmtIns, err := tx.PrepareContext(ctx, q.dialect.Rebind("DELETE FROM users WHERE email=?"))
 
 	if err != nil {
+		zap.S().Errorf(err.Error())
 		return err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var name, email, space sql.NullString
-		var id int
-		if err = rows.Scan(&id, &name, &email, &space); err != nil {
-			return err
-		}
-		*users = append(*users, objects.User{
-			ID:    id,
-			Name:  NullStrToStr(name),
-			Email: NullStrToStr(email),
-			Space: NullStrToStr(space),
-		})
+	defer stmtIns.Close()
+	if _, err = stmtIns.ExecContext(ctx, user.Email); err != nil {
+		log.Error(err, ": Error deleting ", user.Email)
+		return err
 	}
 
 	return tx.Commit()
 }
 
-// GetUserByName returns a user given userName
-func (q *Querier) GetUserByName(userName string, user *objects.User) error {
-	tx, err := q.handle.Begin()
+// RemoveUserByID removes user from database based on id
+func (q *Querier) RemoveUserByID(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("RemoveUserByID")()
+	tx, err := q.handle.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	rows, err := tx.Query("SELECT id, email, space FROM users WHERE name=?", userName)
+	stmtDel, err := tx.PrepareContext(ctx, q.dialect.Rebind("DELETE FROM users WHERE id=?"))
 
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
 
-	found := false
-	if rows.Next() {
-		var email sql.NullString
-		var space sql.NullString
-		var id int
-		err = rows.Scan(&id, &email, &space)
+	defer stmtDel.Close()
+	if _, err = stmtDel.ExecContext(ctx, user.ID); err != nil {
+		log.Error(err, ": Error deleting ", user.ID)
+		tx.Rollback()
+		return err
+	}
 
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
+	return tx.Commit()
+}
 
-		found = true
-		*user = objects.User{
-			ID:    id,
-			Name:  userName,
-			Email: NullStrToStr(email),
-			Space: NullStrToStr(space),
-		}
+// RemoveUser removes user from database based on name
+func (q *Querier) RemoveUserByName(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("RemoveUserByName")()
+	tx, err := q.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
 
-	if !found {
-		tx.Rollback()
-		return nil
+	stmtIns, err := tx.PrepareContext(ctx, q.dialect.Rebind("DELETE FROM users WHERE name=?"))
+
+	if err != nil {
+		zap.S().Errorf(err.Error())
+		return err
 	}
 
-	rows.Close()
+	defer stmtIns.Close()
+	if _, err = stmtIns.ExecContext(ctx, user.Name); err != nil {
+		log.Error(err, ": Error deleting ", user.Name)
+		return err
+	}
 
 	return tx.Commit()
 }
 
-// GetUserByEmail returns a user given userEmail
-func (q *Querier) GetUserByEmail(userEmail string, user *objects.User) error {
-	tx, err := q.handle.Begin()
-	if err != nil {
+func NullStrToStr(ns sql.NullString) string {
+	if ns.Valid {
+		return ns.String
+	} else {
+		return ""
+	}
+}
+
+func NullIntToInt(ni sql.NullInt64) int {
+	if ni.Valid {
+		return int(ni.Int64)
+	}
+	return 0
+}
+
+// IntToNullInt stores 0 as NULL.
+func IntToNullInt(i int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: i != 0}
+}
+
+// Columns of the users table read into objects.User by scanUser.
+const userColumns = "id, name, email, space, max_apps, plan, last_seen_at, issuer"
+
+// Scan a row of userColumns into user.
+func scanUser(rows *sql.Rows, user *objects.User) error {
+	var name, email, space, plan, issuer sql.NullString
+	var maxApps sql.NullInt64
+	var lastSeenAt sql.NullTime
+	var id int
+	if err := rows.Scan(&id, &name, &email, &space, &maxApps, &plan, &lastSeenAt, &issuer); err != nil {
 		return err
 	}
 
-	rows, err := tx.Query("SELECT id, name, space FROM users WHERE email=?", userEmail)
+	*user = objects.User{
+		ID:         id,
+		Name:       NullStrToStr(name),
+		Email:      NullStrToStr(email),
+		Space:      NullStrToStr(space),
+		MaxApps:    NullIntToInt(maxApps),
+		Plan:       NullStrToStr(plan),
+		LastSeenAt: lastSeenAt.Time,
+		Issuer:     NullStrToStr(issuer),
+	}
+	return nil
+}
 
+// GetUsers returns a list of users from database
+func (q *Querier) GetUsers(ctx context.Context, users *[]objects.User) error {
+	defer metrics.ObserveQuery("GetUsers")()
+	tx, err := q.handle.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	found := false
-	if rows.Next() {
-		var name sql.NullString
-		var space sql.NullString
-		var id int
-		err = rows.Scan(&id, &name, &space)
+	rows, err := tx.QueryContext(ctx, "SELECT "+userColumns+" FROM users")
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer rows.Close()
 
-		if err != nil {
+	for rows.Next() {
+		var user objects.User
+		if err = scanUser(rows, &user); err != nil {
 			tx.Rollback()
 			return err
 		}
+		*users = append(*users, user)
+	}
 
-		found = true
-		*user = objects.User{
-			ID:    id,
-			Name:  NullStrToStr(name),
-			Email: userEmail,
-			Space: NullStrToStr(space),
-		}
+	return tx.Commit()
+}
+
+// Get the first user matching the where clause, user is left unchanged if
+// none matches.
+func (q *Querier) getUserWhere(ctx context.Context, where string, arg interface{}, user *objects.User) error {
+	tx, err := q.handle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, q.dialect.Rebind("SELECT "+userColumns+" FROM users WHERE "+where), arg)
+
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	if !found {
+	if !rows.Next() {
+		rows.Close()
 		tx.Rollback()
 		return nil
 	}
 
+	err = scanUser(rows, user)
 	rows.Close()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	return tx.Commit()
 }
+
+// GetUserByName returns a user given userName
+func (q *Querier) GetUserByName(ctx context.Context, userName string, user *objects.User) error {
+	defer metrics.ObserveQuery("GetUserByName")()
+	return q.getUserWhere(ctx, "name=?", userName, user)
+}
+
+// GetUserByEmail returns a user given userEmail
+func (q *Querier) GetUserByEmail(ctx context.Context, userEmail string, user *objects.User) error {
+	defer metrics.ObserveQuery("GetUserByEmail")()
+	return q.getUserWhere(ctx, "email=?", userEmail, user)
+}
+
+// GetUserByID returns a user given userID
+func (q *Querier) GetUserByID(ctx context.Context, userID int, user *objects.User) error {
+	defer metrics.ObserveQuery("GetUserByID")()
+	return q.getUserWhere(ctx, "id=?", userID, user)
+}
+
+// GetUserBySpace returns the user owning a namespace
+func (q *Querier) GetUserBySpace(ctx context.Context, space string, user *objects.User) error {
+	defer metrics.ObserveQuery("GetUserBySpace")()
+	return q.getUserWhere(ctx, "space=?", space, user)
+}
+
+// TouchUser records the user as active now.
+func (q *Querier) TouchUser(ctx context.Context, user *objects.User) error {
+	defer metrics.ObserveQuery("TouchUser")()
+	_, err := q.handle.ExecContext(ctx, q.dialect.Rebind("UPDATE users SET last_seen_at=? WHERE id=?"), time.Now().UTC(), user.ID)
+	return err
+}
@@ -0,0 +1,75 @@
+// Package domains verifies the ownership of custom domains attached to apps.
+package domains
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Prefix of the TXT record holding the verification token of a domain.
+const challengePrefix = "_app-controller-challenge."
+
+// Resolver looks up DNS TXT records, it is implemented by net.Resolver and
+// stubbed in tests.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DefaultResolver uses the system DNS resolver.
+var DefaultResolver Resolver = net.DefaultResolver
+
+// Normalize lowercases the domain and strips the trailing dot.
+func Normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// Validate checks that the domain is a valid hostname with at least two labels.
+func Validate(domain string) error {
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+		return fmt.Errorf("Invalid domain %v: %v", domain, strings.Join(errs, ", "))
+	}
+	if !strings.Contains(domain, ".") {
+		return fmt.Errorf("Invalid domain %v: must be a fully qualified domain name", domain)
+	}
+	return nil
+}
+
+// ChallengeRecord returns the name of the TXT record the owner of the domain
+// publishes the token in.
+func ChallengeRecord(domain string) string {
+	return challengePrefix + domain
+}
+
+// NewToken returns a random verification token.
+func NewToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Verify checks that the token is published in the challenge record of the domain.
+func Verify(ctx context.Context, resolver Resolver, domain string, token string) (bool, error) {
+	records, err := resolver.LookupTXT(ctx, ChallengeRecord(domain))
+	if err != nil {
+		// A missing record is a failed verification, not an error.
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == token {
+			return true, nil
+		}
+	}
+	return false, nil
+}
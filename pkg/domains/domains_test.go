@@ -0,0 +1,45 @@
+package domains
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Resolver stub serving TXT records from a map.
+type stubResolver map[string][]string
+
+func (s stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := s[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	resolver := stubResolver{
+		"_app-controller-challenge.app.example.com": {"other", "token"},
+	}
+
+	ok, err := Verify(ctx, resolver, "app.example.com", "token")
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+
+	ok, err = Verify(ctx, resolver, "app.example.com", "wrong")
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+
+	ok, err = Verify(ctx, resolver, "missing.example.com", "token")
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NilError(t, Validate(Normalize("App.Example.com.")))
+	assert.ErrorContains(t, Validate("localhost"), "fully qualified")
+	assert.ErrorContains(t, Validate("bad_domain.com"), "Invalid domain")
+}
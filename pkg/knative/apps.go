@@ -9,61 +9,31 @@ import (
 	"strings"
 	"time"
 
-	"github.com/platform9/app-controller/pkg/options"
+	"github.com/platform9/app-controller/pkg/objects"
 	"github.com/platform9/app-controller/pkg/util"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/kubectl/pkg/cmd/create"
-	"knative.dev/client/pkg/kn/commands"
 	servinglib "knative.dev/client/pkg/serving"
 	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	network "knative.dev/networking/pkg"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 )
 
-func GetApps(kubeconfig string, space string) (apps_list string, err error) {
-	// Initialize the knative parameters
-	knParams := &commands.KnParams{}
-	knParams.KubeCfgPath = kubeconfig
-	knParams.Initialize()
-
-	// Fetch the knative serving client for a given knative space
-	client, err := knParams.NewServingClient(space)
-	if err != nil {
-		zap.S().Errorf("Error while creating a knative serving client: %v", err)
-		return "", err
-	}
-
-	// Create an empty context, required for knative APIs
-	ctx := context.Background()
-
+// GetApps lists the apps of the space as JSON.
+func (c *Clients) GetApps(ctx context.Context, space string) (apps_list string, err error) {
 	// Call the knative API wrapper
-	return listAllApps(client, ctx)
+	return listAllApps(c.ServingClient(space), ctx)
 }
 
-// Get app by name
-func GetAppByName(kubeconfig string, space string, appName string) (apps_list string, err error) {
-	// Initialize the knative parameters
-	knParams := &commands.KnParams{}
-	knParams.KubeCfgPath = kubeconfig
-	knParams.Initialize()
-
-	// Fetch the knative serving client for a given knative space
-	client, err := knParams.NewServingClient(space)
-	if err != nil {
-		zap.S().Errorf("Error while creating a knative serving client: %v", err)
-		return "", err
-	}
-
-	// Create an empty context, required for knative APIs
-	ctx := context.Background()
-
+// GetAppByName returns the app of the space as JSON.
+func (c *Clients) GetAppByName(ctx context.Context, space string, appName string) (apps_list string, err error) {
 	// Call the knative API wrapper to get service by Name
-	return getAppByName(client, ctx, appName)
-
+	return getAppByName(c.ServingClient(space), ctx, appName)
 }
 
 func containerOfPodSpec(spec *corev1.PodSpec) *corev1.Container {
@@ -80,7 +50,10 @@ func constructService(
 	image string,
 	env []corev1.EnvVar,
 	port string,
-	secretname string) (service servingv1.Service, err error) {
+	secretname string,
+	plan objects.Plan,
+	ttl time.Duration,
+	visibility string) (service servingv1.Service, err error) {
 
 	service = servingv1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -89,6 +62,21 @@ func constructService(
 		},
 	}
 
+	// Schedule the deletion of the app, keeping the ttl to export it.
+	if ttl > 0 {
+		service.Annotations = map[string]string{
+			util.ExpiresAtAnnotation: time.Now().Add(ttl).UTC().Format(time.RFC3339),
+			util.TTLAnnotation:       ttl.String(),
+		}
+	}
+
+	// Keep cluster-local apps off the ingress of the cluster.
+	if label := visibilityLabel(visibility); label != "" {
+		service.Labels = map[string]string{
+			network.VisibilityLabelKey: label,
+		}
+	}
+
 	service.Spec.Template = servingv1.RevisionTemplateSpec{
 		Spec: servingv1.RevisionSpec{},
 		ObjectMeta: metav1.ObjectMeta{
@@ -120,8 +108,27 @@ func constructService(
 		}}
 	}
 
-	max_scale := options.GetConstraintMaxScale()
-	servinglib.UpdateMaxScale(template, max_scale)
+	// Limit the container resources as per the plan.
+	limits := corev1.ResourceList{}
+	if plan.CPU != "" {
+		cpu, err := resource.ParseQuantity(plan.CPU)
+		if err != nil {
+			return service, fmt.Errorf("Invalid cpu limit %v of plan %v. Error: %v", plan.CPU, plan.Name, err)
+		}
+		limits[corev1.ResourceCPU] = cpu
+	}
+	if plan.Memory != "" {
+		memory, err := resource.ParseQuantity(plan.Memory)
+		if err != nil {
+			return service, fmt.Errorf("Invalid memory limit %v of plan %v. Error: %v", plan.Memory, plan.Name, err)
+		}
+		limits[corev1.ResourceMemory] = memory
+	}
+	if len(limits) > 0 {
+		container.Resources.Limits = limits
+	}
+
+	servinglib.UpdateMaxScale(template, plan.MaxScale)
 	return service, nil
 }
 
@@ -208,7 +215,8 @@ func extractRegistryURL(url string) (containerURL string, err error) {
 
 // Inject container secrets into the namespace
 func injectContainerImageSecrets(
-	kubeconfig string,
+	ctx context.Context,
+	clientset kubernetes.Interface,
 	space string,
 	secretname string,
 	username string,
@@ -221,20 +229,6 @@ func injectContainerImageSecrets(
 		return err
 	}
 
-	// create config structure instance from the kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		zap.S().Errorf("Error while creating config object from kubeconfig: %v", err)
-		return err
-	}
-
-	// create clientset from the kubeconfig in-mem structure
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		zap.S().Errorf("Error while creating clientset: %v", err)
-		return err
-	}
-
 	// Create in-mem secretDockerRegistryOptions that are used to create the secretDockerRegistry structure
 	var secretDockerRegistry *corev1.Secret = nil
 	secretDockerRegistryOptions := create.CreateSecretDockerRegistryOptions{
@@ -258,7 +252,7 @@ func injectContainerImageSecrets(
 
 	createOptions := metav1.CreateOptions{}
 	// Fire secret creation CoreV1 API.
-	secretDockerRegistry, err = clientset.CoreV1().Secrets(space).Create(context.TODO(), secretDockerRegistry, createOptions)
+	secretDockerRegistry, err = clientset.CoreV1().Secrets(space).Create(ctx, secretDockerRegistry, createOptions)
 	if err != nil {
 		zap.S().Errorf("Error creating docker secrets: %v", err)
 		return err
@@ -267,8 +261,10 @@ func injectContainerImageSecrets(
 	return nil
 }
 
-func CreateApp(
-	kubeconfig string,
+// CreateApp deploys the app in the space, with the registry credentials
+// stored in the secret if set. The revision is named by knative if empty.
+func (c *Clients) CreateApp(
+	ctx context.Context,
 	appname string,
 	space string,
 	image string,
@@ -276,39 +272,25 @@ func CreateApp(
 	port string,
 	secretname string,
 	username string,
-	password string) (err error) {
-
-	// Initialize the knative parameters
-	knParams := &commands.KnParams{}
-	knParams.KubeCfgPath = kubeconfig
-	knParams.Initialize()
+	password string,
+	plan objects.Plan,
+	ttl time.Duration,
+	visibility string,
+	revision string) (err error) {
 
 	// Fetch the knative serving client for a given knative space
-	client, err := knParams.NewServingClient(space)
-	if err != nil {
-		zap.S().Errorf("Error while creating a knative serving client: %v", err)
-		return err
-	}
-
-	// Create an empty context, required for knative APIs
-	ctx := context.Background()
+	client := c.ServingClient(space)
 
-	// Check for maximum apps deploy limit.
-	stopDeploy, err := maxAppDeployed(kubeconfig, space)
+	// Enforce the maximum apps deploy limit through the namespace quota.
+	err = c.EnsureAppQuota(ctx, space, plan.MaxApps)
 	if err != nil {
-		zap.S().Errorf("Error while checking maximum app deployed: %v", err)
 		return err
 	}
 
-	if stopDeploy {
-		zap.S().Errorf("Maximum Apps deploy limit reached!!")
-		return fmt.Errorf("Maximum App deploy limit reached!")
-	}
-
 	// If container secret info exists, create a secret in the k8s cluster.
 	if (username != "") &&
 		(password != "") {
-		err = injectContainerImageSecrets(kubeconfig, space, secretname, username, password, image)
+		err = injectContainerImageSecrets(ctx, c.Kube, space, secretname, username, password, image)
 		if err != nil {
 			zap.S().Errorf("Error while injecting the secrets object: %v", err)
 		}
@@ -317,11 +299,12 @@ func CreateApp(
 		secretname = ""
 	}
 
-	service, err := constructService(appname, space, image, env, port, secretname)
+	service, err := constructService(appname, space, image, env, port, secretname, plan, ttl, visibility)
 	if err != nil {
 		zap.S().Errorf("Error while creating the service object: %v", err)
 		return err
 	}
+	service.Spec.Template.Name = revision
 
 	zap.S().Debugf("Service : %v\n", service)
 
@@ -334,104 +317,187 @@ func CreateApp(
 	if serviceExists {
 		zap.S().Error("Service already exists.")
 		return fmt.Errorf("Service already exists")
-	} else {
-		err = createAppKnative(ctx, client, &service)
 	}
+
+	err = createAppKnative(ctx, client, &service)
 	if err != nil {
+		if secretname != "" {
+			// Not returning error as the app creation error is the one to report.
+			if errSecret := deleteSecret(ctx, c.Kube, space, secretname); errSecret != nil {
+				zap.S().Debugf("Error while deleting the app secret: %v", errSecret)
+			}
+		}
+		if isAppQuotaExceeded(err) {
+			zap.S().Errorf("Maximum Apps deploy limit reached!!")
+			return fmt.Errorf(util.MaxAppDeployError)
+		}
 		return err
 	}
 
 	return nil
 }
 
-// Delete an app by name
-func DeleteApp(kubeconfig string, space string, appName string) error {
-	// Initialize the knative parameters
-	knParams := &commands.KnParams{}
-	knParams.KubeCfgPath = kubeconfig
-	knParams.Initialize()
+// UpdateApp replaces the spec of an existing app, creating a new revision
+// named by knative if empty. The registry credentials replace the secret of
+// the app if set, otherwise the current one is kept.
+func (c *Clients) UpdateApp(
+	ctx context.Context,
+	appname string,
+	space string,
+	image string,
+	env []corev1.EnvVar,
+	port string,
+	secretname string,
+	username string,
+	password string,
+	plan objects.Plan,
+	ttl time.Duration,
+	visibility string,
+	revision string) error {
+
+	// Apply the app count limit of the current plan.
+	err := c.EnsureAppQuota(ctx, space, plan.MaxApps)
+	if err != nil {
+		return err
+	}
+
+	credentials := username != "" && password != ""
+	if credentials {
+		if errSecret := deleteSecret(ctx, c.Kube, space, secretname); errSecret != nil {
+			zap.S().Debugf("Error while deleting the app secret: %v", errSecret)
+		}
+		err = injectContainerImageSecrets(ctx, c.Kube, space, secretname, username, password, image)
+		if err != nil {
+			zap.S().Errorf("Error while injecting the secrets object: %v", err)
+		}
+	} else {
+		secretname = ""
+	}
+
+	desired, err := constructService(appname, space, image, env, port, secretname, plan, ttl, visibility)
+	if err != nil {
+		zap.S().Errorf("Error while creating the service object: %v", err)
+		return err
+	}
+	desired.Spec.Template.Name = revision
+
+	_, err = c.ServingClient(space).UpdateServiceWithRetry(ctx, appname, func(service *servingv1.Service) (*servingv1.Service, error) {
+		if !credentials {
+			desired.Spec.Template.Spec.ImagePullSecrets = service.Spec.Template.Spec.ImagePullSecrets
+		}
+		service.Spec.Template = desired.Spec.Template
+		// The new spec restarts the ttl and idle period of the app, so the
+		// reaper warns its owner again.
+		service.Annotations = updateMap(service.Annotations, map[string]string{
+			util.ExpiresAtAnnotation: desired.Annotations[util.ExpiresAtAnnotation],
+			util.TTLAnnotation:       desired.Annotations[util.TTLAnnotation],
+			util.WarnedAtAnnotation:  "",
+		})
+		service.Labels = updateMap(service.Labels, map[string]string{
+			network.VisibilityLabelKey: visibilityLabel(visibility),
+			util.IdleLabel:             "",
+		})
+		return service, nil
+	}, 3)
+	if err != nil {
+		zap.S().Errorf("Error while updating app %v: %v", appname, err)
+	}
+	return err
+}
+
+// AppExists checks if the app is deployed in the space.
+func (c *Clients) AppExists(ctx context.Context, space string, appName string) (bool, error) {
+	return serviceExists(ctx, c.ServingClient(space), appName)
+}
 
-	// Fetch the knative serving client for a given knative space
-	client, err := knParams.NewServingClient(space)
+// DryRunApp submits the app to the server-side dry run of knative, as an
+// update if it exists, without changing it. The pull secret is not checked.
+func (c *Clients) DryRunApp(
+	ctx context.Context,
+	appname string,
+	space string,
+	image string,
+	env []corev1.EnvVar,
+	port string,
+	secretname string,
+	plan objects.Plan,
+	ttl time.Duration,
+	visibility string) error {
+
+	desired, err := constructService(appname, space, image, env, port, secretname, plan, ttl, visibility)
+	if err != nil {
+		return err
+	}
+
+	services := c.Serving.ServingV1().Services(space)
+	current, err := services.Get(ctx, appname, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = services.Create(ctx, &desired, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		return err
+	}
 	if err != nil {
-		zap.S().Errorf("Error while creating a knative serving client: %v", err)
 		return err
 	}
+	current.Spec.Template = desired.Spec.Template
+	current.Labels = updateMap(current.Labels, map[string]string{
+		network.VisibilityLabelKey: visibilityLabel(visibility),
+	})
+	_, err = services.Update(ctx, current, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+	return err
+}
+
+// IsRejected checks if the error is knative or kubernetes rejecting an app,
+// e.g. an invalid spec or an exceeded quota, rather than failing.
+func IsRejected(err error) bool {
+	return apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) || apierrors.IsForbidden(err)
+}
 
-	// Create an empty context, required for knative APIs
-	ctx := context.Background()
+// DeleteApp deletes an app by name, along with its registry secret.
+func (c *Clients) DeleteApp(ctx context.Context, space string, appName string) error {
 	/* To delete service without any wait.
 	timeout -- duration to wait for a delete operation to finish.
 	*/
 	var timeout = time.Duration(0)
 
 	// Call the knative API wrapper to delete service by Name
-	err = deleteApp(client, ctx, appName, timeout)
+	err := deleteApp(c.ServingClient(space), ctx, appName, timeout)
 	if err != nil {
-                zap.S().Errorf("Error while deleting the app: %v", err)
-                return err
-        }
+		zap.S().Errorf("Error while deleting the app: %v", err)
+		return err
+	}
 
-	err = deleteSecret(kubeconfig, space, appName)
+	err = deleteSecret(ctx, c.Kube, space, appName)
 	if err != nil {
 		//Not returning error as it's not needed to show this to the user
-                zap.S().Debugf("Error while deleting the app secret: %v", err)
-        }
+		zap.S().Debugf("Error while deleting the app secret: %v", err)
+	}
 
 	return nil
 }
 
 //Delete the secret associated with the app being deleted
 //in case the app is deployed from private registry
-func deleteSecret(kubeconfig string, space string, appName string) error {
-	// create config structure instance from the kubeconfig
-        config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-        if err != nil {
-                zap.S().Debugf("Error creating config object from kubeconfig: %v ", err)
-                return err
-        }
-
-        // create clientset from the kubeconfig in-memory structure
-        clientset, err := kubernetes.NewForConfig(config)
-        if err != nil {
-                zap.S().Debugf("Error creating clientset: %v ", err)
-                return err
-        }
-
-        deleteOptions := metav1.DeleteOptions{}
-        // Fire secret deletion CoreV1 API.
+func deleteSecret(ctx context.Context, clientset kubernetes.Interface, space string, appName string) error {
+	deleteOptions := metav1.DeleteOptions{}
+	// Fire secret deletion CoreV1 API.
 	//Secret name is same as the app name.
 	//This will fail for public registry as secret won't be present.
 	//But this can be treated as no-op.
-        err = clientset.CoreV1().Secrets(space).Delete(context.TODO(), appName, deleteOptions)
-        if err != nil {
+	err := clientset.CoreV1().Secrets(space).Delete(ctx, appName, deleteOptions)
+	if err != nil {
 		zap.S().Debugf("Error deleting the secret: %v", err)
-                return err
-        }
+		return err
+	}
 
 	return nil
 }
 
-// Check if the apps deployed exceeds maxAppDeployCount.
-func maxAppDeployed(kubeconfig string, space string) (bool, error) {
-	get_apps, errMax := GetApps(kubeconfig, space)
-	if errMax != nil {
-		zap.S().Errorf("Error while listing apps: %v", errMax)
-		return false, errMax
-	}
-
-	var appList map[string]interface{}
-
-	err := json.Unmarshal([]byte(get_apps), &appList)
+// ListApps returns the knative services of the apps of the space.
+func (c *Clients) ListApps(ctx context.Context, space string) ([]servingv1.Service, error) {
+	list, err := c.ServingClient(space).ListServices(ctx)
 	if err != nil {
-		zap.S().Errorf("Failed to Unmarshal: %v", err)
-		return false, err
-	}
-
-	max_app := options.GetConstraintMaxAppDeploy()
-
-	if len(appList["items"].([]interface{})) >= max_app {
-		return true, nil
+		zap.S().Errorf("Error while listing apps: %v", err)
+		return nil, err
 	}
-	return false, nil
+	return list.Items, nil
 }
@@ -0,0 +1,42 @@
+package knative
+
+import (
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	servingclientset "knative.dev/serving/pkg/client/clientset/versioned"
+)
+
+// Clients of the cluster hosting knative, created once and shared by the
+// calls instead of being built from the kubeconfig by each of them.
+type Clients struct {
+	Kube    kubernetes.Interface
+	Serving servingclientset.Interface
+}
+
+// NewClients creates the clients of the cluster from the kubeconfig.
+func NewClients(kubeconfig string) (*Clients, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		zap.S().Errorf("Error while creating config object from kubeconfig: %v", err)
+		return nil, err
+	}
+
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		zap.S().Errorf("Error while creating clientset: %v", err)
+		return nil, err
+	}
+	serving, err := servingclientset.NewForConfig(config)
+	if err != nil {
+		zap.S().Errorf("Error while creating a knative serving clientset: %v", err)
+		return nil, err
+	}
+	return &Clients{Kube: kube, Serving: serving}, nil
+}
+
+// ServingClient returns the knative serving client for a given knative space.
+func (c *Clients) ServingClient(space string) clientservingv1.KnServingClient {
+	return clientservingv1.NewKnServingClient(c.Serving.ServingV1(), space)
+}
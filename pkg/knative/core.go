@@ -17,7 +17,7 @@ func listAllApps(client clientservingv1.KnServingClient, ctx context.Context) (s
 		return "", err
 	}
 
-	jsonAppList, err := json.Marshal(appsList)
+	jsonAppList, err := json.Marshal(newAppListView(appsList))
 	if err != nil {
 		zap.S().Errorf("Error while json marshalling the apps list: %v", err)
 		return "", err
@@ -32,7 +32,7 @@ func getAppByName(client clientservingv1.KnServingClient, ctx context.Context, a
 		zap.S().Errorf("Error while listing app: %v", err)
 		return "", err
 	}
-	jsonApp, err := json.Marshal(appGetByName)
+	jsonApp, err := json.Marshal(newAppView(*appGetByName))
 	if err != nil {
 		zap.S().Errorf("Error while json marshalling the app: %v", err)
 		return "", err
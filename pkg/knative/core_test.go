@@ -5,14 +5,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
 	"gotest.tools/assert"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 	clienttesting "k8s.io/client-go/testing"
 	v1 "knative.dev/client/pkg/serving/v1"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
 	servingv1fake "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1/fake"
 )
 
@@ -175,3 +180,27 @@ func TestDeleteApp(t *testing.T) {
 		assert.ErrorContains(t, err, nonExistingServiceName)
 	})
 }
+
+func TestUpdateAppResetsReaper(t *testing.T) {
+	service := newService("web")
+	service.Annotations = map[string]string{
+		util.ExpiresAtAnnotation: "2022-03-01T12:00:00Z",
+		util.WarnedAtAnnotation:  "2022-03-01T11:00:00Z",
+	}
+	service.Labels = map[string]string{util.IdleLabel: "true"}
+	clients := &Clients{Kube: k8sfake.NewSimpleClientset(), Serving: servingfake.NewSimpleClientset(service)}
+
+	err := clients.UpdateApp(context.Background(), "web", testNamespace, "nginx:2", nil, "", "", "", "",
+		objects.Plan{}, time.Hour, VisibilityPublic, "")
+	assert.NilError(t, err)
+
+	updated, err := clients.Serving.ServingV1().Services(testNamespace).Get(context.Background(), "web", metav1.GetOptions{})
+	assert.NilError(t, err)
+	_, warned := updated.Annotations[util.WarnedAtAnnotation]
+	assert.Assert(t, !warned, "the warning of the previous spec is kept")
+	_, idle := updated.Labels[util.IdleLabel]
+	assert.Assert(t, !idle, "the idle mark of the previous spec is kept")
+	expiresAt, ok := ExpiresAt(updated)
+	assert.Assert(t, ok)
+	assert.Assert(t, expiresAt.After(time.Now()), expiresAt)
+}
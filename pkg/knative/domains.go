@@ -0,0 +1,65 @@
+package knative
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingv1beta1 "knative.dev/serving/pkg/apis/serving/v1beta1"
+	servingclientset "knative.dev/serving/pkg/client/clientset/versioned"
+)
+
+// Constructor for the DomainMapping routing a custom domain to an app.
+func newDomainMappingObj(domain string, space string, appName string) *servingv1beta1.DomainMapping {
+	return &servingv1beta1.DomainMapping{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      domain,
+			Namespace: space,
+		},
+		Spec: servingv1beta1.DomainMappingSpec{
+			Ref: duckv1.KReference{
+				APIVersion: servingv1.SchemeGroupVersion.String(),
+				Kind:       "Service",
+				Name:       appName,
+				Namespace:  space,
+			},
+		},
+	}
+}
+
+func createDomainMapping(ctx context.Context, client servingclientset.Interface, domain string, space string, appName string) error {
+	_, err := client.ServingV1beta1().DomainMappings(space).Create(ctx, newDomainMappingObj(domain, space, appName), metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func deleteDomainMapping(ctx context.Context, client servingclientset.Interface, domain string, space string) error {
+	err := client.ServingV1beta1().DomainMappings(space).Delete(ctx, domain, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// CreateDomainMapping routes the custom domain to the app.
+func (c *Clients) CreateDomainMapping(ctx context.Context, space string, appName string, domain string) error {
+	err := createDomainMapping(ctx, c.Serving, domain, space, appName)
+	if err != nil {
+		zap.S().Errorf("Error while creating domain mapping %v: %v", domain, err)
+	}
+	return err
+}
+
+// DeleteDomainMapping removes the route of the custom domain.
+func (c *Clients) DeleteDomainMapping(ctx context.Context, space string, domain string) error {
+	err := deleteDomainMapping(ctx, c.Serving, domain, space)
+	if err != nil {
+		zap.S().Errorf("Error while deleting domain mapping %v: %v", domain, err)
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+package knative
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+)
+
+func TestDomainMapping(t *testing.T) {
+	ctx := context.Background()
+	client := servingfake.NewSimpleClientset()
+
+	t.Run("create a domain mapping to the app", func(t *testing.T) {
+		assert.NilError(t, createDomainMapping(ctx, client, "app.example.com", testNamespace, "app"))
+		// Creating it again, on a retried request, is not an error.
+		assert.NilError(t, createDomainMapping(ctx, client, "app.example.com", testNamespace, "app"))
+
+		mapping, err := client.ServingV1beta1().DomainMappings(testNamespace).Get(ctx, "app.example.com", metav1.GetOptions{})
+		assert.NilError(t, err)
+		assert.Equal(t, mapping.Spec.Ref.Kind, "Service")
+		assert.Equal(t, mapping.Spec.Ref.Name, "app")
+		assert.Equal(t, mapping.Spec.Ref.Namespace, testNamespace)
+	})
+
+	t.Run("delete a domain mapping", func(t *testing.T) {
+		assert.NilError(t, deleteDomainMapping(ctx, client, "app.example.com", testNamespace))
+		assert.NilError(t, deleteDomainMapping(ctx, client, "app.example.com", testNamespace))
+	})
+}
@@ -0,0 +1,89 @@
+package knative
+
+import (
+	"context"
+	"time"
+
+	"github.com/platform9/app-controller/pkg/util"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// ExpiresAt returns the time an app is scheduled for deletion, if it was
+// created with a ttl.
+func ExpiresAt(service *servingv1.Service) (time.Time, bool) {
+	value, ok := service.Annotations[util.ExpiresAtAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		zap.S().Errorf("Invalid %v annotation on app %v: %v", util.ExpiresAtAnnotation, service.Name, err)
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
+
+// WarnedAt returns when the owner of the app was warned of its deletion, and
+// false if they weren't.
+func WarnedAt(service *servingv1.Service) (time.Time, bool) {
+	value, ok := service.Annotations[util.WarnedAtAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	warnedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		zap.S().Errorf("Invalid %v annotation on app %v: %v", util.WarnedAtAnnotation, service.Name, err)
+		return time.Time{}, false
+	}
+	return warnedAt, true
+}
+
+// IdleSince returns when the latest ready revision of the app stopped
+// receiving traffic, and false if the app is active.
+func IdleSince(ctx context.Context, client clientservingv1.KnServingClient, service *servingv1.Service) (time.Time, bool, error) {
+	revisionName := service.Status.LatestReadyRevisionName
+	if revisionName == "" {
+		return time.Time{}, false, nil
+	}
+
+	revision, err := client.GetRevision(ctx, revisionName)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	// Knative marks the revision inactive when it is scaled to zero for lack of traffic.
+	active := revision.Status.GetCondition(servingv1.RevisionConditionActive)
+	if active == nil || active.Status != corev1.ConditionFalse {
+		return time.Time{}, false, nil
+	}
+	return active.LastTransitionTime.Inner.Time, true, nil
+}
+
+// UpdateAppMeta sets, or removes when the value is empty, annotations and
+// labels of an app without creating a new revision.
+func UpdateAppMeta(ctx context.Context, client clientservingv1.KnServingClient, appName string,
+	annotations map[string]string, labels map[string]string) error {
+	_, err := client.UpdateServiceWithRetry(ctx, appName, func(service *servingv1.Service) (*servingv1.Service, error) {
+		service.Annotations = updateMap(service.Annotations, annotations)
+		service.Labels = updateMap(service.Labels, labels)
+		return service, nil
+	}, 3)
+	return err
+}
+
+func updateMap(current map[string]string, updates map[string]string) map[string]string {
+	if current == nil {
+		current = map[string]string{}
+	}
+	for key, value := range updates {
+		if value == "" {
+			delete(current, key)
+			continue
+		}
+		current[key] = value
+	}
+	return current
+}
@@ -0,0 +1,174 @@
+package knative
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NamespaceLabels returns the labels of the namespace of a user: its ID,
+// identity provider and plan, along with the extra labels such as the Pod
+// Security admission ones. Unknown or invalid values are left out.
+func NamespaceLabels(user *objects.User, extra map[string]string) map[string]string {
+	labels := map[string]string{}
+	for key, value := range extra {
+		labels[key] = value
+	}
+	if user.ID != 0 {
+		labels[util.UserIDLabel] = strconv.Itoa(user.ID)
+	}
+	for key, value := range map[string]string{util.IssuerLabel: user.Issuer, util.PlanLabel: user.Plan} {
+		if value != "" && len(validation.IsValidLabelValue(value)) == 0 {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// NewNamespaceObj is the constructor for the namespace of a user, owner is
+// the identity the user is looked up by in DB.
+func NewNamespaceObj(name string, owner string, labels map[string]string) *corev1.Namespace {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{util.ManagedByLabel: util.ManagedByValue},
+			Annotations: map[string]string{util.OwnerAnnotation: owner},
+		},
+	}
+	for key, value := range labels {
+		ns.Labels[key] = value
+	}
+	return ns
+}
+
+// IsManagedNamespace checks if the namespace was created by app-controller.
+func IsManagedNamespace(ns *corev1.Namespace) bool {
+	return ns.Labels[util.ManagedByLabel] == util.ManagedByValue
+}
+
+// ListManagedNamespaces returns the namespaces created by app-controller,
+// the only ones its controllers act on.
+func ListManagedNamespaces(ctx context.Context, clientset kubernetes.Interface) ([]corev1.Namespace, error) {
+	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: util.ManagedByLabel + "=" + util.ManagedByValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return namespaces.Items, nil
+}
+
+// LabelNamespace sets the managed-by and given labels, and the owner
+// annotation on an existing namespace, and returns if it was updated.
+func LabelNamespace(ctx context.Context, clientset kubernetes.Interface, name string, owner string, labels map[string]string) (bool, error) {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	desired := NewNamespaceObj(name, owner, labels)
+	changed := false
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		if ns.Labels[key] != value {
+			ns.Labels[key] = value
+			changed = true
+		}
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	if ns.Annotations[util.OwnerAnnotation] != owner {
+		ns.Annotations[util.OwnerAnnotation] = owner
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	_, err = clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	if err != nil {
+		zap.S().Errorf("Failed to label namespace %v. Error: %v", name, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// EnsureNamespace creates the namespace of a user if it doesn't exist, and
+// returns if it was created.
+func EnsureNamespace(ctx context.Context, clientset kubernetes.Interface, name string, owner string, labels map[string]string) (bool, error) {
+	_, err := clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	_, err = clientset.CoreV1().Namespaces().Create(ctx, NewNamespaceObj(name, owner, labels), metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return false, nil
+	}
+	if err != nil {
+		zap.S().Errorf("Failed to create namespace %v. Error: %v", name, err)
+		return false, err
+	}
+	zap.S().Infof("Created namespace %v of %v", name, owner)
+	return true, nil
+}
+
+// FindOwnedNamespace returns the name of a namespace created for the owner,
+// empty if there is none. A login that failed after creating the namespace
+// resumes with it.
+func FindOwnedNamespace(ctx context.Context, clientset kubernetes.Interface, owner string) (string, error) {
+	namespaces, err := ListManagedNamespaces(ctx, clientset)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ns := range namespaces {
+		if ns.Annotations[util.OwnerAnnotation] == owner && ns.Status.Phase != corev1.NamespaceTerminating {
+			return ns.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// QuarantineNamespace labels a namespace without a user, so that it can be
+// reviewed before deletion.
+func QuarantineNamespace(ctx context.Context, clientset kubernetes.Interface, name string) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	ns.Labels[util.QuarantinedLabel] = "true"
+	_, err = clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	return err
+}
+
+// ReleaseNamespace removes the quarantine of a namespace claimed by its owner again.
+func ReleaseNamespace(ctx context.Context, clientset kubernetes.Interface, name string) error {
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if _, ok := ns.Labels[util.QuarantinedLabel]; !ok {
+		return nil
+	}
+	delete(ns.Labels, util.QuarantinedLabel)
+	_, err = clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	return err
+}
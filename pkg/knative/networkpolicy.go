@@ -0,0 +1,94 @@
+package knative
+
+import (
+	"context"
+
+	"github.com/platform9/app-controller/pkg/util"
+	"go.uber.org/zap"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Label set by kubernetes on every namespace, with the namespace name.
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// NewTenantPolicyObj is the constructor for the NetworkPolicy denying ingress
+// to the pods of a namespace from the other tenants. Pods of the same
+// namespace and of the allowed namespaces, such as the Knative activator and
+// ingress, can still reach them.
+func NewTenantPolicyObj(space string, allowedNamespaces []string) *networkingv1.NetworkPolicy {
+	from := []networkingv1.NetworkPolicyPeer{{
+		PodSelector: &metav1.LabelSelector{},
+	}}
+	if len(allowedNamespaces) > 0 {
+		from = append(from, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      namespaceNameLabel,
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   allowedNamespaces,
+				}},
+			},
+		})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.TenantPolicyName,
+			Namespace: space,
+			Labels:    map[string]string{util.ManagedByLabel: util.ManagedByValue},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: from}},
+		},
+	}
+}
+
+// CheckTenantPolicy returns if the tenant NetworkPolicy of a namespace exists
+// and allows the given namespaces.
+func CheckTenantPolicy(ctx context.Context, clientset kubernetes.Interface, space string, allowedNamespaces []string) (bool, error) {
+	current, err := clientset.NetworkingV1().NetworkPolicies(space).Get(ctx, util.TenantPolicyName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return equality.Semantic.DeepEqual(current.Spec, NewTenantPolicyObj(space, allowedNamespaces).Spec), nil
+}
+
+// EnsureTenantPolicy creates the tenant NetworkPolicy of a namespace, or
+// updates it if the allowed namespaces changed, and returns if it did either.
+func EnsureTenantPolicy(ctx context.Context, clientset kubernetes.Interface, space string, allowedNamespaces []string) (bool, error) {
+	policy := NewTenantPolicyObj(space, allowedNamespaces)
+	policies := clientset.NetworkingV1().NetworkPolicies(space)
+
+	current, err := policies.Get(ctx, policy.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = policies.Create(ctx, policy, metav1.CreateOptions{})
+		if err != nil {
+			zap.S().Errorf("Failed to create network policy of namespace %v. Error: %v", space, err)
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if equality.Semantic.DeepEqual(current.Spec, policy.Spec) {
+		return false, nil
+	}
+	current.Spec = policy.Spec
+	_, err = policies.Update(ctx, current, metav1.UpdateOptions{})
+	if err != nil {
+		zap.S().Errorf("Failed to update network policy of namespace %v. Error: %v", space, err)
+		return false, err
+	}
+	return true, nil
+}
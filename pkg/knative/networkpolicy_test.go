@@ -0,0 +1,77 @@
+package knative
+
+import (
+	"context"
+	"testing"
+
+	"github.com/platform9/app-controller/pkg/util"
+	"gotest.tools/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewTenantPolicyObj(t *testing.T) {
+	policy := NewTenantPolicyObj("tenant", []string{"knative-serving", "kourier-system"})
+
+	assert.Equal(t, policy.Name, util.TenantPolicyName)
+	assert.Equal(t, policy.Namespace, "tenant")
+	assert.Equal(t, policy.Labels[util.ManagedByLabel], util.ManagedByValue)
+	// Selects every pod of the namespace, for ingress only.
+	assert.DeepEqual(t, policy.Spec.PodSelector, metav1.LabelSelector{})
+	assert.DeepEqual(t, policy.Spec.PolicyTypes, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress})
+
+	assert.Equal(t, len(policy.Spec.Ingress), 1)
+	from := policy.Spec.Ingress[0].From
+	assert.Equal(t, len(from), 2)
+	// Pods of the same namespace.
+	assert.DeepEqual(t, from[0], networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}})
+	// Pods of the Knative namespaces.
+	assert.Assert(t, from[1].PodSelector == nil)
+	assert.DeepEqual(t, from[1].NamespaceSelector.MatchExpressions, []metav1.LabelSelectorRequirement{{
+		Key:      "kubernetes.io/metadata.name",
+		Operator: metav1.LabelSelectorOpIn,
+		Values:   []string{"knative-serving", "kourier-system"},
+	}})
+
+	// Without allowed namespaces, only the same namespace can reach the pods.
+	policy = NewTenantPolicyObj("tenant", nil)
+	assert.Equal(t, len(policy.Spec.Ingress[0].From), 1)
+}
+
+func TestEnsureTenantPolicy(t *testing.T) {
+	ctx := context.Background()
+	clientset := k8sfake.NewSimpleClientset()
+	allowed := []string{"knative-serving"}
+
+	ok, err := CheckTenantPolicy(ctx, clientset, "tenant", allowed)
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+
+	changed, err := EnsureTenantPolicy(ctx, clientset, "tenant", allowed)
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+
+	ok, err = CheckTenantPolicy(ctx, clientset, "tenant", allowed)
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+
+	// Applying it again is a no-op.
+	changed, err = EnsureTenantPolicy(ctx, clientset, "tenant", allowed)
+	assert.NilError(t, err)
+	assert.Assert(t, !changed)
+
+	// A change of the allowed namespaces updates the policy.
+	allowed = []string{"knative-serving", "istio-system"}
+	ok, err = CheckTenantPolicy(ctx, clientset, "tenant", allowed)
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+
+	changed, err = EnsureTenantPolicy(ctx, clientset, "tenant", allowed)
+	assert.NilError(t, err)
+	assert.Assert(t, changed)
+
+	policy, err := clientset.NetworkingV1().NetworkPolicies("tenant").Get(ctx, util.TenantPolicyName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, policy.Spec.Ingress[0].From[1].NamespaceSelector.MatchExpressions[0].Values, allowed)
+}
@@ -0,0 +1,113 @@
+package knative
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/platform9/app-controller/pkg/objects"
+	"github.com/platform9/app-controller/pkg/util"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	servingclientset "knative.dev/serving/pkg/client/clientset/versioned"
+)
+
+// Quota usage of a user namespace.
+type Quota struct {
+	Plan objects.Plan `json:"plan"`
+	Apps QuotaUsage   `json:"apps"`
+}
+
+// Used and allowed count of a quota resource.
+type QuotaUsage struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+}
+
+// Constructor for the ResourceQuota object limiting the knative services in a namespace.
+func newAppQuotaObj(namespace string, maxApps int) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      util.AppQuotaName,
+			Namespace: namespace,
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{
+				util.AppQuotaResource: *resource.NewQuantity(int64(maxApps), resource.DecimalSI),
+			},
+		},
+	}
+}
+
+// Create or update the app count ResourceQuota of a namespace, so that the
+// limit is enforced by the API server when the service is created.
+func ensureAppQuota(ctx context.Context, clientset kubernetes.Interface, space string, maxApps int) error {
+	quota := newAppQuotaObj(space, maxApps)
+	existing, err := clientset.CoreV1().ResourceQuotas(space).Get(ctx, util.AppQuotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = clientset.CoreV1().ResourceQuotas(space).Create(ctx, quota, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	current := existing.Spec.Hard[util.AppQuotaResource]
+	if current.Cmp(quota.Spec.Hard[util.AppQuotaResource]) == 0 {
+		return nil
+	}
+	existing.Spec.Hard = quota.Spec.Hard
+	_, err = clientset.CoreV1().ResourceQuotas(space).Update(ctx, existing, metav1.UpdateOptions{})
+	return err
+}
+
+// Check if the error is a rejection due to the app count quota.
+func isAppQuotaExceeded(err error) bool {
+	return apierrors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
+
+// EnsureAppQuota sets the maximum apps allowed in the namespace.
+func (c *Clients) EnsureAppQuota(ctx context.Context, space string, maxApps int) error {
+	err := ensureAppQuota(ctx, c.Kube, space, maxApps)
+	if err != nil {
+		zap.S().Errorf("Error while setting app quota for namespace %v: %v", space, err)
+		return fmt.Errorf("Failed to set app quota. Error: %v", err)
+	}
+	return nil
+}
+
+// GetQuota returns the apps deployed in the namespace against the plan limits.
+func (c *Clients) GetQuota(ctx context.Context, space string, plan objects.Plan) (*Quota, error) {
+	appsList, err := c.ServingClient(space).ListServices(ctx)
+	if err != nil {
+		zap.S().Errorf("Error while listing apps: %v", err)
+		return nil, err
+	}
+
+	return &Quota{
+		Plan: plan,
+		Apps: QuotaUsage{Used: len(appsList.Items), Limit: plan.MaxApps},
+	}, nil
+}
+
+// CountApps returns the number of apps of every namespace, listed at once
+// across the cluster.
+func (c *Clients) CountApps(ctx context.Context) (map[string]int, error) {
+	return countApps(ctx, c.Serving)
+}
+
+func countApps(ctx context.Context, client servingclientset.Interface) (map[string]int, error) {
+	services, err := client.ServingV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, service := range services.Items {
+		counts[service.Namespace]++
+	}
+	return counts, nil
+}
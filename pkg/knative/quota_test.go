@@ -0,0 +1,59 @@
+package knative
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/platform9/app-controller/pkg/util"
+	"gotest.tools/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+)
+
+func TestEnsureAppQuota(t *testing.T) {
+	clientset := k8sfake.NewSimpleClientset()
+	ctx := context.Background()
+
+	getHard := func() int64 {
+		quota, err := clientset.CoreV1().ResourceQuotas(testNamespace).Get(ctx, util.AppQuotaName, metav1.GetOptions{})
+		assert.NilError(t, err)
+		hard := quota.Spec.Hard[util.AppQuotaResource]
+		return hard.Value()
+	}
+
+	t.Run("create the quota when missing", func(t *testing.T) {
+		assert.NilError(t, ensureAppQuota(ctx, clientset, testNamespace, 7))
+		assert.Equal(t, getHard(), int64(7))
+	})
+
+	t.Run("update the quota when the limit changes", func(t *testing.T) {
+		assert.NilError(t, ensureAppQuota(ctx, clientset, testNamespace, 3))
+		assert.Equal(t, getHard(), int64(3))
+	})
+}
+
+func TestIsAppQuotaExceeded(t *testing.T) {
+	exceeded := apierrors.NewForbidden(servingv1.Resource("services"), "app",
+		errors.New("exceeded quota: app-controller-quota, requested: count/services.serving.knative.dev=1"))
+	assert.Assert(t, isAppQuotaExceeded(exceeded))
+
+	forbidden := apierrors.NewForbidden(servingv1.Resource("services"), "app", errors.New("not allowed"))
+	assert.Assert(t, !isAppQuotaExceeded(forbidden))
+	assert.Assert(t, !isAppQuotaExceeded(apierrors.NewNotFound(servingv1.Resource("services"), "app")))
+}
+
+func TestCountApps(t *testing.T) {
+	client := servingfake.NewSimpleClientset(
+		&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "one"}},
+		&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "one"}},
+		&servingv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "two"}},
+	)
+
+	counts, err := countApps(context.Background(), client)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, counts, map[string]int{"one": 2, "two": 1})
+}
@@ -0,0 +1,99 @@
+package knative
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientservingv1 "knative.dev/client/pkg/serving/v1"
+	network "knative.dev/networking/pkg"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// Visibility of an app.
+const (
+	// Reachable through the ingress of the cluster.
+	VisibilityPublic = "public"
+	// Only reachable from inside the cluster.
+	VisibilityClusterLocal = serving.VisibilityClusterLocal
+)
+
+// IsVisibility checks if the value is a valid visibility, empty being public.
+func IsVisibility(visibility string) bool {
+	return visibility == "" || visibility == VisibilityPublic || visibility == VisibilityClusterLocal
+}
+
+// Label value of the visibility, empty for public apps.
+func visibilityLabel(visibility string) string {
+	if visibility == VisibilityClusterLocal {
+		return VisibilityClusterLocal
+	}
+	return ""
+}
+
+// Visibility of the knative service.
+func Visibility(service *servingv1.Service) string {
+	if service.Labels[network.VisibilityLabelKey] == VisibilityClusterLocal {
+		return VisibilityClusterLocal
+	}
+	return VisibilityPublic
+}
+
+// InternalURL of the knative service, to call it from inside the cluster.
+func InternalURL(service *servingv1.Service) string {
+	if service.Status.Address != nil && service.Status.Address.URL != nil {
+		return service.Status.Address.URL.String()
+	}
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local", service.Name, service.Namespace)
+}
+
+// AppView is an app as listed, the knative service along with its visibility.
+type AppView struct {
+	servingv1.Service
+	Visibility  string `json:"visibility"`
+	InternalURL string `json:"internalURL"`
+}
+
+// AppListView is the list of the apps of a namespace.
+type AppListView struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppView `json:"items"`
+}
+
+func newAppView(service servingv1.Service) AppView {
+	return AppView{
+		Service:     service,
+		Visibility:  Visibility(&service),
+		InternalURL: InternalURL(&service),
+	}
+}
+
+func newAppListView(list *servingv1.ServiceList) AppListView {
+	view := AppListView{
+		TypeMeta: list.TypeMeta,
+		ListMeta: list.ListMeta,
+		Items:    []AppView{},
+	}
+	for _, service := range list.Items {
+		view.Items = append(view.Items, newAppView(service))
+	}
+	return view
+}
+
+func setVisibility(ctx context.Context, client clientservingv1.KnServingClient, appName string, visibility string) error {
+	return UpdateAppMeta(ctx, client, appName, nil, map[string]string{
+		network.VisibilityLabelKey: visibilityLabel(visibility),
+	})
+}
+
+// SetVisibility makes an existing app public or cluster-local.
+func (c *Clients) SetVisibility(ctx context.Context, space string, appName string, visibility string) error {
+	err := setVisibility(ctx, c.ServingClient(space), appName, visibility)
+	if err != nil {
+		zap.S().Errorf("Error while updating visibility of app %v: %v", appName, err)
+	}
+	return err
+}
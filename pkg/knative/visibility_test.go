@@ -0,0 +1,82 @@
+package knative
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform9/app-controller/pkg/objects"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	network "knative.dev/networking/pkg"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestConstructServiceVisibility(t *testing.T) {
+	service, err := constructService("private", testNamespace, "nginx", nil, "", "", objects.Plan{}, 0, VisibilityClusterLocal)
+	assert.NilError(t, err)
+	assert.Equal(t, service.Labels[network.VisibilityLabelKey], VisibilityClusterLocal)
+	assert.Equal(t, Visibility(&service), VisibilityClusterLocal)
+
+	service, err = constructService("public", testNamespace, "nginx", nil, "", "", objects.Plan{}, 0, VisibilityPublic)
+	assert.NilError(t, err)
+	assert.Equal(t, len(service.Labels), 0)
+	assert.Equal(t, Visibility(&service), VisibilityPublic)
+}
+
+func TestListAppsVisibility(t *testing.T) {
+	serving, client := setup()
+
+	private := newService("private")
+	private.Labels = map[string]string{network.VisibilityLabelKey: VisibilityClusterLocal}
+	private.Status.Address = &duckv1.Addressable{URL: apis.HTTP("private.test.svc.cluster.local")}
+	public := newService("public")
+	serving.AddReactor("list", "services",
+		func(a clienttesting.Action) (bool, runtime.Object, error) {
+			return true, &servingv1.ServiceList{Items: []servingv1.Service{*private, *public}}, nil
+		})
+
+	allApps, err := listAllApps(client, context.Background())
+	assert.NilError(t, err)
+
+	var appInfo struct {
+		Items []struct {
+			Visibility  string `json:"visibility"`
+			InternalURL string `json:"internalURL"`
+			servingv1.Service
+		} `json:"items"`
+	}
+	assert.NilError(t, json.Unmarshal([]byte(allApps), &appInfo))
+	assert.Equal(t, len(appInfo.Items), 2)
+	assert.Equal(t, appInfo.Items[0].Name, "private")
+	assert.Equal(t, appInfo.Items[0].Visibility, VisibilityClusterLocal)
+	assert.Equal(t, appInfo.Items[0].InternalURL, "http://private.test.svc.cluster.local")
+	assert.Equal(t, appInfo.Items[1].Visibility, VisibilityPublic)
+	assert.Equal(t, appInfo.Items[1].InternalURL, "http://public.test.svc.cluster.local")
+}
+
+func TestSetVisibility(t *testing.T) {
+	serving, client := setup()
+
+	stored := newService("app")
+	serving.AddReactor("get", "services",
+		func(a clienttesting.Action) (bool, runtime.Object, error) {
+			return true, stored.DeepCopy(), nil
+		})
+	serving.AddReactor("update", "services",
+		func(a clienttesting.Action) (bool, runtime.Object, error) {
+			stored = a.(clienttesting.UpdateAction).GetObject().(*servingv1.Service)
+			return true, stored, nil
+		})
+
+	assert.NilError(t, setVisibility(context.Background(), client, "app", VisibilityClusterLocal))
+	assert.Equal(t, Visibility(stored), VisibilityClusterLocal)
+
+	assert.NilError(t, setVisibility(context.Background(), client, "app", VisibilityPublic))
+	assert.Equal(t, Visibility(stored), VisibilityPublic)
+	_, found := stored.Labels[network.VisibilityLabelKey]
+	assert.Assert(t, !found)
+}
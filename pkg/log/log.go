@@ -3,59 +3,163 @@ package log
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
-	"github.com/platform9/app-controller/pkg/util"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/platform9/app-controller/pkg/options"
+	"github.com/platform9/app-controller/pkg/util"
 )
 
-func createDirectoryIfNotExists() error {
-	var err error
-	// Create Pf9Dir
-	if _, err = os.Stat(util.Pf9Dir); os.IsNotExist(err) {
-		errdir := os.Mkdir(util.Pf9Dir, os.ModePerm)
-		if errdir != nil {
-			return errdir
-		}
+// Log outputs.
+const (
+	OutputFile   = "file"
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// Log formats.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Level of the global logger, changed at runtime through the admin API.
+var Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+// Config of the global logger.
+type Config struct {
+	// One of file, stdout or stderr.
+	Output string
+	// File written with the file output.
+	File string
+	// Minimum level logged, e.g. debug, info, warn or error.
+	Level string
+	// Either console or json.
+	Format string
+	// Size in megabytes after which the file is rotated.
+	MaxSize int
+	// Rotated files kept, and their maximum age in days, 0 to keep them all.
+	MaxBackups int
+	MaxAge     int
+	// Compress the rotated files.
+	Compress bool
+	// Mask emails, tokens and passwords, see Redact.
+	Redact bool
+}
+
+// ConfigFromOptions returns the logger config set through options.
+func ConfigFromOptions() Config {
+	file := options.GetLogFile()
+	if file == "" {
+		file = util.AppControllerLog
 	}
-	// Create AppControllerLogDir.
-	if _, err = os.Stat(util.AppControllerLogDir); os.IsNotExist(err) {
-		errlogdir := os.Mkdir(util.AppControllerLogDir, os.ModePerm)
-		if errlogdir != nil {
-			return errlogdir
-		}
-		return nil
+	return Config{
+		Output:     options.GetLogOutput(),
+		File:       file,
+		Level:      options.GetLogLevel(),
+		Format:     options.GetLogFormat(),
+		MaxSize:    options.GetLogMaxSize(),
+		MaxBackups: options.GetLogMaxBackups(),
+		MaxAge:     options.GetLogMaxAge(),
+		Compress:   options.GetLogCompress(),
+		Redact:     options.GetLogRedact(),
 	}
-	return err
 }
 
-func fileConfig() zapcore.Encoder {
+func encoderConfig() zapcore.EncoderConfig {
 	config := zap.NewProductionEncoderConfig()
 	config.EncodeTime = zapcore.TimeEncoder(func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 		enc.AppendString(t.UTC().Format("2006-01-02T15:04:05.9999Z"))
 	})
 	config.EncodeLevel = zapcore.CapitalLevelEncoder
-	return zapcore.NewConsoleEncoder(config)
+	return config
 }
 
-func Logger() error {
-	//Create the Pf9Dir, AppControllerLogDir directory to store logs.
-	err := createDirectoryIfNotExists()
-	if err != nil {
-		return fmt.Errorf("Failed to create Director. \nError is: %s", err)
+func newEncoder(format string) (zapcore.Encoder, error) {
+	switch format {
+	case "", FormatConsole:
+		return zapcore.NewConsoleEncoder(encoderConfig()), nil
+	case FormatJSON:
+		return zapcore.NewJSONEncoder(encoderConfig()), nil
 	}
+	return nil, fmt.Errorf("Invalid log format %v, expected %v or %v", format, FormatConsole, FormatJSON)
+}
 
-	// Open/Create the app-controller.log file.
-	file, err := os.OpenFile(util.AppControllerLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+func newWriter(config Config) (zapcore.WriteSyncer, error) {
+	switch config.Output {
+	case OutputStdout:
+		return zapcore.Lock(os.Stdout), nil
+	case OutputStderr:
+		return zapcore.Lock(os.Stderr), nil
+	case "", OutputFile:
+		if config.File == "" {
+			return nil, fmt.Errorf("No log file set for the file output")
+		}
+		// Create the directory of the log file.
+		if err := os.MkdirAll(filepath.Dir(config.File), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("Failed to create the log directory. Error: %v", err)
+		}
+		// Rotated by size, lumberjack opens the file on the first write.
+		return zapcore.AddSync(&lumberjack.Logger{
+			Filename:   config.File,
+			MaxSize:    config.MaxSize,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAge,
+			Compress:   config.Compress,
+		}), nil
+	}
+	return nil, fmt.Errorf("Invalid log output %v, expected one of %v, %v, %v",
+		config.Output, OutputFile, OutputStdout, OutputStderr)
+}
+
+// New returns a logger of the config, logging at Level.
+func New(config Config) (*zap.Logger, error) {
+	encoder, err := newEncoder(config.Format)
 	if err != nil {
-		return fmt.Errorf("Couldn't open the log file: %s. \nError is: %s", util.AppControllerLog, err)
+		return nil, err
+	}
+	writer, err := newWriter(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Level != "" {
+		if err = SetLevel(config.Level); err != nil {
+			return nil, err
+		}
 	}
 
-	core := zapcore.NewCore(fileConfig(), zapcore.AddSync(file), zapcore.DebugLevel)
+	core := zapcore.NewCore(encoder, writer, Level)
+	if config.Redact {
+		core = NewRedactCore(core)
+	}
+	return zap.New(core, zap.AddCaller()), nil
+}
 
-	logger := zap.New(core, zap.AddCaller())
-	defer logger.Sync()
+// Setup replaces the global logger with a logger of the config.
+func Setup(config Config) error {
+	logger, err := New(config)
+	if err != nil {
+		return err
+	}
 	zap.ReplaceGlobals(logger)
 	return nil
 }
+
+// Logger replaces the global logger with the one configured through options.
+func Logger() error {
+	return Setup(ConfigFromOptions())
+}
+
+// SetLevel changes the level of the global logger, e.g. to debug.
+func SetLevel(level string) error {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("Invalid log level %v", level)
+	}
+	Level.SetLevel(l)
+	return nil
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	"gotest.tools/assert"
+)
+
+func TestNew(t *testing.T) {
+	defer Level.SetLevel(Level.Level())
+
+	file := filepath.Join(t.TempDir(), "logs", "app-controller.log")
+	logger, err := New(Config{
+		Output:  OutputFile,
+		File:    file,
+		Level:   "warn",
+		Format:  FormatJSON,
+		MaxSize: 1,
+		Redact:  true,
+	})
+	assert.NilError(t, err)
+
+	logger.Info("not logged")
+	logger.Sugar().Warnf("Login of %v failed", "jdoe@example.com")
+	assert.NilError(t, logger.Sync())
+
+	data, err := ioutil.ReadFile(file)
+	assert.NilError(t, err)
+	line := map[string]interface{}{}
+	assert.NilError(t, json.Unmarshal(data, &line))
+	assert.Equal(t, line["level"], "WARN")
+	assert.Equal(t, line["msg"], "Login of "+redactEmail("jdoe@example.com")+" failed")
+
+	// The level changes at runtime.
+	assert.NilError(t, SetLevel("debug"))
+	assert.Assert(t, logger.Core().Enabled(zapcore.DebugLevel))
+	assert.ErrorContains(t, SetLevel("verbose"), "Invalid log level")
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, config := range []Config{
+		{Output: "syslog"},
+		{Output: OutputFile},
+		{Output: OutputStdout, Format: "logfmt"},
+		{Output: OutputStdout, Level: "verbose"},
+	} {
+		_, err := New(config)
+		assert.Assert(t, err != nil, config)
+	}
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Value replacing the redacted secrets.
+const redacted = "<redacted>"
+
+var (
+	emailRegex = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// JWTs, e.g. the ID tokens of Auth0.
+	jwtRegex    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerRegex = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+	// Secrets written as key=value, key: value or "key":"value".
+	secretRegex = regexp.MustCompile(`(?i)((?:password|passwd|secret|token)"?\s*[:=]\s*"?)[^\s",}]+`)
+)
+
+// Keys of the fields whose value is always redacted.
+var secretKeys = []string{"password", "passwd", "secret", "token", "authorization"}
+
+// Replace an email with a short hash of it, so that the lines of a user can
+// still be correlated.
+func redactEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "<email:" + hex.EncodeToString(sum[:4]) + ">"
+}
+
+// Redact masks the emails, JWTs, bearer tokens and passwords in the value.
+func Redact(value string) string {
+	value = jwtRegex.ReplaceAllString(value, redacted)
+	value = bearerRegex.ReplaceAllString(value, "${1}"+redacted)
+	value = secretRegex.ReplaceAllString(value, "${1}"+redacted)
+	return emailRegex.ReplaceAllStringFunc(value, redactEmail)
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact a field, its value is rendered as a string if it may hold a secret.
+func redactField(field zapcore.Field) zapcore.Field {
+	if isSecretKey(field.Key) {
+		return zap.String(field.Key, redacted)
+	}
+	switch field.Type {
+	case zapcore.StringType:
+		return zap.String(field.Key, Redact(field.String))
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && err != nil {
+			return zap.String(field.Key, Redact(err.Error()))
+		}
+	case zapcore.StringerType:
+		if s, ok := field.Interface.(fmt.Stringer); ok && s != nil {
+			return zap.String(field.Key, Redact(s.String()))
+		}
+	case zapcore.ReflectType:
+		return zap.String(field.Key, Redact(fmt.Sprintf("%+v", field.Interface)))
+	}
+	return field
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redactedFields := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redactedFields[i] = redactField(field)
+	}
+	return redactedFields
+}
+
+// Core redacting the messages and fields before writing them.
+type redactCore struct {
+	zapcore.Core
+}
+
+// NewRedactCore wraps the core to mask the emails, tokens and passwords of
+// the messages and fields, see Redact.
+func NewRedactCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{Core: core}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = Redact(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
@@ -0,0 +1,161 @@
+// Package metrics defines the Prometheus metrics of app-controller.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+const namespace = "app_controller"
+
+var (
+	// Registry of the metrics of app-controller, along with the Go and process ones.
+	Registry = prometheus.NewRegistry()
+
+	// HTTPRequests counts the API requests per route, method and status code.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "API requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// HTTPDuration observes the API request latencies per route, method and status code.
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "API request latencies by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	// AuthFailures counts the rejected requests per reason.
+	AuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Requests rejected by authentication or authorization, by reason.",
+	}, []string{"reason"})
+
+	// KubeRequestDuration observes the Kubernetes and Knative API call latencies.
+	KubeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kube_request_duration_seconds",
+		Help:      "Kubernetes and Knative API call latencies by verb and resource.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"verb", "resource"})
+
+	// KubeRequestErrors counts the failed Kubernetes and Knative API calls.
+	KubeRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kube_request_errors_total",
+		Help:      "Failed Kubernetes and Knative API calls by method and status code.",
+	}, []string{"method", "code"})
+
+	// DBQueryDuration observes the DB query latencies per Querier method.
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latencies by query.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"query"})
+
+	// Users is the number of users in DB.
+	Users = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "users",
+		Help:      "Users in the database.",
+	})
+
+	// Apps is the number of apps per user namespace.
+	Apps = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "apps",
+		Help:      "Apps deployed by namespace.",
+	}, []string{"namespace"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, AuthFailures,
+		KubeRequestDuration, KubeRequestErrors,
+		DBQueryDuration, Users, Apps,
+	)
+
+	// Observe the calls of every client-go based client, kubernetes and knative.
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestLatency: kubeLatency{},
+		RequestResult:  kubeResult{},
+	})
+}
+
+// Handler serves the metrics of the registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// AuthFailure counts a request rejected for the reason.
+func AuthFailure(reason string) {
+	AuthFailures.WithLabelValues(reason).Inc()
+}
+
+// ObserveQuery returns a func observing the latency of a DB query since the
+// call, to be deferred.
+func ObserveQuery(query string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	}
+}
+
+type kubeLatency struct{}
+
+func (kubeLatency) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	KubeRequestDuration.WithLabelValues(verb, Resource(u.Path)).Observe(latency.Seconds())
+}
+
+type kubeResult struct{}
+
+func (kubeResult) Increment(ctx context.Context, code string, method string, host string) {
+	if strings.HasPrefix(code, "2") || strings.HasPrefix(code, "3") {
+		return
+	}
+	KubeRequestErrors.WithLabelValues(method, code).Inc()
+}
+
+// Resource returns the resource of a Kubernetes API path, to keep the names
+// of namespaces and objects out of the labels, e.g. "services" for
+// /apis/serving.knative.dev/v1/namespaces/ns/services/app.
+func Resource(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	// Skip the /api/<version> or /apis/<group>/<version> prefix.
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return "other"
+	}
+	if len(parts) >= 2 && parts[0] == "namespaces" {
+		if len(parts) == 2 {
+			return "namespaces"
+		}
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return "other"
+	}
+	// Keep the subresource, e.g. services/status.
+	if len(parts) >= 3 {
+		return parts[0] + "/" + parts[2]
+	}
+	return parts[0]
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestResource(t *testing.T) {
+	for path, resource := range map[string]string{
+		"/api/v1/namespaces":                                          "namespaces",
+		"/api/v1/namespaces/tenant":                                   "namespaces",
+		"/api/v1/namespaces/tenant/secrets/app":                       "secrets",
+		"/api/v1/namespaces/tenant/resourcequotas":                    "resourcequotas",
+		"/apis/serving.knative.dev/v1/namespaces/tenant/services":     "services",
+		"/apis/serving.knative.dev/v1/services":                       "services",
+		"/apis/serving.knative.dev/v1/namespaces/t/services/a/status": "services/status",
+		"/apis/coordination.k8s.io/v1/namespaces/default/leases/app":  "leases",
+		"/version": "other",
+		"":         "other",
+	} {
+		assert.Equal(t, Resource(path), resource, path)
+	}
+}
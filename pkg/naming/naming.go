@@ -0,0 +1,104 @@
+// Package naming generates the namespace names of users.
+package naming
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// Characters of the suffix.
+	suffixCharSet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	// Length of the suffix.
+	SuffixLen = 6
+	// Base of the names of users without a usable nickname or email.
+	defaultBase = "user"
+	// Attempts to create a namespace when none is configured.
+	defaultMaxAttempts = 5
+)
+
+// Namer derives DNS-1123 label names from the identity of a user, with an
+// optional prefix and a suffix derived from the owner and the attempt, so
+// that a retried login tries the same names in the same order.
+type Namer struct {
+	Prefix      string
+	MaxAttempts int
+}
+
+// New returns a namer using the sanitized prefix.
+func New(prefix string, maxAttempts int) *Namer {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	prefix = Sanitize(prefix)
+	// Leave room for at least one character of the base, and the suffix.
+	if max := validation.DNS1123LabelMaxLength - SuffixLen - 2; len(prefix) > max {
+		prefix = strings.TrimRight(prefix[:max], "-")
+	}
+	if prefix != "" {
+		prefix += "-"
+	}
+	return &Namer{Prefix: prefix, MaxAttempts: maxAttempts}
+}
+
+// Sanitize lowercases the value and replaces every run of characters other
+// than [a-z0-9] with a single '-', without leading or trailing '-'.
+func Sanitize(value string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// Suffix derived from the owner and the attempt.
+func suffix(owner string, attempt int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", owner, attempt)))
+	code := make([]byte, SuffixLen)
+	for i := range code {
+		code[i] = suffixCharSet[int(sum[i])%len(suffixCharSet)]
+	}
+	return string(code)
+}
+
+// Name returns the name of the attempt for the base, e.g. the nickname or
+// the email prefix of the user, and the owner.
+func (n *Namer) Name(base string, owner string, attempt int) string {
+	base = Sanitize(base)
+	if base == "" {
+		base = defaultBase
+	}
+	if max := validation.DNS1123LabelMaxLength - len(n.Prefix) - SuffixLen - 1; len(base) > max {
+		base = strings.TrimRight(base[:max], "-")
+	}
+	return n.Prefix + base + "-" + suffix(owner, attempt)
+}
+
+// Create calls create with the names of successive attempts until one
+// doesn't already exist, and returns the name created.
+func (n *Namer) Create(base string, owner string, create func(name string) error) (string, error) {
+	for attempt := 0; attempt < n.MaxAttempts; attempt++ {
+		name := n.Name(base, owner, attempt)
+		err := create(name)
+		if err == nil {
+			return name, nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("Failed to find an available namespace name for %v after %d attempts", base, n.MaxAttempts)
+}
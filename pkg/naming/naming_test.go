@@ -0,0 +1,134 @@
+package naming
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/platform9/app-controller/pkg/util"
+	"gotest.tools/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func assertValid(t *testing.T, name string) {
+	t.Helper()
+	if !validName(name) {
+		t.Fatalf("invalid namespace name %q", name)
+	}
+}
+
+func TestName(t *testing.T) {
+	namer := New("", 0)
+
+	name := namer.Name("John.Doe", "john.doe@example.com", 0)
+	assert.Assert(t, strings.HasPrefix(name, "john-doe-"), name)
+	assert.Equal(t, len(name), len("john-doe-")+SuffixLen)
+	assertValid(t, name)
+
+	// The same owner and attempt give the same name, other attempts another one.
+	assert.Equal(t, namer.Name("John.Doe", "john.doe@example.com", 0), name)
+	assert.Assert(t, namer.Name("John.Doe", "john.doe@example.com", 1) != name)
+	assert.Assert(t, namer.Name("John.Doe", "jdoe@example.com", 0) != name)
+
+	// Long and empty bases.
+	assertValid(t, namer.Name(strings.Repeat("a", 100), "owner", 0))
+	assert.Assert(t, strings.HasPrefix(namer.Name("___", "owner", 0), "user-"))
+
+	prefixed := New("Apps_", 3).Name("jdoe", "owner", 0)
+	assert.Assert(t, strings.HasPrefix(prefixed, "apps-jdoe-"), prefixed)
+	assertValid(t, prefixed)
+}
+
+func TestCreate(t *testing.T) {
+	namer := New("", 3)
+	exists := apierrors.NewAlreadyExists(schema.GroupResource{Resource: "namespaces"}, "taken")
+
+	t.Run("retry names that already exist", func(t *testing.T) {
+		tried := []string{}
+		name, err := namer.Create("jdoe", "owner", func(name string) error {
+			tried = append(tried, name)
+			if len(tried) < 3 {
+				return exists
+			}
+			return nil
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, len(tried), 3)
+		assert.Equal(t, name, namer.Name("jdoe", "owner", 2))
+	})
+
+	t.Run("give up after the attempts", func(t *testing.T) {
+		calls := 0
+		_, err := namer.Create("jdoe", "owner", func(name string) error {
+			calls++
+			return exists
+		})
+		assert.ErrorContains(t, err, "after 3 attempts")
+		assert.Equal(t, calls, 3)
+	})
+
+	t.Run("return other errors", func(t *testing.T) {
+		calls := 0
+		_, err := namer.Create("jdoe", "owner", func(name string) error {
+			calls++
+			return errors.New("forbidden")
+		})
+		assert.ErrorContains(t, err, "forbidden")
+		assert.Equal(t, calls, 1)
+	})
+}
+
+// Valid namespace name, a DNS-1123 label.
+func validName(name string) bool {
+	return util.RegexValidate(name) && len(name) <= validation.DNS1123LabelMaxLength
+}
+
+func TestNameValid(t *testing.T) {
+	// Every name is valid, whatever the prefix, base, owner and attempt.
+	property := func(prefix string, base string, owner string, attempt int) bool {
+		return validName(New(prefix, 0).Name(base, owner, attempt))
+	}
+	assert.NilError(t, quick.Check(property, &quick.Config{MaxCount: 10000}))
+
+	// Along with the edge cases random inputs are unlikely to hit.
+	tests := []struct {
+		prefix  string
+		base    string
+		owner   string
+		attempt int
+	}{
+		{"-_-", "---", "", 4},
+		{strings.Repeat("p", 80), strings.Repeat("b", 80), "owner", 2},
+		{"apps-", "-jdoe-", "owner", -1},
+		{"", "a.b_c d", "owner", 100},
+	}
+	for _, tt := range tests {
+		assert.Assert(t, property(tt.prefix, tt.base, tt.owner, tt.attempt), "%+v", tt)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"John.Doe", "john-doe"},
+		{"--a--b--", "a-b"},
+		{"ß", ""},
+		{"", ""},
+		{"jöhn_dœ", "j-hn-d"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, Sanitize(tt.value), tt.want)
+	}
+
+	// Sanitized values are empty or valid.
+	property := func(value string) bool {
+		sanitized := Sanitize(value)
+		return sanitized == "" || util.RegexValidate(sanitized)
+	}
+	assert.NilError(t, quick.Check(property, &quick.Config{MaxCount: 10000}))
+}
@@ -0,0 +1,34 @@
+package objects
+
+import "time"
+
+// Outcomes of an audited action.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records a mutating action and who performed it.
+type AuditEvent struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Space     string    `json:"space"`
+	Target    string    `json:"target"`
+	Outcome   string    `json:"outcome"`
+	Detail    string    `json:"detail"`
+	// ID of the API request performing the action, if any.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// AuditFilter selects audit events, empty fields match any value.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Space  string
+	// Events created at or after Since.
+	Since time.Time
+	// Maximum number of events, the latest first.
+	Limit int
+}
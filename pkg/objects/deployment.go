@@ -0,0 +1,27 @@
+package objects
+
+import "time"
+
+// Outcomes of a deployment.
+const (
+	DeploymentPending   = "pending"
+	DeploymentSucceeded = "success"
+	DeploymentFailed    = "failure"
+)
+
+// Deployment records a create or update of an app, with the spec submitted.
+type Deployment struct {
+	ID         int       `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	UserID     int       `json:"-"`
+	Space      string    `json:"-"`
+	App        string    `json:"app"`
+	Actor      string    `json:"actor"`
+	// JSON of the app spec, without the registry password.
+	Spec string `json:"-"`
+	// Name of the knative revision created, empty if unknown.
+	Revision string `json:"revision"`
+	Outcome  string `json:"outcome"`
+	Error    string `json:"error,omitempty"`
+}
@@ -0,0 +1,21 @@
+package objects
+
+import "time"
+
+// Domain is a custom hostname attached to an app. It is mapped to the app
+// once the owner has proven control of it with the token.
+type Domain struct {
+	ID         int       `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UserID     int       `json:"-"`
+	Space      string    `json:"-"`
+	App        string    `json:"app"`
+	Domain     string    `json:"domain"`
+	Token      string    `json:"token"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// Verified checks if the ownership of the domain was verified.
+func (d *Domain) Verified() bool {
+	return !d.VerifiedAt.IsZero()
+}
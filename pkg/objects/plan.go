@@ -0,0 +1,47 @@
+package objects
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Plan holds the limits applied to the users subscribed to it.
+type Plan struct {
+	Name       string        `json:"name"`
+	MaxApps    int           `json:"maxApps" mapstructure:"max-app"`
+	MaxScale   int           `json:"maxScale" mapstructure:"max-scale"`
+	CPU        string        `json:"cpu" mapstructure:"cpu"`
+	Memory     string        `json:"memory" mapstructure:"memory"`
+	MaxDomains int           `json:"maxDomains" mapstructure:"max-domains"`
+	Retention  time.Duration `json:"retention" mapstructure:"retention"`
+}
+
+// MarshalJSON formats the retention as a duration string.
+func (p Plan) MarshalJSON() ([]byte, error) {
+	type plan Plan
+	return json.Marshal(struct {
+		plan
+		Retention string `json:"retention"`
+	}{plan(p), p.Retention.String()})
+}
+
+// UnmarshalJSON parses the retention as a duration string.
+func (p *Plan) UnmarshalJSON(data []byte) error {
+	type plan Plan
+	value := struct {
+		*plan
+		Retention string `json:"retention"`
+	}{plan: (*plan)(p)}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value.Retention == "" {
+		return nil
+	}
+	retention, err := time.ParseDuration(value.Retention)
+	if err != nil {
+		return err
+	}
+	p.Retention = retention
+	return nil
+}
@@ -1,8 +1,26 @@
 package objects
 
+import "time"
+
 type User struct {
 	ID    int
 	Name  string `json:"name"`
 	Email string `json:"email"`
 	Space string `json:"space"`
+	// Per-user override of the maximum apps deploy count, 0 if not set.
+	MaxApps int `json:"maxApps"`
+	// Subscription plan of the user, empty for the default plan.
+	Plan string `json:"plan"`
+	// Last login or deploy of the user, zero if unknown.
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	// Identity provider the user logs in with, e.g. github, empty if unknown.
+	Issuer string `json:"issuer"`
+}
+
+// Owner returns the identity recorded on the namespace of the user.
+func (u *User) Owner() string {
+	if u.Email != "" {
+		return u.Email
+	}
+	return u.Name
 }
@@ -0,0 +1,545 @@
+package options
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/platform9/app-controller/pkg/objects"
+)
+
+// EnvPrefix prefixes the environment variables overriding the config file,
+// e.g. APP_CONTROLLER_DB_PASSWORD for db.password.
+const EnvPrefix = "APP_CONTROLLER"
+
+// Config of app-controller, read from the config file and the environment.
+type Config struct {
+	Kubeconfig     KubeconfigConfig        `mapstructure:"kubeconfig"`
+	DB             DBConfig                `mapstructure:"db"`
+	Auth0          Auth0Config             `mapstructure:"auth0"`
+	JWKS           JWKSConfig              `mapstructure:"jwks"`
+	Constraints    ConstraintsConfig       `mapstructure:"constraints"`
+	DefaultPlan    string                  `mapstructure:"default-plan"`
+	Plans          map[string]objects.Plan `mapstructure:"plans"`
+	Admin          AdminConfig             `mapstructure:"admin"`
+	Reaper         ReaperConfig            `mapstructure:"reaper"`
+	GC             GCConfig                `mapstructure:"gc"`
+	Reconcile      ReconcileConfig         `mapstructure:"reconcile"`
+	LeaderElection LeaderElectionConfig    `mapstructure:"leader-election"`
+	NetworkPolicy  NetworkPolicyConfig     `mapstructure:"network-policy"`
+	PodSecurity    PodSecurityConfig       `mapstructure:"pod-security"`
+	Namespace      NamespaceConfig         `mapstructure:"namespace"`
+	Metrics        MetricsConfig           `mapstructure:"metrics"`
+	Server         ServerConfig            `mapstructure:"server"`
+	Log            LogConfig               `mapstructure:"log"`
+}
+
+// KubeconfigConfig locates the cluster running Knative.
+type KubeconfigConfig struct {
+	File string `mapstructure:"file"`
+}
+
+// DBConfig of the sqlite3, mysql or postgres database.
+type DBConfig struct {
+	Type     string `mapstructure:"type"`
+	Src      string `mapstructure:"src"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Name     string `mapstructure:"name"`
+	// SSL mode of the postgres connections, e.g. disable or verify-full.
+	SSLMode string `mapstructure:"sslmode"`
+	// Connection pool, 0 keeps the defaults of database/sql.
+	MaxOpenConns    int           `mapstructure:"max-open-conns"`
+	MaxIdleConns    int           `mapstructure:"max-idle-conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn-max-lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn-max-idle-time"`
+	// How long to retry connecting to the database at startup.
+	ConnectTimeout time.Duration `mapstructure:"connect-timeout"`
+}
+
+// Auth0Config of the tenant issuing the tokens.
+type Auth0Config struct {
+	ClientID string `mapstructure:"client-id"`
+}
+
+// JWKSConfig locates the keys verifying the tokens.
+type JWKSConfig struct {
+	URL string `mapstructure:"url"`
+}
+
+// ConstraintsConfig are the limits of plans not setting them.
+type ConstraintsConfig struct {
+	MaxScale int `mapstructure:"max-scale"`
+	MaxApp   int `mapstructure:"max-app"`
+}
+
+// AdminConfig lists the users allowed to use the admin APIs.
+type AdminConfig struct {
+	Emails []string `mapstructure:"emails"`
+}
+
+// ReaperConfig of the removal of idle and expired apps.
+type ReaperConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Interval      time.Duration `mapstructure:"interval"`
+	IdlePeriod    time.Duration `mapstructure:"idle-period"`
+	WarningPeriod time.Duration `mapstructure:"warning-period"`
+	Mode          string        `mapstructure:"mode"`
+	NotifyURL     string        `mapstructure:"notify-url"`
+}
+
+// GCConfig of the deletion of inactive users and orphan namespaces.
+type GCConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Interval       time.Duration `mapstructure:"interval"`
+	InactivePeriod time.Duration `mapstructure:"inactive-period"`
+	OrphanGrace    time.Duration `mapstructure:"orphan-grace"`
+}
+
+// ReconcileConfig of the periodic reconciliation of users and namespaces.
+type ReconcileConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	Fix      bool          `mapstructure:"fix"`
+}
+
+// LeaderElectionConfig locates the lease electing the replica running the
+// background controllers.
+type LeaderElectionConfig struct {
+	Namespace string `mapstructure:"namespace"`
+	LeaseName string `mapstructure:"lease-name"`
+}
+
+// NetworkPolicyConfig of the isolation of the namespaces of users.
+type NetworkPolicyConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	AllowedNamespaces []string `mapstructure:"allowed-namespaces"`
+}
+
+// PodSecurityConfig are the Pod Security admission levels of the namespaces
+// of users, empty to not set a mode.
+type PodSecurityConfig struct {
+	Enforce string `mapstructure:"enforce"`
+	Audit   string `mapstructure:"audit"`
+	Warn    string `mapstructure:"warn"`
+	Version string `mapstructure:"version"`
+}
+
+// NamespaceConfig of the naming of the namespaces of users.
+type NamespaceConfig struct {
+	Prefix      string `mapstructure:"prefix"`
+	MaxAttempts int    `mapstructure:"max-attempts"`
+}
+
+// MetricsConfig of the Prometheus metrics listener.
+type MetricsConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Address  string        `mapstructure:"address"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// ServerConfig of the API listener.
+type ServerConfig struct {
+	Address       string        `mapstructure:"address"`
+	TLS           TLSConfig     `mapstructure:"tls"`
+	ReadTimeout   time.Duration `mapstructure:"read-timeout"`
+	WriteTimeout  time.Duration `mapstructure:"write-timeout"`
+	IdleTimeout   time.Duration `mapstructure:"idle-timeout"`
+	ShutdownDelay time.Duration `mapstructure:"shutdown-delay"`
+	ShutdownGrace time.Duration `mapstructure:"shutdown-grace"`
+}
+
+// TLSConfig of the API listener.
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert-file"`
+	KeyFile      string `mapstructure:"key-file"`
+	ClientCAFile string `mapstructure:"client-ca-file"`
+	ClientAuth   string `mapstructure:"client-auth"`
+}
+
+// LogConfig of the logger.
+type LogConfig struct {
+	Output     string `mapstructure:"output"`
+	File       string `mapstructure:"file"`
+	Level      string `mapstructure:"level"`
+	Format     string `mapstructure:"format"`
+	MaxSize    int    `mapstructure:"max-size"`
+	MaxBackups int    `mapstructure:"max-backups"`
+	MaxAge     int    `mapstructure:"max-age"`
+	Compress   bool   `mapstructure:"compress"`
+	Redact     bool   `mapstructure:"redact"`
+}
+
+// Default returns the config used for the values missing from the config
+// file and the environment.
+func Default() *Config {
+	return &Config{
+		DB: DBConfig{
+			Type:            defaultDBType,
+			Src:             defaultDBSrc,
+			SSLMode:         defaultDBSSLMode,
+			MaxOpenConns:    defaultDBMaxOpenConns,
+			MaxIdleConns:    defaultDBMaxIdleConns,
+			ConnMaxLifetime: mustDuration(defaultDBConnMaxLifetime),
+			ConnMaxIdleTime: mustDuration(defaultDBConnMaxIdleTime),
+			ConnectTimeout:  mustDuration(defaultDBConnectTimeout),
+		},
+		Constraints: ConstraintsConfig{
+			MaxScale: maxAppScaleCount,
+			MaxApp:   maxAppDeployCount,
+		},
+		Reaper: ReaperConfig{
+			Interval:      mustDuration(defaultReaperInterval),
+			WarningPeriod: mustDuration(defaultReaperWarningPeriod),
+			Mode:          defaultReaperMode,
+		},
+		GC: GCConfig{
+			Interval:    mustDuration(defaultGCInterval),
+			OrphanGrace: mustDuration(defaultGCOrphanGrace),
+		},
+		Reconcile: ReconcileConfig{
+			Interval: mustDuration(defaultReconcileInterval),
+		},
+		LeaderElection: LeaderElectionConfig{
+			Namespace: defaultLeaseNamespace,
+			LeaseName: defaultLeaseName,
+		},
+		NetworkPolicy: NetworkPolicyConfig{
+			Enabled:           true,
+			AllowedNamespaces: append([]string{}, defaultNetworkPolicyAllowedNamespaces...),
+		},
+		PodSecurity: PodSecurityConfig{
+			Enforce: defaultPodSecurityEnforce,
+			Version: defaultPodSecurityVersion,
+		},
+		Namespace: NamespaceConfig{
+			MaxAttempts: defaultNamespaceMaxAttempts,
+		},
+		Metrics: MetricsConfig{
+			Enabled:  true,
+			Address:  defaultMetricsAddress,
+			Interval: mustDuration(defaultMetricsInterval),
+		},
+		Server: ServerConfig{
+			Address:       defaultServerAddress,
+			ReadTimeout:   mustDuration(defaultServerReadTimeout),
+			WriteTimeout:  mustDuration(defaultServerWriteTimeout),
+			IdleTimeout:   mustDuration(defaultServerIdleTimeout),
+			ShutdownDelay: mustDuration(defaultServerShutdownDelay),
+			ShutdownGrace: mustDuration(defaultServerShutdownGrace),
+		},
+		Log: LogConfig{
+			Output:     defaultLogOutput,
+			Level:      defaultLogLevel,
+			Format:     defaultLogFormat,
+			MaxSize:    defaultLogMaxSize,
+			MaxBackups: defaultLogMaxBackups,
+			MaxAge:     defaultLogMaxAge,
+			Redact:     true,
+		},
+	}
+}
+
+func mustDuration(value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Config in use, the default one until Set.
+var current atomic.Value
+
+// Get returns the config in use.
+func Get() *Config {
+	if config, ok := current.Load().(*Config); ok {
+		return config
+	}
+	return defaultConfig
+}
+
+var defaultConfig = Default()
+
+// Set replaces the config in use.
+func Set(config *Config) {
+	current.Store(config)
+}
+
+// ConfigError lists every error of a config.
+type ConfigError struct {
+	Errors []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("Invalid config, %d error(s):\n  %s", len(e.Errors), strings.Join(e.Errors, "\n  "))
+}
+
+// Keys of the fields of the struct type, e.g. server.tls.cert-file. Maps,
+// such as plans, have no fixed keys and are skipped.
+func configKeys(t reflect.Type, prefix string) []string {
+	keys := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configKeys(field.Type, key+".")...)
+		case reflect.Map:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Load reads the config file, if any, and the APP_CONTROLLER_* environment
+// variables over the defaults, and validates the result. Every invalid value
+// is reported in a single ConfigError.
+func Load(file string) (*Config, error) {
+	v := viper.New()
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		if err := v.BindEnv(key); err != nil {
+			return nil, err
+		}
+	}
+	if file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("Failed to read config file %v. Error: %v", file, err)
+		}
+	}
+
+	config := Default()
+	errs := []string{}
+	if err := v.Unmarshal(config); err != nil {
+		// Keep validating the values that could be decoded.
+		if decodeErr, ok := err.(*mapstructure.Error); ok {
+			errs = append(errs, decodeErr.Errors...)
+		} else {
+			errs = append(errs, err.Error())
+		}
+	}
+	errs = append(errs, config.validate()...)
+	if len(errs) > 0 {
+		return config, &ConfigError{Errors: errs}
+	}
+	return config, nil
+}
+
+// Validate checks every field of the config, and reports all the invalid
+// ones in a ConfigError.
+func (c *Config) Validate() error {
+	if errs := c.validate(); len(errs) > 0 {
+		return &ConfigError{Errors: errs}
+	}
+	return nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func validURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (c *Config) validate() []string {
+	errs := []string{}
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	switch c.DB.Type {
+	case "sqlite3":
+		if c.DB.Src == "" {
+			fail("db.src is required for sqlite3")
+		}
+	case "mysql", "postgres":
+		for _, field := range []struct{ key, value string }{
+			{"db.user", c.DB.User}, {"db.host", c.DB.Host}, {"db.name", c.DB.Name},
+		} {
+			if field.value == "" {
+				fail("%v is required for %v", field.key, c.DB.Type)
+			}
+		}
+	default:
+		fail("db.type %q is not supported, expected sqlite3, mysql or postgres", c.DB.Type)
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxOpenConns == 1 {
+		// The migrations lock holds a connection while migrating.
+		fail("db.max-open-conns must be 0 for unlimited or at least 2, got %d", c.DB.MaxOpenConns)
+	}
+	if c.DB.MaxIdleConns < 0 {
+		fail("db.max-idle-conns must not be negative, got %d", c.DB.MaxIdleConns)
+	}
+	if c.DB.Type == "postgres" && !oneOf(c.DB.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full") {
+		fail("db.sslmode %q is invalid", c.DB.SSLMode)
+	}
+
+	if c.Auth0.ClientID == "" {
+		fail("auth0.client-id is required")
+	}
+	if !validURL(c.JWKS.URL) {
+		fail("jwks.url %q is not a valid http(s) URL", c.JWKS.URL)
+	}
+
+	if c.Constraints.MaxApp <= 0 {
+		fail("constraints.max-app must be positive, got %d", c.Constraints.MaxApp)
+	}
+	if c.Constraints.MaxScale <= 0 {
+		fail("constraints.max-scale must be positive, got %d", c.Constraints.MaxScale)
+	}
+	names := []string{}
+	for name := range c.Plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if plan := c.Plans[name]; plan.MaxApps < 0 || plan.MaxScale < 0 || plan.MaxDomains < 0 || plan.Retention < 0 {
+			fail("plans.%v limits must not be negative", name)
+		}
+	}
+	if c.DefaultPlan != "" && len(c.Plans) > 0 {
+		if _, ok := c.Plans[strings.ToLower(c.DefaultPlan)]; !ok {
+			fail("default-plan %q is not one of the plans", c.DefaultPlan)
+		}
+	}
+
+	for _, email := range c.Admin.Emails {
+		if !strings.Contains(email, "@") {
+			fail("admin.emails %q is not an email", email)
+		}
+	}
+
+	if !oneOf(c.Reaper.Mode, "delete", "mark") {
+		fail("reaper.mode %q is invalid, expected delete or mark", c.Reaper.Mode)
+	}
+	if c.Reaper.NotifyURL != "" && !validURL(c.Reaper.NotifyURL) {
+		fail("reaper.notify-url %q is not a valid http(s) URL", c.Reaper.NotifyURL)
+	}
+	for _, field := range []struct {
+		key      string
+		value    time.Duration
+		positive bool
+	}{
+		{"db.conn-max-lifetime", c.DB.ConnMaxLifetime, false},
+		{"db.conn-max-idle-time", c.DB.ConnMaxIdleTime, false},
+		{"db.connect-timeout", c.DB.ConnectTimeout, true},
+		{"reaper.interval", c.Reaper.Interval, true},
+		{"reaper.idle-period", c.Reaper.IdlePeriod, false},
+		{"reaper.warning-period", c.Reaper.WarningPeriod, false},
+		{"gc.interval", c.GC.Interval, true},
+		{"gc.inactive-period", c.GC.InactivePeriod, false},
+		{"gc.orphan-grace", c.GC.OrphanGrace, false},
+		{"reconcile.interval", c.Reconcile.Interval, true},
+		{"metrics.interval", c.Metrics.Interval, true},
+		{"server.read-timeout", c.Server.ReadTimeout, false},
+		{"server.write-timeout", c.Server.WriteTimeout, false},
+		{"server.idle-timeout", c.Server.IdleTimeout, false},
+		{"server.shutdown-delay", c.Server.ShutdownDelay, false},
+		{"server.shutdown-grace", c.Server.ShutdownGrace, false},
+	} {
+		if field.value < 0 || (field.positive && field.value == 0) {
+			fail("%v must be positive, got %v", field.key, field.value)
+		}
+	}
+
+	if c.LeaderElection.Namespace == "" || c.LeaderElection.LeaseName == "" {
+		fail("leader-election.namespace and leader-election.lease-name are required")
+	}
+
+	for _, field := range []struct{ mode, level string }{
+		{"enforce", c.PodSecurity.Enforce}, {"audit", c.PodSecurity.Audit}, {"warn", c.PodSecurity.Warn},
+	} {
+		if !oneOf(field.level, "", "privileged", "baseline", "restricted") {
+			fail("pod-security.%v %q is invalid, expected privileged, baseline or restricted", field.mode, field.level)
+		}
+	}
+	if c.Namespace.MaxAttempts <= 0 {
+		fail("namespace.max-attempts must be positive, got %d", c.Namespace.MaxAttempts)
+	}
+
+	if c.Metrics.Enabled && c.Metrics.Address == "" {
+		fail("metrics.address is required when metrics are enabled")
+	}
+	if c.Server.Address == "" {
+		fail("server.address is required")
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		fail("server.tls.cert-file and server.tls.key-file must be set together")
+	}
+	if !oneOf(c.Server.TLS.ClientAuth, "", "none", "optional", "require") {
+		fail("server.tls.client-auth %q is invalid, expected none, optional or require", c.Server.TLS.ClientAuth)
+	}
+	if c.Server.TLS.ClientCAFile != "" && c.Server.TLS.CertFile == "" {
+		fail("server.tls.client-ca-file requires server.tls.cert-file")
+	}
+
+	if !oneOf(c.Log.Output, "file", "stdout", "stderr") {
+		fail("log.output %q is invalid, expected file, stdout or stderr", c.Log.Output)
+	}
+	if !oneOf(strings.ToLower(c.Log.Level), "debug", "info", "warn", "error", "dpanic", "panic", "fatal") {
+		fail("log.level %q is invalid, expected debug, info, warn or error", c.Log.Level)
+	}
+	if !oneOf(c.Log.Format, "console", "json") {
+		fail("log.format %q is invalid, expected console or json", c.Log.Format)
+	}
+	if c.Log.MaxSize < 0 || c.Log.MaxBackups < 0 || c.Log.MaxAge < 0 {
+		fail("log.max-size, log.max-backups and log.max-age must not be negative")
+	}
+	return errs
+}
+
+// Reload returns a copy of the config with the fields safe to change at
+// runtime taken from the updated config: the constraints, the plans and the
+// log level. The other fields require a restart.
+func (c *Config) Reload(updated *Config) *Config {
+	reloaded := *c
+	reloaded.Constraints = updated.Constraints
+	reloaded.DefaultPlan = updated.DefaultPlan
+	reloaded.Plans = updated.Plans
+	reloaded.Log.Level = updated.Log.Level
+	return &reloaded
+}
+
+// Watch reloads the safe fields of the config in use, see Reload, when the
+// config file changes, and calls onReload with the new config. Invalid
+// configs are logged and ignored.
+func Watch(file string, onReload func(*Config)) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.OnConfigChange(func(event fsnotify.Event) {
+		updated, err := Load(file)
+		if err != nil {
+			zap.S().Errorf("Ignoring change of config file %v. Error: %v", file, err)
+			return
+		}
+		reloaded := Get().Reload(updated)
+		Set(reloaded)
+		zap.S().Infof("Reloaded config file %v", file)
+		if onReload != nil {
+			onReload(reloaded)
+		}
+	})
+	v.WatchConfig()
+}
@@ -2,33 +2,66 @@ package options
 
 import (
 	"fmt"
-	"strconv"
-
-	"github.com/spf13/viper"
+	"net"
+	"net/url"
+	"time"
 )
 
 const (
 	defaultDBType     = "mysql"
 	defaultDBSrc      = "file::memory:?cache=shared"
+	defaultDBSSLMode  = "disable"
 	maxAppScaleCount  = 1
 	maxAppDeployCount = 7
+
+	defaultDBMaxOpenConns       = 20
+	defaultDBMaxIdleConns       = 5
+	defaultDBConnMaxLifetime    = "30m"
+	defaultDBConnMaxIdleTime    = "5m"
+	defaultDBConnectTimeout     = "2m"
+	defaultReaperInterval       = "10m"
+	defaultReaperWarningPeriod  = "24h"
+	defaultReaperMode           = "delete"
+	defaultGCInterval           = "24h"
+	defaultGCOrphanGrace        = "1h"
+	defaultReconcileInterval    = "1h"
+	defaultLeaseNamespace       = "default"
+	defaultLeaseName            = "app-controller"
+	defaultPodSecurityEnforce   = "baseline"
+	defaultPodSecurityVersion   = "latest"
+	defaultNamespaceMaxAttempts = 5
+	defaultMetricsAddress       = ":9112"
+	defaultMetricsInterval      = "1m"
+	defaultServerAddress        = ":6112"
+	defaultServerReadTimeout    = "30s"
+	defaultServerWriteTimeout   = "60s"
+	defaultServerIdleTimeout    = "120s"
+	defaultServerShutdownDelay  = "5s"
+	defaultServerShutdownGrace  = "30s"
+	defaultLogOutput            = "file"
+	defaultLogLevel             = "info"
+	defaultLogFormat            = "console"
+	defaultLogMaxSize           = 100
+	defaultLogMaxBackups        = 5
+	defaultLogMaxAge            = 30
 )
 
-func init() {
-	viper.SetDefault("db.type", defaultDBType)
-	viper.SetDefault("db.src", defaultDBSrc)
-	viper.SetDefault("constraints.max-scale", maxAppScaleCount)
-	viper.SetDefault("constraints.max-app", maxAppDeployCount)
+// Namespaces of the Knative activator and ingress, allowed to reach the apps.
+var defaultNetworkPolicyAllowedNamespaces = []string{"knative-serving", "kourier-system"}
+
+// GetKubeconfigFile returns the kubeconfig of the cluster running Knative.
+func GetKubeconfigFile() string {
+	return Get().Kubeconfig.File
 }
 
 // GetDBType returns database type
 func GetDBType() string {
-	return viper.GetString("db.type")
+	return Get().DB.Type
 }
 
 // GetDBSrc returns database source string
 func GetDBSrc() string {
-	return viper.GetString("db.src")
+	return Get().DB.Src
 }
 
 // GetDefaultDBSrc returns database source string
@@ -38,40 +71,301 @@ func GetDefaultDBSrc() string {
 
 // GetDBCreds returns MySQL db string
 func GetDBCreds() string {
+	db := Get().DB
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-		viper.GetString("db.user"),
-		viper.GetString("db.password"),
-		viper.GetString("db.host"),
-		viper.GetString("db.port"),
-		viper.GetString("db.name"))
+		db.User, db.Password, db.Host, db.Port, db.Name)
+}
+
+// GetPostgresDSN returns PostgreSQL connection string
+func GetPostgresDSN() string {
+	db := Get().DB
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(db.User, db.Password),
+		Host:     db.Host,
+		Path:     "/" + db.Name,
+		RawQuery: url.Values{"sslmode": []string{db.SSLMode}}.Encode(),
+	}
+	if db.Port != "" {
+		dsn.Host = net.JoinHostPort(db.Host, db.Port)
+	}
+	return dsn.String()
+}
+
+// GetDBMaxOpenConns returns the maximum open connections to the database.
+func GetDBMaxOpenConns() int {
+	return Get().DB.MaxOpenConns
+}
+
+// GetDBMaxIdleConns returns the maximum idle connections kept in the pool.
+func GetDBMaxIdleConns() int {
+	return Get().DB.MaxIdleConns
+}
+
+// GetDBConnMaxLifetime returns the maximum duration a connection is reused.
+func GetDBConnMaxLifetime() time.Duration {
+	return Get().DB.ConnMaxLifetime
+}
+
+// GetDBConnMaxIdleTime returns the maximum duration a connection stays idle.
+func GetDBConnMaxIdleTime() time.Duration {
+	return Get().DB.ConnMaxIdleTime
+}
+
+// GetDBConnectTimeout returns how long to retry connecting to the database at startup.
+func GetDBConnectTimeout() time.Duration {
+	return Get().DB.ConnectTimeout
 }
 
 // GetConstraintMaxScale returns the maximum app scale count.
 func GetConstraintMaxScale() int {
-	max_scale_str := viper.GetString("constraints.max-scale")
-	max_scale, err := strconv.Atoi(max_scale_str)
-	if err != nil {
-		return maxAppScaleCount
-	}
-	return max_scale
+	return Get().Constraints.MaxScale
 }
 
 // GetAuth0ClientId returns the auth0 client-id.
 func GetAuth0ClientId() string {
-	return viper.GetString("auth0.client-id")
+	return Get().Auth0.ClientID
 }
 
 // GetConstraintMaxAppDeploy returns the maximum apps allowed to deploy.
 func GetConstraintMaxAppDeploy() int {
-	max_app_str := viper.GetString("constraints.max-app")
-	max_app, err := strconv.Atoi(max_app_str)
-	if err != nil {
-		return maxAppDeployCount
-	}
-	return max_app
+	return Get().Constraints.MaxApp
 }
 
 // GetJWKSURL returns the JWKS-URL for validation.
 func GetJWKSURL() string {
-	return viper.GetString("jwks.url")
+	return Get().JWKS.URL
+}
+
+// GetReaperEnabled returns if idle and expired apps should be reaped.
+func GetReaperEnabled() bool {
+	return Get().Reaper.Enabled
+}
+
+// GetReaperInterval returns the period between two reaper runs.
+func GetReaperInterval() time.Duration {
+	return Get().Reaper.Interval
+}
+
+// GetReaperIdlePeriod returns the idle period after which apps are reaped,
+// for plans without a retention.
+func GetReaperIdlePeriod() time.Duration {
+	return Get().Reaper.IdlePeriod
+}
+
+// GetReaperWarningPeriod returns how long before reaping an app its owner is warned.
+func GetReaperWarningPeriod() time.Duration {
+	return Get().Reaper.WarningPeriod
+}
+
+// GetReaperMode returns the action on idle apps, "delete" or "mark".
+func GetReaperMode() string {
+	return Get().Reaper.Mode
+}
+
+// GetReaperNotifyURL returns the webhook URL notified before reaping an app.
+func GetReaperNotifyURL() string {
+	return Get().Reaper.NotifyURL
+}
+
+// GetGCEnabled returns if inactive users and orphan namespaces should be deleted.
+func GetGCEnabled() bool {
+	return Get().GC.Enabled
+}
+
+// GetGCInterval returns the period between two garbage collections.
+func GetGCInterval() time.Duration {
+	return Get().GC.Interval
+}
+
+// GetGCInactivePeriod returns the inactivity after which users are deleted.
+func GetGCInactivePeriod() time.Duration {
+	return Get().GC.InactivePeriod
+}
+
+// GetGCOrphanGrace returns the age of a namespace without user before it is deleted.
+func GetGCOrphanGrace() time.Duration {
+	return Get().GC.OrphanGrace
+}
+
+// GetReconcileEnabled returns if DB and cluster should be reconciled periodically.
+func GetReconcileEnabled() bool {
+	return Get().Reconcile.Enabled
+}
+
+// GetReconcileInterval returns the period between two reconciliations.
+func GetReconcileInterval() time.Duration {
+	return Get().Reconcile.Interval
+}
+
+// GetReconcileFix returns if the periodic reconciliation fixes the drift.
+func GetReconcileFix() bool {
+	return Get().Reconcile.Fix
+}
+
+// GetLeaseNamespace returns the namespace of the leader election lease.
+func GetLeaseNamespace() string {
+	return Get().LeaderElection.Namespace
+}
+
+// GetLeaseName returns the name of the leader election lease.
+func GetLeaseName() string {
+	return Get().LeaderElection.LeaseName
+}
+
+// GetNetworkPolicyEnabled returns if the namespaces of users are isolated
+// from each other through a NetworkPolicy.
+func GetNetworkPolicyEnabled() bool {
+	return Get().NetworkPolicy.Enabled
+}
+
+// GetNetworkPolicyAllowedNamespaces returns the namespaces allowed to reach
+// the apps of every user.
+func GetNetworkPolicyAllowedNamespaces() []string {
+	return Get().NetworkPolicy.AllowedNamespaces
+}
+
+// GetPodSecurityLabels returns the Pod Security admission labels of the
+// namespaces of users, for the enforce, audit and warn modes configured.
+func GetPodSecurityLabels() map[string]string {
+	labels := map[string]string{}
+	podSecurity := Get().PodSecurity
+	for _, mode := range []struct{ name, level string }{
+		{"enforce", podSecurity.Enforce}, {"audit", podSecurity.Audit}, {"warn", podSecurity.Warn},
+	} {
+		if mode.level == "" {
+			continue
+		}
+		labels["pod-security.kubernetes.io/"+mode.name] = mode.level
+		if podSecurity.Version != "" {
+			labels["pod-security.kubernetes.io/"+mode.name+"-version"] = podSecurity.Version
+		}
+	}
+	return labels
+}
+
+// GetNamespacePrefix returns the prefix of the namespace names of users.
+func GetNamespacePrefix() string {
+	return Get().Namespace.Prefix
+}
+
+// GetNamespaceMaxAttempts returns the names tried when creating the namespace
+// of a user before giving up.
+func GetNamespaceMaxAttempts() int {
+	return Get().Namespace.MaxAttempts
+}
+
+// GetMetricsEnabled returns if the Prometheus metrics are served.
+func GetMetricsEnabled() bool {
+	return Get().Metrics.Enabled
+}
+
+// GetMetricsAddress returns the address of the listener serving the metrics,
+// separate from the API one.
+func GetMetricsAddress() string {
+	return Get().Metrics.Address
+}
+
+// GetMetricsInterval returns the period between two collections of the
+// users and apps gauges.
+func GetMetricsInterval() time.Duration {
+	return Get().Metrics.Interval
+}
+
+// GetServerAddress returns the listen address of the API.
+func GetServerAddress() string {
+	return Get().Server.Address
+}
+
+// GetServerCertFile returns the TLS certificate file of the API, TLS is
+// disabled if empty.
+func GetServerCertFile() string {
+	return Get().Server.TLS.CertFile
+}
+
+// GetServerKeyFile returns the TLS key file of the API.
+func GetServerKeyFile() string {
+	return Get().Server.TLS.KeyFile
+}
+
+// GetServerClientCAFile returns the CA bundle verifying client certificates.
+func GetServerClientCAFile() string {
+	return Get().Server.TLS.ClientCAFile
+}
+
+// GetServerClientAuth returns the client certificate authentication mode:
+// none, optional or require.
+func GetServerClientAuth() string {
+	return Get().Server.TLS.ClientAuth
+}
+
+// GetServerReadTimeout returns the maximum duration to read a request.
+func GetServerReadTimeout() time.Duration {
+	return Get().Server.ReadTimeout
+}
+
+// GetServerWriteTimeout returns the maximum duration to write a response.
+func GetServerWriteTimeout() time.Duration {
+	return Get().Server.WriteTimeout
+}
+
+// GetServerIdleTimeout returns the maximum duration of idle keep-alive connections.
+func GetServerIdleTimeout() time.Duration {
+	return Get().Server.IdleTimeout
+}
+
+// GetServerShutdownDelay returns the time the server keeps serving new
+// requests on shutdown, while its readiness fails.
+func GetServerShutdownDelay() time.Duration {
+	return Get().Server.ShutdownDelay
+}
+
+// GetServerShutdownGrace returns the time allowed to in-flight requests on shutdown.
+func GetServerShutdownGrace() time.Duration {
+	return Get().Server.ShutdownGrace
+}
+
+// GetLogOutput returns where logs are written: file, stdout or stderr.
+func GetLogOutput() string {
+	return Get().Log.Output
+}
+
+// GetLogFile returns the log file of the file output, the default one if empty.
+func GetLogFile() string {
+	return Get().Log.File
+}
+
+// GetLogLevel returns the minimum level logged.
+func GetLogLevel() string {
+	return Get().Log.Level
+}
+
+// GetLogFormat returns the log format, console or json.
+func GetLogFormat() string {
+	return Get().Log.Format
+}
+
+// GetLogMaxSize returns the size in megabytes after which the log file is rotated.
+func GetLogMaxSize() int {
+	return Get().Log.MaxSize
+}
+
+// GetLogMaxBackups returns the number of rotated log files kept.
+func GetLogMaxBackups() int {
+	return Get().Log.MaxBackups
+}
+
+// GetLogMaxAge returns the days rotated log files are kept.
+func GetLogMaxAge() int {
+	return Get().Log.MaxAge
+}
+
+// GetLogCompress checks if rotated log files are compressed.
+func GetLogCompress() bool {
+	return Get().Log.Compress
+}
+
+// GetLogRedact checks if emails, tokens and passwords are masked in logs.
+func GetLogRedact() bool {
+	return Get().Log.Redact
 }
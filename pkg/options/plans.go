@@ -0,0 +1,91 @@
+package options
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/platform9/app-controller/pkg/objects"
+	"go.uber.org/zap"
+)
+
+const (
+	// Plan used when no plans are configured.
+	defaultPlanName = "default"
+)
+
+// GetPlans returns the configured subscription plans by name. When no plans
+// are configured, a single default plan is built from the constraints.
+func GetPlans() map[string]objects.Plan {
+	plans := map[string]objects.Plan{}
+	for name, plan := range Get().Plans {
+		plans[strings.ToLower(name)] = plan
+	}
+	if len(plans) == 0 {
+		plans[defaultPlanName] = objects.Plan{}
+	}
+
+	for name, plan := range plans {
+		plan.Name = name
+		if plan.MaxApps <= 0 {
+			plan.MaxApps = GetConstraintMaxAppDeploy()
+		}
+		if plan.MaxScale <= 0 {
+			plan.MaxScale = GetConstraintMaxScale()
+		}
+		plans[name] = plan
+	}
+	return plans
+}
+
+// GetPlanNames returns the sorted names of the configured plans.
+func GetPlanNames() []string {
+	names := []string{}
+	for name := range GetPlans() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetDefaultPlanName returns the plan of users without one.
+func GetDefaultPlanName() string {
+	if name := Get().DefaultPlan; name != "" {
+		return strings.ToLower(name)
+	}
+	return GetPlanNames()[0]
+}
+
+// GetPlan returns the plan with the given name, or the default plan if the
+// name is empty or not configured.
+func GetPlan(name string) objects.Plan {
+	plans := GetPlans()
+	if plan, ok := plans[strings.ToLower(name)]; ok {
+		return plan
+	}
+	if name != "" {
+		zap.S().Warnf("Plan %v is not configured, using the default plan.", name)
+	}
+	if plan, ok := plans[GetDefaultPlanName()]; ok {
+		return plan
+	}
+	return plans[GetPlanNames()[0]]
+}
+
+// IsPlan checks if a plan with the given name is configured.
+func IsPlan(name string) bool {
+	_, ok := GetPlans()[strings.ToLower(name)]
+	return ok
+}
+
+// IsAdmin checks if the email belongs to an app-controller administrator.
+func IsAdmin(email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, admin := range Get().Admin.Emails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
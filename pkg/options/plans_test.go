@@ -0,0 +1,52 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestGetPlanDefaults(t *testing.T) {
+	setConfig(t, `
+constraints:
+  max-app: "4"
+`)
+
+	plan := GetPlan("")
+	assert.Equal(t, plan.Name, defaultPlanName)
+	assert.Equal(t, plan.MaxApps, 4)
+	assert.Equal(t, plan.MaxScale, maxAppScaleCount)
+}
+
+func TestGetPlanFromConfig(t *testing.T) {
+	setConfig(t, `
+default-plan: free
+plans:
+  free:
+    max-app: 2
+  pro:
+    max-app: "20"
+    max-scale: 5
+    cpu: "2"
+    memory: 2Gi
+    max-domains: 10
+    retention: 720h
+`)
+
+	assert.Equal(t, GetDefaultPlanName(), "free")
+	assert.DeepEqual(t, GetPlanNames(), []string{"free", "pro"})
+	assert.Assert(t, IsPlan("Pro"))
+	assert.Assert(t, !IsPlan("enterprise"))
+
+	pro := GetPlan("pro")
+	assert.Equal(t, pro.Name, "pro")
+	assert.Equal(t, pro.MaxApps, 20)
+	assert.Equal(t, pro.MaxScale, 5)
+	assert.Equal(t, pro.Memory, "2Gi")
+	assert.Equal(t, pro.MaxDomains, 10)
+	assert.Equal(t, pro.Retention, 720*time.Hour)
+
+	// Unknown plans fall back to the default plan.
+	assert.Equal(t, GetPlan("enterprise").Name, "free")
+}
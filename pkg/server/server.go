@@ -0,0 +1,227 @@
+// Package server builds the HTTP server of the API from options.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/platform9/app-controller/pkg/options"
+)
+
+// Client certificate authentication modes.
+const (
+	// Client certificates are not requested.
+	ClientAuthNone = "none"
+	// Client certificates are verified if presented.
+	ClientAuthOptional = "optional"
+	// Client certificates are required and verified.
+	ClientAuthRequire = "require"
+)
+
+// Config of the API server.
+type Config struct {
+	Address string
+	// Certificate and key served, TLS is disabled without them.
+	CertFile string
+	KeyFile  string
+	// CA bundle verifying the client certificates.
+	ClientCAFile string
+	ClientAuth   string
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// Time new requests are still served on shutdown, while the readiness fails.
+	ShutdownDelay time.Duration
+	// Time allowed to in-flight requests on shutdown.
+	ShutdownGrace time.Duration
+}
+
+// ConfigFromOptions returns the server config set through options.
+func ConfigFromOptions() Config {
+	return Config{
+		Address:       options.GetServerAddress(),
+		CertFile:      options.GetServerCertFile(),
+		KeyFile:       options.GetServerKeyFile(),
+		ClientCAFile:  options.GetServerClientCAFile(),
+		ClientAuth:    options.GetServerClientAuth(),
+		ReadTimeout:   options.GetServerReadTimeout(),
+		WriteTimeout:  options.GetServerWriteTimeout(),
+		IdleTimeout:   options.GetServerIdleTimeout(),
+		ShutdownDelay: options.GetServerShutdownDelay(),
+		ShutdownGrace: options.GetServerShutdownGrace(),
+	}
+}
+
+// TLS checks if the server is configured to serve TLS.
+func (c Config) TLS() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// New returns the server of the handler. With TLS, the certificate is
+// reloaded when its files change.
+func New(handler http.Handler, config Config) (*http.Server, error) {
+	srv := &http.Server{
+		Handler:      handler,
+		Addr:         config.Address,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+	}
+	if !config.TLS() {
+		if config.ClientCAFile != "" {
+			return nil, fmt.Errorf("Client certificate authentication requires TLS")
+		}
+		return srv, nil
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = tlsConfig
+	return srv, nil
+}
+
+// ListenAndServe serves plain HTTP or TLS, as configured by New.
+func ListenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		// The certificate comes from the TLS config.
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+// Shutdown stops the server gracefully. Once drain fails the readiness, new
+// requests are served for the shutdown delay, for load balancers to observe it
+// and stop routing to the server, then the in-flight requests get the shutdown
+// grace to complete.
+func Shutdown(srv *http.Server, config Config, drain func()) error {
+	drain()
+	time.Sleep(config.ShutdownDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownGrace)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+func newTLSConfig(config Config) (*tls.Config, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, fmt.Errorf("Both the TLS certificate and key files are required")
+	}
+	reloader, err := NewCertReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch config.ClientAuth {
+	case "":
+		// Require client certificates once a client CA is set.
+		if config.ClientCAFile != "" {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	case ClientAuthNone:
+	case ClientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("Invalid client auth %v, expected one of %v, %v, %v",
+			config.ClientAuth, ClientAuthNone, ClientAuthOptional, ClientAuthRequire)
+	}
+
+	if tlsConfig.ClientAuth != tls.NoClientCert {
+		if config.ClientCAFile == "" {
+			return nil, fmt.Errorf("Client auth %v requires a client CA file", config.ClientAuth)
+		}
+		pem, err := ioutil.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read client CA file. Error: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in client CA file %v", config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// CertReloader serves a certificate, reloaded from its files once they change.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader loads the certificate and key files.
+func NewCertReloader(certFile string, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := reloader.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err = reloader.load(modTime); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Latest modification time of the certificate and key files.
+func (cr *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (cr *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("Failed to load TLS certificate. Error: %v", err)
+	}
+	cr.cert = &cert
+	cr.modTime = modTime
+	return nil
+}
+
+// GetCertificate returns the current certificate, reloading it first if its
+// files changed. The previous certificate is kept if the new one is invalid,
+// e.g. while the files are being replaced.
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	modTime, err := cr.latestModTime()
+	if err == nil && modTime.After(cr.modTime) {
+		if err = cr.load(modTime); err != nil {
+			zap.S().Errorf("Failed to reload TLS certificate, keeping the previous one. Error: %v", err)
+		} else {
+			zap.S().Infof("Reloaded TLS certificate %v", cr.certFile)
+		}
+	}
+	return cr.cert, nil
+}
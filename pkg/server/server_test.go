@@ -0,0 +1,196 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// writeCert writes a self-signed certificate of the common name and its key
+// in dir, and returns their paths.
+func writeCert(t *testing.T, dir string, commonName string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NilError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	assert.NilError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NilError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NilError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first")
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	assert.NilError(t, err)
+	cert, err := reloader.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, commonName(t, cert), "first")
+
+	// Replace the files, with a later modification time.
+	writeCert(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	assert.NilError(t, os.Chtimes(certFile, later, later))
+	assert.NilError(t, os.Chtimes(keyFile, later, later))
+	cert, err = reloader.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, commonName(t, cert), "second")
+
+	// An invalid certificate keeps the previous one.
+	assert.NilError(t, ioutil.WriteFile(certFile, []byte("garbage"), 0600))
+	later = later.Add(time.Minute)
+	assert.NilError(t, os.Chtimes(certFile, later, later))
+	cert, err = reloader.GetCertificate(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, commonName(t, cert), "second")
+
+	_, err = NewCertReloader(filepath.Join(dir, "missing.crt"), keyFile)
+	assert.Assert(t, err != nil)
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "server")
+	handler := http.NotFoundHandler()
+
+	t.Run("plain HTTP with timeouts", func(t *testing.T) {
+		srv, err := New(handler, Config{
+			Address:      ":8080",
+			ReadTimeout:  time.Second,
+			WriteTimeout: 2 * time.Second,
+			IdleTimeout:  3 * time.Second,
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, srv.Addr, ":8080")
+		assert.Assert(t, srv.TLSConfig == nil)
+		assert.Equal(t, srv.ReadTimeout, time.Second)
+		assert.Equal(t, srv.WriteTimeout, 2*time.Second)
+		assert.Equal(t, srv.IdleTimeout, 3*time.Second)
+	})
+
+	t.Run("client CA without TLS", func(t *testing.T) {
+		_, err := New(handler, Config{ClientCAFile: certFile})
+		assert.ErrorContains(t, err, "requires TLS")
+	})
+
+	t.Run("certificate without key", func(t *testing.T) {
+		_, err := New(handler, Config{CertFile: certFile})
+		assert.ErrorContains(t, err, "key files are required")
+	})
+
+	for _, tc := range []struct {
+		name       string
+		clientAuth string
+		caFile     string
+		expected   tls.ClientAuthType
+		err        string
+	}{
+		{name: "TLS only", expected: tls.NoClientCert},
+		{name: "client CA defaults to require", caFile: certFile, expected: tls.RequireAndVerifyClientCert},
+		{name: "none ignores the client CA", clientAuth: ClientAuthNone, caFile: certFile, expected: tls.NoClientCert},
+		{name: "optional", clientAuth: ClientAuthOptional, caFile: certFile, expected: tls.VerifyClientCertIfGiven},
+		{name: "require", clientAuth: ClientAuthRequire, caFile: certFile, expected: tls.RequireAndVerifyClientCert},
+		{name: "require without client CA", clientAuth: ClientAuthRequire, err: "requires a client CA file"},
+		{name: "invalid client CA", clientAuth: ClientAuthOptional, caFile: keyFile, err: "No certificate found"},
+		{name: "invalid mode", clientAuth: "always", caFile: certFile, err: "Invalid client auth"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := New(handler, Config{
+				CertFile:     certFile,
+				KeyFile:      keyFile,
+				ClientCAFile: tc.caFile,
+				ClientAuth:   tc.clientAuth,
+			})
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, srv.TLSConfig.ClientAuth, tc.expected)
+			assert.Equal(t, srv.TLSConfig.ClientCAs != nil, tc.expected != tls.NoClientCert)
+			assert.Equal(t, srv.TLSConfig.MinVersion, uint16(tls.VersionTLS12))
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var draining int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&draining) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(listener)
+	url := "http://" + listener.Addr().String() + "/readyz"
+
+	readyz := func() (int, error) {
+		resp, err := http.Get(url)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	code, err := readyz()
+	assert.NilError(t, err)
+	assert.Equal(t, code, http.StatusOK)
+
+	drained := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Shutdown(srv, Config{ShutdownDelay: time.Second, ShutdownGrace: time.Second}, func() {
+			atomic.StoreInt32(&draining, 1)
+			close(drained)
+		})
+	}()
+
+	// The failing readiness is served during the delay, then the listener closes.
+	<-drained
+	code, err = readyz()
+	assert.NilError(t, err)
+	assert.Equal(t, code, http.StatusServiceUnavailable)
+	assert.NilError(t, <-done)
+	_, err = readyz()
+	assert.Assert(t, err != nil)
+}
@@ -12,10 +12,10 @@ var (
 
 	//Valid NameSpace.
 	ValidNameSpaceRegex = fmt.Sprintf(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
-	NoSpecialChar       = "[^a-zA-Z0-9]+"
 
 	//Maximum App Deploy Error
 	MaxAppDeployError = "Maximum App deploy limit reached!"
+	MaxDomainsError   = "Maximum domains limit reached!"
 	ErrorsToken       = []string{"Token is expired", "Forbidden", "Token Invalid"}
 	Errors            = []string{"Failed to parse image"}
 )
@@ -33,14 +33,34 @@ var (
 )
 
 const (
-	//To create random code.
-	lowerCharSet = "abcdedfghijklmnopqrst"
-	numberSet    = "0123456789"
-	AllCharSet   = lowerCharSet + numberSet
-
 	//Status Code for maximum app deployed limit.
 	MaxAppDeployStatusCode = 429
 
+	//ResourceQuota limiting the knative services in a user namespace.
+	AppQuotaName     = "app-controller-quota"
+	AppQuotaResource = "count/services.serving.knative.dev"
+
+	//Annotations and labels set on apps by app-controller.
+	ExpiresAtAnnotation = "app-controller.platform9.io/expires-at"
+	TTLAnnotation       = "app-controller.platform9.io/ttl"
+	WarnedAtAnnotation  = "app-controller.platform9.io/warned-at"
+	IdleLabel           = "app-controller.platform9.io/idle"
+
+	//Label identifying the namespaces created by app-controller.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "app-controller"
+
+	//Namespace annotation holding the identity the owner is looked up by in DB.
+	OwnerAnnotation = "app-controller.platform9.io/owner"
+	//Label of namespaces without a user, set by reconcile.
+	QuarantinedLabel = "app-controller.platform9.io/quarantined"
+	// Labels of the namespace of a user, to look up its owner.
+	UserIDLabel = "app-controller.platform9.io/user-id"
+	IssuerLabel = "app-controller.platform9.io/issuer"
+	PlanLabel   = "app-controller.platform9.io/plan"
+	// Name of the NetworkPolicy isolating the namespace of a user from other tenants.
+	TenantPolicyName = "app-controller-tenant-isolation"
+
 	// Secret URL constants
 	HTTPURL         = "http://"
 	HTTPSURL        = "https://"